@@ -7,18 +7,29 @@ package main
 /* ------------- IMPORT ------------- */
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"flag"
+	"io"
+	"os"
+	"os/signal"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
-	arrayutils "github.com/alessiosavi/GoGPUtils/array"
 	fileutils "github.com/alessiosavi/GoGPUtils/files"
-	stringutils "github.com/alessiosavi/GoGPUtils/string"
+	"github.com/alessiosavi/GoLog-Viewer/config"
 	"github.com/alessiosavi/GoLog-Viewer/datastructure"
+	"github.com/alessiosavi/GoLog-Viewer/health"
+	"github.com/alessiosavi/GoLog-Viewer/metrics"
+	"github.com/alessiosavi/GoLog-Viewer/parser"
+	"github.com/alessiosavi/GoLog-Viewer/query"
+	"github.com/alessiosavi/GoLog-Viewer/ui"
 
 	utils "github.com/alessiosavi/GoUtils"
 	"github.com/onrik/logrus/filename" // Used for print the name and the logline at each entries of the log file
@@ -43,11 +54,75 @@ func main() {
 	log.SetFormatter(Formatter)
 	log.SetLevel(log.DebugLevel)
 
-	logCfg = InitConfigurationData()          // Init the datastructure.Configuration
-	fileListStruct = InitLogFileData(&logCfg) // Initialize the data
+	logCfg, configFile := InitConfigurationData() // Init the datastructure.Configuration
+	fileListStruct = InitLogFileData(&logCfg)     // List the files, the lines are loaded in background (see /readyz)
 
-	go CoreEngine(fileListStruct, &logCfg)  // Run the core engine as a background task
-	HandleRequests(fileListStruct, &logCfg) // Spawn the HTTP service for serve the request
+	ctx, cancel := context.WithCancel(context.Background()) // Cancelled on SIGTERM/SIGINT, every background task stop
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+		log.Warn("main | ", <-signals, " received, shutting down")
+		signal.Stop(signals) // A second signal kill the process
+		cancel()
+	}()
+
+	if *logCfg.ConfigFile != "" {
+		go WatchConfiguration(ctx, &logCfg, configFile) // Reload the configuration file on SIGHUP or when modified
+	}
+	var engines sync.WaitGroup // Background tasks that have to be completed before the exit
+	engines.Add(1)
+	go func() {
+		defer engines.Done()
+		LoadLogFileData(fileListStruct, &logCfg) // Load the lines of the files
+		if ctx.Err() != nil {
+			return
+		}
+		if *logCfg.Snapshot != "" {
+			engines.Add(1)
+			go func() {
+				defer engines.Done()
+				SnapshotEngine(ctx, fileListStruct, &logCfg) // Save the state of the files at every interval
+			}()
+		}
+		CoreEngine(ctx, fileListStruct, &logCfg) // Run the core engine as a background task
+	}()
+	HandleRequests(ctx, fileListStruct, &logCfg) // Spawn the HTTP service for serve the request, return when the requests in progress are completed
+	cancel()                                     // The service stopped without signal (bind error)
+	Shutdown(fileListStruct, &logCfg, &engines)
+}
+
+// Shutdown wait the background tasks (at most the shutdown timeout) and save the state of the files in the snapshot (if any).
+// The snapshot is not saved if the core engine is still running, in order to not save a partial state. The alert notifications
+// in progress are awaited within the same timeout
+func Shutdown(fileList []datastructure.LogFileStruct, logCfg *datastructure.Configuration, engines *sync.WaitGroup) {
+	log.Trace("Shutdown | START")
+	deadline := time.Now().Add(time.Duration(*logCfg.ShutdownTimeout) * time.Second) // The timeout is shared by the background tasks and the notifications
+	stopped := make(chan struct{})
+	go func() {
+		engines.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		log.Info("Shutdown | Background tasks stopped")
+	case <-time.After(time.Until(deadline)):
+		log.Error("Shutdown | Background tasks still running after ", *logCfg.ShutdownTimeout, " seconds, the snapshot is not saved")
+		return
+	}
+	if *logCfg.Snapshot != "" && !logCfg.Health.State().Loaded.IsZero() {
+		start := time.Now()
+		if err := WriteSnapshot(*logCfg.Snapshot, fileList); err != nil {
+			log.Error("Shutdown | Unable to save the snapshot in ", *logCfg.Snapshot, " | Err: ", err)
+		} else {
+			log.Info("Shutdown | Snapshot saved in ", *logCfg.Snapshot, " | Elapsed: ", time.Since(start))
+		}
+	}
+	if logCfg.AlertEngine.Wait(time.Until(deadline)) {
+		log.Info("Shutdown | Alert notifications completed")
+	} else {
+		log.Error("Shutdown | Alert notifications still in progress after ", *logCfg.ShutdownTimeout, " seconds, they are lost")
+	}
+	log.Trace("Shutdown | STOP")
 }
 
 /* ------------- CORE METHOD ------------- */
@@ -55,36 +130,178 @@ func main() {
 // CoreEngine Main core function for recognize file change. It have to scan the list of file recognize if a file have changed.
 // In order to achieve an higher efficiency and be compliant with every SO this function is developed in pure GO.
 // The datastructure.Configuration of the tool can change at runtime using the API.A boolean channel it's used for be sure to read the data accordly to the latest datastructure.Configuration.
-func CoreEngine(fileList []datastructure.LogFileStruct, logCfg *datastructure.Configuration) {
+// The engine stop when the context is cancelled, after the completion of the round in progress.
+func CoreEngine(ctx context.Context, fileList []datastructure.LogFileStruct, logCfg *datastructure.Configuration) {
 	log.Trace("CoreEngine | START")
 	var round float64
-	lineToPrint := *logCfg.MinLinesToPrint
 	var wg sync.WaitGroup
 	semaphore := make(chan struct{}, 256)
 	for {
+		settings := logCfg.Settings.Get()
+		now := time.Now()
+		var modified int32 // Number of files changed in this round
 		wg.Add(len(fileList))
 		for i := 0; i < len(fileList); i++ { // Iterating the list of file for detecting changes ...
-			go func(i int /* fileList []datastructure.LogFileStruct, logCfg *datastructure.Configuration*/) {
+			go func(i int) {
 				semaphore <- struct{}{}
 				defer func() { <-semaphore }()
 				defer wg.Done()
-				timestamp := fileutils.GetFileModification(fileList[i].LogFileInfoStruct.Path)                                         // Get the the last modification of the file
-				if fileList[i].LogFileInfoStruct.Timestamp != timestamp && timestamp != -1 || lineToPrint != *logCfg.MinLinesToPrint { // If the lines have changed, update the data
-					fileList[i].Data = utils.ReadFile(fileList[i].LogFileInfoStruct.Path, *logCfg.MinLinesToPrint)
-					fileList[i].LogFileInfoStruct.Timestamp = timestamp
-					log.Trace("CoreEngine | Round ", round, " | File [", fileList[i].LogFileInfoStruct.Path, "] has changed!!"+
-						"[", fileList[i].LogFileInfoStruct.Path, "] Last modification ->", fileList[i].LogFileInfoStruct.Timestamp, " | timestamp -> ", timestamp)
-					round++ // Number of time that files have changed
+				if CheckFile(&fileList[i], logCfg, settings, now) {
+					atomic.AddInt32(&modified, 1)
 				}
 			}(i)
 		}
 		wg.Wait()
-		lineToPrint = *logCfg.MinLinesToPrint
-		log.Trace("CoreEngine | Sleeping [", *logCfg.Sleep, "] ZzZzZzZ ....")
-		time.Sleep(time.Duration(*logCfg.Sleep) * time.Second)
+		round += float64(modified) // Number of time that files have changed
+		log.Trace("CoreEngine | Round ", round, " | ", modified, " files changed")
+		logCfg.Health.Round(time.Now())
+		scanDuration.Observe(time.Since(now).Seconds())
+		changedFiles.Add(float64(modified))
+		roundChangedFiles.Set(float64(modified))
+		logCfg.AlertEngine.Evaluate(time.Now()) // Update the state of the alerts with the lines ingested in this round
+		sleep := NextCheck(fileList, logCfg.Settings.Get(), time.Now())
+		log.Trace("CoreEngine | Sleeping [", sleep, "] ZzZzZzZ ....")
+		select {
+		case <-time.After(sleep):
+		case <-logCfg.Settings.Changed(): // The new settings are applied immediately
+		case <-ctx.Done():
+			log.Info("CoreEngine | Stopped after ", logCfg.Health.State().Rounds, " rounds")
+			log.Trace("CoreEngine | STOP")
+			return
+		}
 	}
 }
 
+// CheckFile verify if the file is changed since the last check and load the new lines, applying the settings.
+// The file is read again only if it changed or if the settings changed the lines to keep in memory (see config.Limits.SameLines),
+// the other settings (lines searched, interval among the checks) are applied without read the file. It return true if the file is changed
+func CheckFile(logFile *datastructure.LogFileStruct, logCfg *datastructure.Configuration, settings config.Settings, now time.Time) bool {
+	logFile.Lock() // The API read the state of the file while it's updated
+	defer logFile.Unlock()
+	path := logFile.LogFileInfoStruct.Path
+	if !settings.Managed(path) { // Excluded by the include/exclude rules, the lines in memory are released
+		if !logFile.Excluded {
+			ExcludeLogFile(logFile)
+			log.Info("CheckFile | File [", path, "] excluded")
+		}
+		return false
+	}
+	limits := settings.Limits(path)
+	reload := !limits.SameLines(logFile.LogFileInfoStruct.Limits)
+	if !logFile.Excluded {
+		logFile.LogFileInfoStruct.Limits = limits
+	}
+	if !logFile.Excluded && !reload && now.Sub(logFile.Checked) < time.Duration(limits.Sleep)*time.Second { // Checked recently
+		return false
+	}
+	logFile.Checked = now
+	timestamp := fileutils.GetFileModification(path) // Get the the last modification of the file
+	if timestamp == -1 {                             // Removed or not accessible, the lines in memory are kept
+		if f, err := os.Open(path); err != nil {
+			FileFailed(logFile, errorKind(err), err)
+		} else {
+			f.Close()
+		}
+		return false
+	}
+	if logFile.Excluded { // Included again: the lines appended in the meanwhile are not ingested
+		SeekEnd(logFile)
+		ReadLogFile(logFile, limits)
+		logFile.Excluded = false
+		logFile.LogFileInfoStruct.Timestamp = timestamp
+		log.Info("CheckFile | File [", path, "] included")
+		return false
+	}
+	if logFile.LogFileInfoStruct.Timestamp == timestamp && !reload && !logFile.Error.Failing {
+		return false
+	}
+	// The lines have changed (or the limits, or the last read failed), update the data
+	ReadLogFile(logFile, limits)
+	IngestNewLines(logFile, logCfg, now)
+	log.Trace("CheckFile | File [", path, "] has changed!! Last modification -> ", logFile.LogFileInfoStruct.Timestamp, " | timestamp -> ", timestamp)
+	logFile.LogFileInfoStruct.Timestamp = timestamp
+	return true
+}
+
+// NextCheck return the time to wait until the next file have to be checked, at most the global interval (used for apply the changes of the settings)
+func NextCheck(fileList []datastructure.LogFileStruct, settings config.Settings, now time.Time) time.Duration {
+	sleep := time.Duration(settings.Sleep) * time.Second
+	for i := 0; i < len(fileList); i++ {
+		state := fileList[i].State()
+		if state.Excluded {
+			continue
+		}
+		interval := time.Duration(state.Info.Limits.Sleep) * time.Second
+		if wait := state.Checked.Add(interval).Sub(now); wait < sleep {
+			sleep = wait
+		}
+	}
+	if sleep < time.Second {
+		sleep = time.Second
+	}
+	return sleep
+}
+
+// ReadLogFile load the latest lines of the file in memory and update the statistics related to the content.
+// The oldest lines are discarded when the lines exceed the max bytes of the limits. If the file can't be read the lines in memory are kept.
+// The caller have to hold the lock of the file
+func ReadLogFile(logFile *datastructure.LogFileStruct, limits config.Limits) {
+	logFile.LogFileInfoStruct.Limits = limits
+	if logFile.LogFileInfoStruct.Levels == nil {
+		logFile.LogFileInfoStruct.Levels = parser.CountLevels(nil)
+	}
+	f, err := os.Open(logFile.LogFileInfoStruct.Path) // The lines are read by tail, that does not report the cause of the failure
+	if err != nil {
+		log.Error("ReadLogFile | Unable to open ", logFile.LogFileInfoStruct.Path, " | Err: ", err)
+		FileFailed(logFile, errorKind(err), err)
+		return
+	}
+	f.Close()
+	logFile.Data = utils.ReadFile(logFile.LogFileInfoStruct.Path, limits.MinLinesToPrint)
+	if logFile.Data == nil {
+		logFile.LogFileInfoStruct.Levels = parser.CountLevels(nil)
+		logFile.LogFileInfoStruct.Bytes = 0
+		FileRead(logFile)
+		return
+	}
+	data, err := gozstd.Decompress(nil, logFile.Data)
+	if err != nil {
+		log.Error("ReadLogFile | Unable to decompress data of ", logFile.LogFileInfoStruct.Path, " | Err: ", err)
+		FileFailed(logFile, datastructure.ErrorDecompress, err)
+		return
+	}
+	FileRead(logFile)
+	if limits.MaxBytes > 0 && len(data) > limits.MaxBytes {
+		data = TrimLines(data, limits.MaxBytes)
+		logFile.Data = gozstd.Compress(nil, data)
+	}
+	logFile.LogFileInfoStruct.Levels = parser.CountLevels(data)
+	logFile.LogFileInfoStruct.Bytes = len(data)
+}
+
+// TrimLines return the latest complete lines that fit in maxBytes
+func TrimLines(data []byte, maxBytes int) []byte {
+	if len(data) <= maxBytes {
+		return data
+	}
+	start := len(data) - maxBytes
+	if data[start-1] == '\n' { // Cut on a line boundary, the first line is complete
+		return data[start:]
+	}
+	if i := bytes.IndexByte(data[start:], '\n'); i >= 0 { // The first line is truncated
+		return data[start+i+1:]
+	}
+	return nil
+}
+
+// ExcludeLogFile release the lines in memory of the file excluded by the include/exclude rules. The caller have to hold the lock of the file
+func ExcludeLogFile(logFile *datastructure.LogFileStruct) {
+	logFile.Data = nil
+	logFile.LogFileInfoStruct.Levels = parser.CountLevels(nil)
+	logFile.LogFileInfoStruct.Bytes = 0
+	logFile.Excluded = true
+}
+
 func check(err error) {
 	if err != nil {
 		log.Warning("ERR: {" + err.Error() + "}")
@@ -93,94 +310,175 @@ func check(err error) {
 
 /* ------------- API METHOD ------------- */
 
+// idleTimeout is the max time among the requests of a keep-alive connection, the idle connections would delay the shutdown
+const idleTimeout = 10 * time.Second
+
 // HandleRequests is the hook the real function/wrapper for expose the API. It's main scope it's to map the url to the function that have to do the work.
 // It take in input the pointer to the list of file to server; The pointer to the datastructure.Configuration in order to change the parameter at runtime;the channel used for thread safety
-func HandleRequests(fileList []datastructure.LogFileStruct, logCfg *datastructure.Configuration) {
+// The service stop accepting requests when the context is cancelled, it return when the requests in progress are completed (at most the shutdown timeout)
+func HandleRequests(ctx context.Context, fileList []datastructure.LogFileStruct, logCfg *datastructure.Configuration) {
 	log.Trace("HandleRequests | START")
-	m := func(ctx *fasthttp.RequestCtx) { // Hook to the API methods "magilogically"
+	// The gzipHandler will serve a compress request only if the client request it with headers (Content-Type: gzip, deflate)
+	gzipHandler := fasthttp.CompressHandlerLevel(Handler(fileList, logCfg), fasthttp.CompressBestCompression) // Compress data before sending (if requested by the client)
+	server := &fasthttp.Server{Handler: gzipHandler, IdleTimeout: idleTimeout}
+	drained := make(chan bool, 1) // The requests in progress completed before the timeout
+	go func() {
+		<-ctx.Done()
+		timeout := time.Duration(*logCfg.ShutdownTimeout) * time.Second
+		log.Info("HandleRequests | Stop accepting requests, waiting the requests in progress (max ", timeout, ")")
+		done := make(chan struct{})
+		go func() {
+			check(server.Shutdown()) // Close the listener and wait the connections (streams, downloads) to complete
+			close(done)
+		}()
+		select {
+		case <-done:
+			drained <- true
+		case <-time.After(timeout):
+			log.Warn("HandleRequests | Requests still in progress after ", timeout, ", they will be interrupted")
+			drained <- false
+		}
+	}()
+	err := server.ListenAndServe(*logCfg.Hostname + ":" + strconv.Itoa(*logCfg.Port)) // Try to start the server with input "host:port" received in input
+	if err != nil && ctx.Err() == nil {                                               // No luck, connection not successfully. Probably port used ...
+		log.Warn("Port ", *logCfg.Port, " seems used :/")
+		for i := 0; i < 10; i++ {
+			port := strconv.Itoa(utils.Random(8081, 8090)) // Generate a new port to use
+			log.Info("Round ", strconv.Itoa(i), "]No luck! Connecting to anotother random port [@", port, "] ...")
+			*logCfg.Port, err = strconv.Atoi(port) // Updating the datastructure.Configuration with the new port used
+			if err != nil {
+				log.Error("HandleRequests | Unable to parse int [", logCfg.Port, "] | Err: ", err)
+				return
+			}
+			err := server.ListenAndServe(*logCfg.Hostname + ":" + port) // Trying with the random port generate few step above
+			if err == nil || ctx.Err() != nil {                         // Connection estabileshed! (and closed by the shutdown)
+				log.Warning("HandleRequests | Connection estabilished @[", *logCfg.Hostname, ":", *logCfg.Port)
+				break
+			}
+		}
+	}
+	if ctx.Err() != nil && <-drained {
+		log.Info("HandleRequests | Requests in progress completed")
+	}
+	log.Trace("HandleRequests | STOP")
+}
+
+// Handler map the url to the function that have to do the work
+func Handler(fileList []datastructure.LogFileStruct, logCfg *datastructure.Configuration) fasthttp.RequestHandler {
+	return func(ctx *fasthttp.RequestCtx) { // Hook to the API methods "magilogically"
 		ctx.Response.Header.Set("GoLog-Viewer", "v0.0.1$/beta") // Set an header just for track the version of the software
-		log.Info("REQUEST --> ", ctx, " | Headers: ", ctx.Request.Header.String())
+		requestID := SetRequestID(ctx)                          // Returned in the X-Request-ID header and in the errors
+		defer ObserveRequest(ctx, time.Now())                   // Metrics of the request, updated when the response is ready
+		log.Info("REQUEST --> ", requestID, " | ", ctx, " | Headers: ", redactedHeaders(ctx))
 		tmpChar := "============================================================"
+		switch string(ctx.Path()) { // The checks of the load balancer does not require the credentials
+		case "/healthz":
+			HealthzHTTP(ctx, fileList, logCfg) // Liveness: the core engine is progressing
+			log.Info(tmpChar)
+			return
+		case "/readyz":
+			ReadyzHTTP(ctx, fileList, logCfg) // Readiness: the files are loaded and readable
+			log.Info(tmpChar)
+			return
+		}
+		if !AuthorizeHTTP(ctx, logCfg) { // Credentials of the configuration file (if any)
+			log.Info(tmpChar)
+			return
+		}
+		if strings.HasPrefix(string(ctx.Path()), APIPrefix+"/") { // Versioned API, the paths below are kept as aliases
+			APIHTTP(ctx, fileList, logCfg)
+			log.Info(tmpChar)
+			return
+		}
+		if route, ok := LegacyRoute(string(ctx.Path())); ok { // Paths that precede the versioned API, aliases of its resources
+			route.Handler(ctx, fileList, logCfg)
+			log.Info(tmpChar)
+			return
+		}
 		switch string(ctx.Path()) {
 		case "/benchmark":
 			fastBenchmarkHTTP(ctx) // Benchmark API
 		case "/":
-			FastHomePage(ctx, fileList, *logCfg.Hostname, strconv.Itoa(*logCfg.Port)) // Simply print some link
-			log.Info(tmpChar)
-		case "/listAllFile":
-			ListAllFilesHTTP(ctx, fileList) // List all file managed by the application
-			log.Info(tmpChar)
-		case "/getFile":
-			FastGetFileHTTP(ctx, fileList) // Expose the log file
-			log.Info(tmpChar)
-		case "/filterFromFile":
-			FastFilterFileHTTP(ctx, fileList, logCfg) // Filter text from log file
+			if acceptHTML(ctx) && len(ctx.QueryArgs().Peek("plain")) == 0 { // The browsers are redirected to the web interface
+				ctx.Redirect(ui.Prefix, fasthttp.StatusFound)
+				log.Info(tmpChar)
+				break
+			}
+			FastHomePage(ctx, fileList, logCfg.VirtualFiles, *logCfg.Hostname, strconv.Itoa(*logCfg.Port)) // Simply print some link
 			log.Info(tmpChar)
 		case "/changeLine":
 			FastChangeLineHTTP(ctx, logCfg) // Change the number of line printed
 			log.Info(tmpChar)
-		case "/getLinePrinted":
-			FastGetLinePrintedHTTP(ctx, logCfg) // Simply print the active datastructure.Configuration parameter
-			log.Info(tmpChar)
 		default:
+			if UIHTTP(ctx) { // Assets of the web interface
+				break
+			}
+			ctx.SetStatusCode(fasthttp.StatusNotFound)
 			_, err := ctx.WriteString("The url " + string(ctx.URI().RequestURI()) + " does not exist :(\n")
 			check(err)
-			FastHomePage(ctx, fileList, *logCfg.Hostname, strconv.Itoa(*logCfg.Port)) // Simply print some link
+			FastHomePage(ctx, fileList, logCfg.VirtualFiles, *logCfg.Hostname, strconv.Itoa(*logCfg.Port)) // Simply print some link
 			log.Info(tmpChar)
 		}
 	}
-
-	// The gzipHandler will serve a compress request only if the client request it with headers (Content-Type: gzip, deflate)
-	gzipHandler := fasthttp.CompressHandlerLevel(m, fasthttp.CompressBestCompression)            // Compress data before sending (if requested by the client)
-	err := fasthttp.ListenAndServe(*logCfg.Hostname+":"+strconv.Itoa(*logCfg.Port), gzipHandler) // Try to start the server with input "host:port" received in input
-	if err != nil {                                                                              // No luck, connection not successfully. Probably port used ...
-		log.Warn("Port ", *logCfg.Port, " seems used :/")
-		for i := 0; i < 10; i++ {
-			port := strconv.Itoa(utils.Random(8081, 8090)) // Generate a new port to use
-			log.Info("Round ", strconv.Itoa(i), "]No luck! Connecting to anotother random port [@", port, "] ...")
-			*logCfg.Port, err = strconv.Atoi(port) // Updating the datastructure.Configuration with the new port used
-			if err != nil {
-				log.Error("HandleRequests | Unable to parse int [", logCfg.Port, "] | Err: ", err)
-				return
-			}
-			err := fasthttp.ListenAndServe(*logCfg.Hostname+":"+port, gzipHandler) // Trying with the random port generate few step above
-			if err == nil {                                                        // Connection estabileshed!
-				log.Warning("HandleRequests | Connection estabilished @[", *logCfg.Hostname, ":", *logCfg.Port) // Not reached
-				break
-			}
-		}
-	}
-	log.Trace("HandleRequests | STOP")
 }
 
 // FastHomePage is the methods for serve the home page. It print the list of file that you can query with the complete link in order to copy and paste easily
-func FastHomePage(ctx *fasthttp.RequestCtx, fileList []datastructure.LogFileStruct, hostname, port string) {
+func FastHomePage(ctx *fasthttp.RequestCtx, fileList []datastructure.LogFileStruct, virtualFiles []datastructure.VirtualFile, hostname, port string) {
 	log.Trace("FastHomePage | START")
 	ctx.Response.Header.SetContentType("text/plain; charset=utf-8")
 	_, err := ctx.WriteString("Welcome to the GoLog Viewer!\n" + "API List!\n" +
-		"http://" + hostname + ":" + port + "/listAllFile -> Return all file managed in a json format\n" +
-		"http://" + hostname + ":" + port + "/getFile?file=file_name&json=on -> Return the file log lines (optional: json)\n" +
-		"http://" + hostname + ":" + port + "/filterFromFile?file=file_name&filter=toFilter&reverse=on&json=on&ignoreCase=on -> Filter text from the given file (optional: reverse, json, ignoreCase)\n" +
+		"http://" + hostname + ":" + port + "/api/v1/openapi.json -> OpenAPI specification of the versioned API (/api/v1/files, /api/v1/search, /api/v1/config, /api/v1/stats ...), the paths below are kept as aliases\n" +
+		"http://" + hostname + ":" + port + "/ui/ -> Web interface (the browsers are redirected here, use /?plain=on for this page)\n" +
+		"http://" + hostname + ":" + port + "/listAllFile -> Return all file managed in a json format (with the number of lines for every level and the limits applied)\n" +
+		"http://" + hostname + ":" + port + "/getFile?file=file_name&json=on&ansi=strip -> Return the file log lines (optional: json, ansi=keep|strip|html, format=text|ndjson|csv[.gz|.zst] for download)\n" +
+		"http://" + hostname + ":" + port + "/filterFromFile?file=file_name&filter=toFilter&reverse=on&json=on&ignoreCase=on&level=warn+&q=user_id=42 AND latency_ms>500 -> Filter text from the given file, ignoring the ANSI colours (optional: reverse, json, ignoreCase, level, q, ansi, format, limit)\n" +
+		"http://" + hostname + ":" + port + "/aggregate?files=*.log&level=error&bucket=1m&since=1h&groupBy=file&field=latency_ms -> Count the lines in time buckets (optional: files (every file if missing), filter, level, q, bucket, since, until, groupBy, field, percentiles)\n" +
+		"http://" + hostname + ":" + port + "/patterns?file=file_name&top=20 -> Return the most frequent message templates (optional: top, filter, level, q)\n" +
+		"http://" + hostname + ":" + port + "/newPatterns?file=file_name&since=10m -> Return the templates observed for the first time in the last 10 minutes (optional: file, since)\n" +
+		"http://" + hostname + ":" + port + "/alerts -> Return the status (pending, firing, resolved) of the alerts\n" +
+		"http://" + hostname + ":" + port + "/listAlertRules?name=rule_name -> Return the alert rules with the last evaluation/firing (optional: name)\n" +
+		"http://" + hostname + ":" + port + "/createAlertRule -> Create the alert rule received in the (json) body\n" +
+		"http://" + hostname + ":" + port + "/updateAlertRule?name=rule_name -> Replace the alert rule with the one received in the (json) body\n" +
+		"http://" + hostname + ":" + port + "/silenceAlertRule?name=rule_name&duration=1h -> Suppress the notifications of the rule (duration=0 remove the silence)\n" +
+		"http://" + hostname + ":" + port + "/deleteAlertRule?name=rule_name -> Delete the alert rule\n" +
+		"http://" + hostname + ":" + port + "/getAlertRuleHistory?name=rule_name -> Return the evaluation history of the rule\n" +
+		"http://" + hostname + ":" + port + "/correlate?id=request_id&json=on -> Return the lines of every file related to the request id, ordered by time (optional: field, files, json, ansi)\n" +
+		"http://" + hostname + ":" + port + "/diff?left=healthy.log&right=failing.log -> Compare the message templates of two files, or of the same file in two time windows (optional: file, leftSince, leftUntil, rightSince, rightUntil, factor, minCount, filter, level, q)\n" +
 		"http://" + hostname + ":" + port + "/changeLine?line=100&json=on -> Change the number of line printed to 100 (optional: json) \n" +
-		"http://" + hostname + ":" + port + "/getLinePrinted?json=on -> Return the number of line printed for every log (optional: json)\n")
+		"http://" + hostname + ":" + port + "/getLinePrinted?json=on -> Return the number of line printed for every log (optional: json)\n" +
+		"http://" + hostname + ":" + port + "/updateConfig?dryRun=on -> Validate and apply the settings received in the (json) body (MinLinesToPrint, MaxLinesToSearch, Sleep, GCSleep, Include, Exclude, Files), returning the changes (optional: dryRun)\n" +
+		"http://" + hostname + ":" + port + "/metrics -> Return the metrics of the viewer (files, memory, core engine, HTTP requests, live tail subscribers) in the Prometheus text format\n" +
+		"http://" + hostname + ":" + port + "/healthz -> Liveness check, fail (503) when the core engine is stalled (no credentials required)\n" +
+		"http://" + hostname + ":" + port + "/readyz -> Readiness check, fail (503) while loading the files, when the core engine is stalled or every file is failing to read (no credentials required)\n" +
+		"http://" + hostname + ":" + port + "/debug/status -> Return the state of the service (load, core engine rounds) with the errors of every file (permission denied, decompress ...)\n")
 	check(err)
 	var buffer bytes.Buffer // create a buffer for the string content
 
-	for i := 0; i < len(fileList); i++ {
+	for _, i := range ManagedFiles(fileList) {
 		buffer.WriteString("http://" + hostname + ":" + port + "/getFile?file=" + fileList[i].LogFileInfoStruct.Path + "\n") // append data to the buffer
 	}
+	for _, virtualFile := range virtualFiles {
+		buffer.WriteString("http://" + hostname + ":" + port + "/getFile?file=" + virtualFile.Name + " (" + virtualFile.Files + ")\n")
+	}
 	_, err = ctx.Write(buffer.Bytes()) // Print the list of the file in the browser
 	check(err)
 	log.Trace("FastHomePage | STOP")
 }
 
-// ListAllFilesHTTP Return a json list of every file saved in the structure
-func ListAllFilesHTTP(ctx *fasthttp.RequestCtx, fileList []datastructure.LogFileStruct) {
+// ListAllFilesHTTP Return a json list of every file saved in the structure, followed by the virtual files
+func ListAllFilesHTTP(ctx *fasthttp.RequestCtx, fileList []datastructure.LogFileStruct, logCfg *datastructure.Configuration) {
 	log.Trace("ListAllFilesHTTP | START")
 	ctx.Response.Header.SetContentType("application/json; charset=utf-8")
-	tmpStruct := make([]datastructure.LogFileInfoStruct, len(fileList))
+	tmpStruct := make([]datastructure.LogFileInfoStruct, 0, len(fileList))
 	for i := 0; i < len(fileList); i++ {
-		tmpStruct[i] = fileList[i].LogFileInfoStruct
+		if state := fileList[i].State(); !state.Excluded { // Hidden by the include/exclude rules
+			tmpStruct = append(tmpStruct, state.Info)
+		}
+	}
+	for _, virtualFile := range logCfg.VirtualFiles {
+		info := VirtualFileInfo(fileList, logCfg.VirtualFiles, virtualFile)
+		info.Limits = logCfg.Settings.Get().Limits(virtualFile.Name) // Only the lines searched are related to the virtual file
+		tmpStruct = append(tmpStruct, info)
 	}
 	err := json.NewEncoder(ctx).Encode(datastructure.Status{Status: true, Description: "", ErrorCode: "", Data: tmpStruct})
 	check(err)
@@ -188,145 +486,294 @@ func ListAllFilesHTTP(ctx *fasthttp.RequestCtx, fileList []datastructure.LogFile
 }
 
 // FastGetFileHTTP is in charged to find the file related to the INPUT parameter and expose the file over HTTP
-func FastGetFileHTTP(ctx *fasthttp.RequestCtx, fileList []datastructure.LogFileStruct) {
+func FastGetFileHTTP(ctx *fasthttp.RequestCtx, fileList []datastructure.LogFileStruct, logCfg *datastructure.Configuration) {
 	log.Trace("FastGetFileHTTP | START")
 	file := string(ctx.FormValue("file")) // Extracting the "file" INPUT parameter
 	if strings.Compare(file, "") == 0 {
-		ctx.Response.Header.SetContentType("application/json; charset=utf-8")
-		err := json.NewEncoder(ctx).Encode(datastructure.Status{Status: false, Description: "Example: /getFile?file=file_name", ErrorCode: "Parameter not found: file", Data: nil})
-		check(err)
+		WriteError(ctx, datastructure.ErrMissingParameter, missingParameterError("file", "/getFile?file=file_name").Error())
 		log.Error("FastGetFileHTTP without file paramater!")
 		log.Trace("FastGetFileHTTP | STOP")
 		return
 	}
+	mode, err := parser.ParseANSIMode(string(ctx.FormValue("ansi"))) // Extracting the "ansi" INPUT parameter (keep, strip, html)
+	if err != nil {
+		WriteError(ctx, datastructure.ErrInvalidParameter, parameterError("ansi", err).Error())
+		log.Trace("FastGetFileHTTP | STOP")
+		return
+	}
+	jsonOutput, errorCode, err := ParseBoolParameter(ctx, "json") // Extracting the "json" INPUT parameter
+	if err == nil {
+		_, errorCode, err = ParseBoolParameter(ctx, "follow") // Only validated, the clients of the live tail are counted by ObserveRequest
+	}
+	if err != nil {
+		WriteError(ctx, errorCode, err.Error())
+		log.Trace("FastGetFileHTTP | STOP")
+		return
+	}
+	if len(ctx.FormValue("format")) > 0 { // Download the lines as attachment (ndjson, csv, gz, zst)
+		ExportHTTP(ctx, fileList, logCfg, file, query.Filter{}, 0, 0, mode)
+		log.Trace("FastGetFileHTTP | STOP")
+		return
+	}
+	if virtualFile, ok := FindVirtualFile(logCfg.VirtualFiles, file); ok {
+		FastGetVirtualFileHTTP(ctx, fileList, logCfg, virtualFile, mode, jsonOutput)
+		log.Trace("FastGetFileHTTP | STOP")
+		return
+	}
 	for i := 0; i < len(fileList); i++ { // Try to find the file ...
-		if strings.Compare(fileList[i].LogFileInfoStruct.Path, file) == 0 { // File found !
-			dataUncompressed, err := gozstd.Decompress(nil, fileList[i].Data) // Decompress the data
+		if strings.Compare(fileList[i].LogFileInfoStruct.Path, file) != 0 {
+			continue
+		}
+		if state := fileList[i].State(); !state.Excluded { // File found !
+			lines, err := FileLines(file, state.Data)
 			if err != nil {
-				ctx.Response.Header.SetContentType("application/json; charset=utf-8")
-				err := json.NewEncoder(ctx).Encode(datastructure.Status{Status: false, Description: "Unable to decompress file " + file, ErrorCode: "UNABLE_DECOMPRESS", Data: nil})
-				check(err)
-				log.Error("FastGetFileHTTP unable to decompress file " + file)
+				WriteError(ctx, datastructure.ErrInternal, err.Error())
 				log.Trace("FastGetFileHTTP | STOP")
 				return
 			}
-			strJSON := strings.ToLower(string(ctx.FormValue("json")))
-			if strings.Compare(strJSON, "on") == 0 || strings.Compare(strJSON, "true") == 0 { // Checking if the json is on
-				log.Debug("FastGetFileHTTP | Setting json headers and writing the response")
-				ctx.Response.Header.SetContentType("application/json; charset=utf-8")
-				err := json.NewEncoder(ctx).Encode(datastructure.Status{Status: true, Description: "", ErrorCode: "", Data: map[string]string{"Name": fileList[i].FileName, "Data": string(dataUncompressed), "Timestamp": strconv.FormatInt(fileList[i].LogFileInfoStruct.Timestamp, 10)}})
-				check(err)
-			} else {
-				log.Debug("FastGetFileHTTP | Setting plain headers and writing the response")
-				ctx.Response.Header.SetContentType("text/plain; charset=utf-8")
-				_, err := ctx.Write(dataUncompressed)
-				check(err)
-			}
-			log.Info("FastGetFileHTTP | File Found -> ", file, " | Params -> ", ctx)
+			streamFile(ctx, fileList[i].FileName, state.Info.Timestamp, lines, false, mode, jsonOutput)
+			log.Info("FastGetFileHTTP | File Found -> ", file, " | Params -> ", string(ctx.QueryArgs().QueryString()))
 			log.Trace("FastGetFileHTTP | STOP")
 			return
 		}
 	}
-	ctx.Response.Header.SetContentType("application/json; charset=utf-8")
-	err := json.NewEncoder(ctx).Encode(datastructure.Status{Status: false, Description: file, ErrorCode: "File not found", Data: nil})
-	check(err)
+	WriteError(ctx, datastructure.ErrFileNotFound, "file not found: "+file)
 	log.Warn("FastGetFileHTTP | File NOT Found -> ", file, " | Params -> ", string(ctx.QueryArgs().QueryString()))
 	log.Trace("FastGetFileHTTP | STOP")
 }
 
+// FastGetVirtualFileHTTP expose the lines of the members of the virtual file, interleaved by timestamp and prefixed by the name of the origin file
+func FastGetVirtualFileHTTP(ctx *fasthttp.RequestCtx, fileList []datastructure.LogFileStruct, logCfg *datastructure.Configuration, virtualFile datastructure.VirtualFile, mode parser.ANSIMode, jsonOutput bool) {
+	lines, err := ReadVirtualFile(fileList, logCfg.VirtualFiles, virtualFile, logCfg.Parser)
+	if err != nil {
+		WriteError(ctx, datastructure.ErrInternal, err.Error())
+		return
+	}
+	info := VirtualFileInfo(fileList, logCfg.VirtualFiles, virtualFile)
+	streamFile(ctx, virtualFile.Name, info.Timestamp, lines, true, mode, jsonOutput)
+	log.Info("FastGetVirtualFileHTTP | Virtual file -> ", virtualFile.Name, " | Lines -> ", len(lines))
+}
+
+// streamFile write every line of the file in the response while they are sent (chunked transfer). The json response contains the Name,
+// the Timestamp and the Data (the lines) of the file
+func streamFile(ctx *fasthttp.RequestCtx, name string, timestamp int64, lines []LogLine, virtual bool, mode parser.ANSIMode, jsonOutput bool) {
+	var header, footer, newLine string
+	if jsonOutput {
+		ctx.Response.Header.SetContentType("application/json; charset=utf-8")
+		encodedName, err := json.Marshal(name)
+		check(err)
+		header = `{"Status":true,"ErrorCode":"","Description":"","Data":{"Name":` + string(encodedName) + `,"Timestamp":"` + strconv.FormatInt(timestamp, 10) + `","Data":"`
+		footer, newLine = "\"}}\n", `\n`
+	} else {
+		ctx.Response.Header.SetContentType("text/plain; charset=utf-8")
+		newLine = "\n"
+	}
+	ctx.SetBodyStreamWriter(func(w *bufio.Writer) {
+		activeStreams.Add(1)
+		defer activeStreams.Add(-1)
+		_, err := w.WriteString(header)
+		var n int
+		if err == nil {
+			n, err = WriteLines(w, lines, virtual, len(lines), query.Filter{}, 0, mode, jsonOutput)
+		}
+		if err == nil && n > 0 { // The last line of the file is terminated too
+			_, err = w.WriteString(newLine)
+		}
+		if err == nil {
+			_, err = w.WriteString(footer)
+		}
+		if err == nil {
+			err = w.Flush()
+		}
+		if err != nil {
+			log.Warn("streamFile | Response of ", name, " interrupted after ", n, " lines | Err: ", err)
+		}
+	})
+}
+
 // FastFilterFileHTTP is in charge to return the lines of log that contains some text in input and expose the result over HTTP.
 // The purpouse of this method is to extract only the lines that contains "filter" from "file" (input parameter)
 func FastFilterFileHTTP(ctx *fasthttp.RequestCtx, fileList []datastructure.LogFileStruct, logCfg *datastructure.Configuration) {
 	log.Trace("FastFilterFileHTTP | START")
-	file := string(ctx.FormValue("file"))              // Extracting the "file" INPUT parameter
-	filter := string(ctx.FormValue("filter"))          // Extracting the "filter" INPUT parameter
-	_ignoreCase := string(ctx.FormValue("ignoreCase")) // Extracting the "ignoreCase" INPUT parameter
-	var reverse bool                                   // Used for save the search criteria status
-	var ignoreCase bool
-	if strings.Compare(file, "") == 0 || strings.Compare(filter, "") == 0 { // The input parameters are not populated.
-		ctx.Response.Header.SetContentType("application/json; charset=utf-8")
-		err := json.NewEncoder(ctx).Encode(datastructure.Status{Status: false, Description: "/filterFromFile?file=file_name&filter=to_filter", ErrorCode: "Parameter not found: file,filter", Data: nil})
-		check(err)
-		log.Warn("FastFilterFileHTTP | Empty file parameters | Params -> ", string(ctx.QueryArgs().QueryString()))
+	file := string(ctx.FormValue("file")) // Extracting the "file" INPUT parameter
+	filter, errorCode, err := ParseFilterParameters(ctx, logCfg)
+	if err == nil && (strings.Compare(file, "") == 0 || filter.IsEmpty()) { // The input parameters are not populated.
+		err = missingParameterError("file,filter", "/filterFromFile?file=file_name&filter=to_filter&level=warn+&q=user_id=42")
+		errorCode = datastructure.ErrMissingParameter
+	}
+	var mode parser.ANSIMode
+	if err == nil {
+		if mode, err = parser.ParseANSIMode(string(ctx.FormValue("ansi"))); err != nil { // Extracting the "ansi" INPUT parameter (keep, strip, html)
+			err, errorCode = parameterError("ansi", err), datastructure.ErrInvalidParameter
+		}
+	}
+	var jsonOutput bool
+	if err == nil {
+		jsonOutput, errorCode, err = ParseBoolParameter(ctx, "json") // Extracting the "json" INPUT parameter
+	}
+	if err == nil {
+		_, errorCode, err = ParseBoolParameter(ctx, "follow") // Only validated, the clients of the live tail are counted by ObserveRequest
+	}
+	if err != nil {
+		WriteError(ctx, errorCode, err.Error())
+		log.Warn("FastFilterFileHTTP | ", errorCode, " | Params -> ", string(ctx.QueryArgs().QueryString()))
 		log.Trace("FastFilterFileHTTP | STOP !")
 		return
 	}
-	if strings.Compare(_ignoreCase, "on") == 0 || strings.Compare(_ignoreCase, "true") == 0 {
-		ignoreCase = true
-	} else {
-		ignoreCase = false
-	}
-	tmpReverse := strings.ToLower(string(ctx.FormValue("reverse"))) // Check if the user want to search "everything except that"
-	if strings.Compare(tmpReverse, "on") == 0 || strings.Compare(tmpReverse, "true") == 0 {
-		reverse = true
-	} else {
-		reverse = false
+
+	limit, errorCode, err := ParseIntParameter(ctx, "limit", 0, 0) // Extracting the "limit" INPUT parameter (max number of lines returned)
+	if err != nil {
+		WriteError(ctx, errorCode, err.Error())
+		log.Trace("FastFilterFileHTTP | STOP")
+		return
 	}
 
-	strJSON := strings.ToLower(string(ctx.FormValue("json"))) // Extracting the "json" INPUT parameter
+	if len(ctx.FormValue("format")) > 0 { // Download the lines as attachment (ndjson, csv, gz, zst)
+		ExportHTTP(ctx, fileList, logCfg, file, filter, logCfg.Settings.Get().Limits(file).MaxLinesToSearch, limit, mode)
+		log.Trace("FastFilterFileHTTP | STOP")
+		return
+	}
 
-	filteredData := FastFilterFilteHTTPEngine(fileList, *logCfg.MaxLinesToSearch, &file, &filter, reverse, ignoreCase)
-	if strings.Compare(strJSON, "on") == 0 || strings.Compare(strJSON, "true") == 0 { // Checking if the json is on
-		log.Trace("FastFilterFileHTTP | Setting json headers and writing the response")
+	lines, found, err := ReadLines(fileList, logCfg.VirtualFiles, file, logCfg.Parser)
+	if !found {
+		WriteError(ctx, datastructure.ErrFileNotFound, "file not found: "+file)
+		log.Warn("FastFilterFileHTTP | File NOT Found -> ", file, " | Params -> ", string(ctx.QueryArgs().QueryString()))
+		log.Trace("FastFilterFileHTTP | STOP")
+		return
+	}
+	if err != nil {
+		WriteError(ctx, datastructure.ErrInternal, err.Error())
+		log.Trace("FastFilterFileHTTP | STOP")
+		return
+	}
+	_, virtual := FindVirtualFile(logCfg.VirtualFiles, file)
+	maxLinesToSearch := logCfg.Settings.Get().Limits(file).MaxLinesToSearch
+	if jsonOutput {
 		ctx.Response.Header.SetContentType("application/json; charset=utf-8")
-		err := json.NewEncoder(ctx).Encode(datastructure.Status{Status: true, Description: "", ErrorCode: "", Data: filteredData})
-		check(err)
 	} else {
-		log.Trace("FastFilterFileHTTP | Setting plain headers and writing the response")
 		ctx.Response.Header.SetContentType("text/plain; charset=utf-8")
-		_, err := ctx.WriteString(filteredData)
-		check(err)
 	}
-	log.Info("FastFilterFileHTTP | Hit with -> ", filter, " | ", file, " | Params -> ", string(ctx.QueryArgs().QueryString()))
+	params := string(ctx.QueryArgs().QueryString())
+	// The lines are written while they are found (chunked transfer), the request context can't be used inside the writer
+	ctx.SetBodyStreamWriter(func(w *bufio.Writer) {
+		activeStreams.Add(1)
+		defer activeStreams.Add(-1)
+		var writeErr error
+		if jsonOutput { // The json response is the same of the other API, the lines are streamed inside the Data string
+			_, writeErr = w.WriteString(`{"Status":true,"ErrorCode":"","Description":"","Data":"`)
+		}
+		var n int
+		if writeErr == nil {
+			n, writeErr = WriteLines(w, lines, virtual, maxLinesToSearch, filter, limit, mode, jsonOutput)
+		}
+		if writeErr == nil && jsonOutput {
+			_, writeErr = w.WriteString("\"}\n")
+		}
+		if writeErr == nil {
+			writeErr = w.Flush()
+		}
+		if writeErr != nil {
+			log.Warn("FastFilterFileHTTP | Response interrupted after ", n, " lines | Err: ", writeErr)
+		}
+		log.Info("FastFilterFileHTTP | Hit with -> ", filter.Text, " | ", file, " | Lines -> ", n, " | Params -> ", params)
+	})
 	log.Trace("FastFilterFileHTTP | STOP")
 }
 
-// FastFilterFilteHTTPEngine is a wrapper for the core logic method
-func FastFilterFilteHTTPEngine(fileList []datastructure.LogFileStruct, maxLinesToSearch int, file *string, filter *string, reverse, ignoreCase bool) string {
-	log.Trace("FastFilterFilteHTTPEngine | START")
-	for i := 0; i < len(fileList); i++ {
-		if strings.Compare(fileList[i].LogFileInfoStruct.Path, *file) == 0 { // Try to find the file
-			_data, err := gozstd.Decompress(nil, fileList[i].Data) // Decompress the data
-			if err != nil {
-				log.Error("FastFilterFilteHTTPEngine | Unable to extract data ...")
-			} else {
-				if ignoreCase {
-					_data = bytes.ToLower(_data)
-				}
-				array := bytes.Split(_data, []byte("\n"))
-				var startPoint int
-				if len(array) >= maxLinesToSearch {
-					startPoint = len(array) - maxLinesToSearch
-				} else {
-					startPoint = 0
-				}
-				var filtered []string
-				log.Info("FastFilterFilteHTTPEngine | Starting from: ", i)
-				if !reverse {
-					for i := startPoint; i < len(array); i++ {
-						if bytes.Contains(array[i], []byte(*filter)) {
-							filtered = append(filtered, string(array[i]))
-						}
-					}
-				} else {
-					for i := startPoint; i < len(array); i++ {
-						if !bytes.Contains(array[i], []byte(*filter)) {
-							filtered = append(filtered, string(array[i]))
-						}
-					}
-				}
-				filteredData := arrayutils.JoinStrings(filtered, "\n")
-
-				// log.Debug("FastFilterFilteHTTPEngine | File found! | Filtering " + *filter + " from " + fileList[i].LogFileInfoStruct.Path)
-				// filteredData := utils.FilterFromFile(fileList[i].LogFileInfoStruct.Path, maxLinesToSearch, *filter, reverse)
-				// log.Debug("FastFilterFilteHTTPEngine | Filtered data -> " + filteredData)
-				return filteredData
+// WriteLines write the lines that satisfy the filter (see FastFilterFilteHTTPEngine) separated by a new line, with jsonOutput as the
+// content of a json string. The lines are flushed every 1000, so the client receive them while they are found. The number of lines
+// written is returned, the search stop at the first error
+func WriteLines(w io.Writer, lines []LogLine, virtual bool, maxLinesToSearch int, filter query.Filter, limit int, mode parser.ANSIMode, jsonOutput bool) (int, error) {
+	buffer := bufio.NewWriter(w)
+	var err error
+	n := FastFilterFilteHTTPEngine(lines, virtual, maxLinesToSearch, filter, limit, func(i int, line []byte) bool {
+		line = mode.Apply(line)
+		if jsonOutput {
+			if i > 0 {
+				_, err = buffer.WriteString(`\n`)
+			}
+			if err == nil {
+				err = writeJSONString(buffer, line)
+			}
+		} else {
+			if i > 0 {
+				err = buffer.WriteByte('\n')
 			}
+			if err == nil {
+				_, err = buffer.Write(line)
+			}
+		}
+		if err == nil && (i+1)%1000 == 0 { // Send the lines to the client
+			err = buffer.Flush()
+		}
+		return err == nil
+	})
+	if err == nil {
+		err = buffer.Flush()
+	}
+	return n, err
+}
 
+// writeJSONString write the data as the content of a json string (without the quotes)
+func writeJSONString(w *bufio.Writer, data []byte) error {
+	encoded, err := json.Marshal(string(data))
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(encoded[1 : len(encoded)-1])
+	return err
+}
+
+// ParseFilterParameters extract the search criteria (filter, reverse, ignoreCase, level, q) from the request.
+// In case of error, the error code related to the invalid parameter is returned
+func ParseFilterParameters(ctx *fasthttp.RequestCtx, logCfg *datastructure.Configuration) (query.Filter, string, error) {
+	var filter query.Filter
+	var err error
+	filter.Text = string(ctx.FormValue("filter")) // Extracting the "filter" INPUT parameter
+	filter.Parser = logCfg.Parser
+	var errorCode string
+	if filter.IgnoreCase, errorCode, err = ParseBoolParameter(ctx, "ignoreCase"); err != nil { // Extracting the "ignoreCase" INPUT parameter
+		return filter, errorCode, err
+	}
+	if filter.Reverse, errorCode, err = ParseBoolParameter(ctx, "reverse"); err != nil { // Check if the user want to search "everything except that"
+		return filter, errorCode, err
+	}
+	if filter.Level, err = parser.ParseLevelFilter(string(ctx.FormValue("level"))); err != nil { // Extracting the "level" INPUT parameter (warn+, error, info,debug)
+		return filter, datastructure.ErrInvalidParameter, parameterError("level", err)
+	}
+	if filter.Query, err = query.Parse(string(ctx.FormValue("q"))); err != nil { // Extracting the "q" INPUT parameter (user_id=42 AND latency_ms>500)
+		return filter, datastructure.ErrInvalidParameter, parameterError("q", err)
+	}
+	return filter, "", nil
+}
+
+// FastFilterFilteHTTPEngine call emit for every line that satisfy the filter, searching among the last maxLinesToSearch lines.
+// The lines of the virtual files are prefixed by the name of the origin file. The search stop when emit return false or when
+// limit lines (if positive) are found. The number of lines emitted is returned
+func FastFilterFilteHTTPEngine(lines []LogLine, virtual bool, maxLinesToSearch int, filter query.Filter, limit int, emit func(i int, line []byte) bool) int {
+	log.Trace("FastFilterFilteHTTPEngine | START")
+	if len(lines) > maxLinesToSearch {
+		lines = lines[len(lines)-maxLinesToSearch:]
+	}
+	var n int
+	for _, line := range lines {
+		if limit > 0 && n >= limit {
+			break
+		}
+		if !filter.Match(line.File, line.Text) {
+			continue
+		}
+		text := line.Text
+		if virtual {
+			text = []byte(FormatVirtualLine(line))
+		}
+		n++
+		if !emit(n-1, text) {
+			break
 		}
 	}
-	log.Warn("FastFilterFilteHTTPEngine | File not found :/ | STOP")
-	return ""
+	log.Trace("FastFilterFilteHTTPEngine | STOP")
+	return n
 }
 
 // FastChangeLineHTTP API for change the line printed @runtime
@@ -336,22 +783,28 @@ func FastChangeLineHTTP(ctx *fasthttp.RequestCtx, logCfg *datastructure.Configur
 	line := string(ctx.FormValue("line"))
 	if strings.Compare(line, "") == 0 {
 		log.Error("FastChangeLineHTTP | Request failed! Missing parameter! | Request -> ", ctx)
-		err := json.NewEncoder(ctx).Encode(datastructure.Status{Status: false, Description: "/changeLine?line=200", ErrorCode: "Parameter not found; line", Data: logCfg})
-		check(err)
+		WriteError(ctx, datastructure.ErrMissingParameter, missingParameterError("line", "/changeLine?line=200").Error())
 		log.Trace("FastChangeLineHTTP | STOP")
 		return
 	}
-	n, err := strconv.Atoi(line) // Convert INPUT string to int
+	n, errorCode, err := ParseIntParameter(ctx, "line", 0, 1) // Convert INPUT string to int
 	if err != nil {
 		log.Error("FastChangeLineHTTP | Request failed! Int conversion failed :(!!", err)
-		err := json.NewEncoder(ctx).Encode(datastructure.Status{Status: false, Description: err.Error(), ErrorCode: "Int conversion failed", Data: logCfg})
-		check(err)
+		WriteError(ctx, errorCode, err.Error())
 		log.Trace("FastChangeLineHTTP | STOP")
 		return
 	}
 
-	*logCfg.MinLinesToPrint = n // Apply the datastructure.Configuration
-	err = json.NewEncoder(ctx).Encode(datastructure.Status{Status: true, Description: "datastructure.Configuration changed to " + line, ErrorCode: "", Data: logCfg})
+	_, err = logCfg.Settings.Update(func(settings *config.Settings) error { // Apply the new settings
+		settings.MinLinesToPrint = n
+		return nil
+	}, false)
+	if err != nil {
+		WriteError(ctx, datastructure.ErrInvalidParameter, err.Error())
+		log.Trace("FastChangeLineHTTP | STOP")
+		return
+	}
+	err = json.NewEncoder(ctx).Encode(datastructure.Status{Status: true, Description: "Lines kept in memory for every file changed to " + strconv.Itoa(n), ErrorCode: "", Data: ActiveConfiguration(logCfg)})
 	check(err)
 	log.Warn("FastChangeLineHTTP | Request succed -> Changing to " + strconv.Itoa(n))
 	log.Trace("FastChangeLineHTTP | STOP")
@@ -361,7 +814,7 @@ func FastChangeLineHTTP(ctx *fasthttp.RequestCtx, logCfg *datastructure.Configur
 func FastGetLinePrintedHTTP(ctx *fasthttp.RequestCtx, logCfg *datastructure.Configuration) {
 	log.Trace("FastGetLinePrintedHTTP | START")
 	ctx.Response.Header.SetContentType("application/json; charset=utf-8")
-	err := json.NewEncoder(ctx).Encode(datastructure.Status{Status: true, Description: "", ErrorCode: "", Data: logCfg})
+	err := json.NewEncoder(ctx).Encode(datastructure.Status{Status: true, Description: "", ErrorCode: "", Data: ActiveConfiguration(logCfg)})
 	check(err)
 	log.Trace("FastGetLinePrintedHTTP | STOP")
 }
@@ -376,68 +829,78 @@ func fastBenchmarkHTTP(ctx *fasthttp.RequestCtx) {
 
 // InitConfigurationData is in charge to init the various datastructure.Configuration data.
 // It runs only once for load the data and instantiate datastructure.Configuration options.
-func InitConfigurationData() datastructure.Configuration {
+// The configuration file is returned too, it's the baseline of the changes applied by the reload
+func InitConfigurationData() (datastructure.Configuration, ConfigFile) {
 	log.Trace("Initdatastructure.ConfigurationData | START")
-	var (
-		logPath  string // User INPUT folder
-		numLines int    // Number of line to print
-		maxLines int    // Max lines used for search the content
-		port     int    // Port to bind the service (random from 8080/8090 if used)
-		host     string // Host to bind the service (127.0.0.1 as default)
-		sleep    int    // Seconds for wait until check the log another time
-		gcSleep  int    // Number of minutes to sleep beetween every forced GC cycle
-	)
-
-	logPath, numLines, maxLines, port, host, sleep, gcSleep = VerifyCommandLineInput() // Function for validate command line INPUT
-	if strings.Compare(logPath[len(logPath)-1:], "/") != 0 {                           // Be sure that the last character is an '/'
+	cfg, configFile := VerifyCommandLineInput() // Function for validate command line INPUT
+	logPath, port, host, patterns, alerts, field, snapshot, snapshotInterval := cfg.Path, cfg.Port, cfg.Hostname, cfg.Patterns, cfg.Alerts, cfg.CorrelationField, cfg.Snapshot, cfg.SnapshotInterval
+	shutdownTimeout := cfg.ShutdownTimeout
+	if strings.Compare(logPath[len(logPath)-1:], "/") != 0 { // Be sure that the last character is an '/'
 		logPath += "/" // Append the character needed by the directory if not present
 	}
+	if port == 0 { // If no port selected, generate select a random one from 8080 to 8090
+		port = utils.Random(8081, 8090)
+		log.Error("Initdatastructure.ConfigurationData | Use -port 8081 to bind the service on the port 8081 | Binded @", port)
+	}
+	lineParser, err := cfg.LoadParser()
+	if err != nil {
+		log.Fatal("Initdatastructure.ConfigurationData | Unable to load the patterns from ", patterns, " | Err: ", err)
+	}
+	alertEngine, err := cfg.LoadAlertEngine(lineParser)
+	if err != nil {
+		log.Fatal("Initdatastructure.ConfigurationData | Unable to load the alert rules from ", alerts, " | Err: ", err)
+	}
+	logMetrics, err := metrics.NewEngine(cfg.Metrics, lineParser, registry) // The metrics of the rules are exposed with the ones of the viewer
+	if err != nil {
+		log.Fatal("Initdatastructure.ConfigurationData | Unable to load the metrics rules | Err: ", err)
+	}
+	settings, err := config.NewStore(cfg.Settings)
+	if err != nil {
+		log.Fatal("Initdatastructure.ConfigurationData | Invalid configuration | Err: ", err)
+	}
+	auth := &config.Auth{}
+	auth.Set(cfg.Auth)
 	// Init a new datastructure.Configuration
 	log.Trace("Initdatastructure.ConfigurationData | STOP")
-	return datastructure.Configuration{Path: &logPath, MinLinesToPrint: &numLines, MaxLinesToSearch: &maxLines, Port: &port, Hostname: &host, Sleep: &sleep, GCSleep: &gcSleep}
+	return datastructure.Configuration{Path: &logPath, Port: &port, Hostname: &host, Patterns: &patterns, Alerts: &alerts, CorrelationField: &field, ConfigFile: &configFile,
+		Snapshot: &snapshot, SnapshotInterval: &snapshotInterval, ShutdownTimeout: &shutdownTimeout,
+		VirtualFiles: cfg.VirtualFiles, Parser: lineParser, AlertEngine: alertEngine, LogMetrics: logMetrics, Health: health.NewMonitor(time.Now()), Settings: settings, Auth: auth}, cfg
 }
 
-// VerifyCommandLineInput verify about the INPUT parameter passed as arg[]
-func VerifyCommandLineInput() (string, int, int, int, string, int, int) {
+// VerifyCommandLineInput verify about the INPUT parameter passed as arg[], merged with the configuration file (-config).
+// The configuration and the path of the configuration file are returned
+func VerifyCommandLineInput() (ConfigFile, string) {
 	log.Trace("VerifyCommandLineInput | START")
-	path := flag.String("path", "", "Log folder (MANDATORY PARAMETER)")
-	linesFlag := flag.Int("lines", 200, "Lines to filter")
-	maxLines := flag.Int("maxlines", 100000, "Max lines used for filter")
-	port := flag.Int("port", 8080, "Port to bind the service")
-	host := flag.String("host", "localhost", "Host to bind the service")
-	sleep := flag.Int("sleep", 15, "Seconds for wait until another iteration")
-	gcSleep := flag.Int("gcSleep", 5, "Number of minutes to sleep beetween every forced GC cycle")
+	configFile := flag.String("config", "", "Json configuration file, the flags set on the command line override it (reloaded on SIGHUP or when modified)")
+	flag.String("path", "", "Log folder (MANDATORY PARAMETER)")
+	flag.Int("lines", 200, "Lines to filter")
+	flag.Int("maxlines", 100000, "Max lines used for filter")
+	flag.Int("port", 8080, "Port to bind the service")
+	flag.String("host", "localhost", "Host to bind the service")
+	flag.Int("sleep", 15, "Seconds for wait until another iteration")
+	flag.Int("gcSleep", 5, "Number of minutes to sleep beetween every forced GC cycle")
+	flag.String("patterns", "", "Json file that contains the custom grok patterns and the related files")
+	flag.String("correlationField", "trace_id", "Field that contains the correlation id of the requests")
+	flag.String("virtual", "", "Virtual files that merge other files by timestamp (app.log=app-*.log;edge=gateway.log,lb.log)")
+	flag.String("alerts", "", "Json file that contains the alert rules and the webhooks to notify, the rules managed by the API are saved here (default alerts.json next to the -config file)")
+	flag.String("snapshot", "", "File used for save the state of the files (lines in memory, offsets, templates), loaded at startup for avoid to read again every file")
+	flag.Int("snapshotInterval", 5, "Number of minutes among every save of the snapshot")
+	flag.Int("shutdownTimeout", 30, "Seconds to wait the requests in progress (downloads, searches) on shutdown (SIGTERM, SIGINT)")
 	flag.Parse()
-	if strings.Compare(*path, "") == 0 { // Verify that "path" (INPUT parameter) is populated
+	if *configFile == "" && strings.Compare(flag.Lookup("path").Value.String(), "") == 0 { // Verify that "path" (INPUT parameter) is populated
 		flag.PrintDefaults() // Exit status 2, bye bye Sir
 		log.Fatal("Start without -path parameter :/")
 	}
 	log.Trace("VerifyCommandLineInput | Starting command line input validation ..")
-	if utils.IsDir(*path) { // Be sure that the INPUT directory exist and parse the lines
-		// #TODO: these check are unusefull, due to the default value assigned. Are just a template for a future "input validation methods"
-		if *linesFlag == 0 { // If no lines provided set 1000 as standard output lines
-			*linesFlag = 1000
-			log.Warn("VerifyCommandLineInput | Use -lines 2000 if you want to choose to print 2000 lines")
-		}
-		if *maxLines == 0 { // If no lines provided select 1000 as default search lines for text
-			*maxLines = 1000000
-			log.Warn("VerifyCommandLineInput | Use -maxlines 1000000 to choose search the text among 1000000 lines ")
-		}
-		if *port == 0 { // If no port selected, generate select a random one from 8080 to 8090
-			*port = utils.Random(8081, 8090)
-			log.Error("VerifyCommandLineInput | Use -port 8081 to bind the service on the port 8081 | Binded @", *port)
-		}
-		if stringutils.IsBlank(*host) {
-			*host = "localhost" //if no host provided set localhost
-			log.Error("VerifyCommandLineInput | Use -host localhost for bind the service to 127.0.0.1 | Binded @", *host)
-		}
-		log.Info("INPUT folder: ", *path, " | Lines to print: ", strconv.Itoa(*linesFlag), " | Max line to filter: ", strconv.Itoa(*maxLines),
-			" | Port: ", *port, " | Host: ", *host, " | Sleep: ", *sleep, " | GCSleep: ", *gcSleep)
-		log.Trace("VerifyCommandLineInput | STOP")
-		return *path, *linesFlag, *maxLines, *port, *host, *sleep, *gcSleep
-	}
-	log.Fatal("VerifyCommandLineInput | ERROR: No folder found like ", *path)
-	return "", 0, 0, 0, "", 0, 0 // Unuseful, Fatal will call os.Exit(1)
+	cfg, err := ReadConfiguration(*configFile)
+	if err != nil {
+		log.Fatal("VerifyCommandLineInput | Invalid configuration ", *configFile, " | Err: ", err)
+	}
+	log.Info("INPUT folder: ", cfg.Path, " | Lines to print: ", cfg.MinLinesToPrint, " | Max line to filter: ", cfg.MaxLinesToSearch, " | Port: ", cfg.Port, " | Host: ", cfg.Hostname,
+		" | Sleep: ", cfg.Sleep, " | GCSleep: ", cfg.GCSleep, " | Patterns: ", cfg.Patterns, " | Alerts: ", cfg.Alerts, " | CorrelationField: ", cfg.CorrelationField,
+		" | Virtual: ", cfg.VirtualFiles, " | Snapshot: ", cfg.Snapshot, " | Config: ", *configFile)
+	log.Trace("VerifyCommandLineInput | STOP")
+	return cfg, *configFile
 }
 
 // InitLogFileData Init the log file. It runs only once for load the data and instantiate the array of logfile
@@ -466,26 +929,67 @@ func InitLogFileData(logCfg *datastructure.Configuration) []datastructure.LogFil
 	}
 
 	log.Info("List of file in logpath -> ", filesList, " | Number of files -> ", len(filesList))
-	filesLen := len(filesList)
-	logList = make([]datastructure.LogFileStruct, filesLen) // Allocate an array of LogFileStruct
+	logList = make([]datastructure.LogFileStruct, len(filesList)) // Allocate an array of LogFileStruct
+	settings := logCfg.Settings.Get()
+	for i := range filesList {
+		tmpname := strings.Split(filesList[i], "/")   // Tokenize the string by the /
+		logList[i].FileName = tmpname[len(tmpname)-1] // Extract only the Name of the file (latest element after "/")
+		logList[i].LogFileInfoStruct.Path = filesList[i]
+		logList[i].LogFileInfoStruct.Levels = parser.CountLevels(nil)
+		logList[i].Excluded = !settings.Managed(filesList[i])
+	}
+	logCfg.Health.Loading(len(logList))
+	log.Debug("InitLogFileData | STOP")
+	return logList
+}
+
+// LoadLogFileData load the lines of the managed files, restoring the state saved in the snapshot (if any). The end of the load is
+// registered in the health monitor, the service is ready after it
+func LoadLogFileData(logList []datastructure.LogFileStruct, logCfg *datastructure.Configuration) {
+	log.Debug("LoadLogFileData | START")
+	start := time.Now()
+	filesLen := len(logList)
 	var wg sync.WaitGroup
 	// Use only 64 threads for avoid 'too many open files'
 	semaphore := make(chan struct{}, 128)
+	settings := logCfg.Settings.Get()
+	var snapshot map[string]FileSnapshot // State of the files saved before the restart
+	if *logCfg.Snapshot != "" {
+		var err error
+		if snapshot, err = LoadSnapshot(*logCfg.Snapshot); err != nil && !os.IsNotExist(err) {
+			log.Warn("LoadLogFileData | Unable to load the snapshot, every file will be read | Err: ", err)
+		}
+	}
+	var restored int32
 	wg.Add(filesLen)
 	for i := 0; i < filesLen; i++ { // Populate with the data
 		go func(i int) {
 			semaphore <- struct{}{}
 			defer func() { <-semaphore }()
 			defer wg.Done()
-			tmpname := strings.Split(filesList[i], "/")   // Tokenize the string by the /
-			logList[i].FileName = tmpname[len(tmpname)-1] // Extract only the Name of the file (latest element after "/")
-			logList[i].LogFileInfoStruct.Path = filesList[i]
-			logList[i].Data = utils.ReadFile(filesList[i], *logCfg.MinLinesToPrint)
-			logList[i].LogFileInfoStruct.Timestamp = fileutils.GetFileModification(filesList[i])
+			defer logCfg.Health.FileLoaded()
+			logList[i].Lock() // The API read the state of the file while it's loaded
+			defer logList[i].Unlock()
+			path := logList[i].LogFileInfoStruct.Path
+			logList[i].Excluded = !settings.Managed(path)
+			if logList[i].Excluded {
+				ExcludeLogFile(&logList[i])
+			} else if saved, ok := snapshot[path]; ok && RestoreLogFile(&logList[i], saved, settings.Limits(path)) {
+				atomic.AddInt32(&restored, 1)
+				return
+			} else {
+				ReadLogFile(&logList[i], settings.Limits(path))
+			}
+			InitIngestion(&logList[i])
+			logList[i].LogFileInfoStruct.Timestamp = fileutils.GetFileModification(path)
 		}(i)
 		//fmt.Printf("\r %d/%d - %s", i, filesLen, logList[i].FileName)
 	}
 	wg.Wait()
-	log.Debug("InitLogFileData | STOP")
-	return logList
+	if snapshot != nil {
+		log.Info("LoadLogFileData | ", restored, " files restored from the snapshot ", *logCfg.Snapshot)
+	}
+	logCfg.Health.Loaded(time.Now())
+	log.Info("LoadLogFileData | ", filesLen, " files loaded in ", time.Since(start))
+	log.Debug("LoadLogFileData | STOP")
 }
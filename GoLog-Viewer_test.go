@@ -0,0 +1,381 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alessiosavi/GoLog-Viewer/alert"
+	"github.com/alessiosavi/GoLog-Viewer/config"
+	"github.com/alessiosavi/GoLog-Viewer/datastructure"
+	"github.com/alessiosavi/GoLog-Viewer/drain"
+	"github.com/alessiosavi/GoLog-Viewer/parser"
+	"github.com/alessiosavi/GoLog-Viewer/query"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/gozstd"
+)
+
+// specialLines are lines that have to be escaped in the json output
+var specialLines = []string{
+	`say "hello"`,
+	`C:\logs\app.log \n is not a new line`,
+	"tab\tbell\x07 nul\x00 end",
+	"\x1b[31mERROR\x1b[0m coloured",
+	"unicode è ✓ \u2028 separator",
+	"</script><script>alert(1)</script>",
+}
+
+// chunks record every write received
+type chunks [][]byte
+
+func (c *chunks) Write(p []byte) (int, error) {
+	*c = append(*c, append([]byte(nil), p...))
+	return len(p), nil
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("connection closed")
+}
+
+func logLines(file string, texts ...string) []LogLine {
+	var lines []LogLine
+	for i, text := range texts {
+		lines = append(lines, LogLine{File: file, Number: i + 1, Text: []byte(text)})
+	}
+	return lines
+}
+
+func TestWriteLines(t *testing.T) {
+	lines := logLines("/var/log/app.log", "INFO started", "ERROR disk full", "INFO request", "ERROR timeout", "INFO stopped")
+	tests := []struct {
+		name             string
+		virtual          bool
+		maxLinesToSearch int
+		filter           query.Filter
+		limit            int
+		mode             parser.ANSIMode
+		expected         string
+	}{
+		{name: "every line", maxLinesToSearch: 10, expected: "INFO started\nERROR disk full\nINFO request\nERROR timeout\nINFO stopped"},
+		{name: "filter", maxLinesToSearch: 10, filter: query.Filter{Text: "ERROR"}, expected: "ERROR disk full\nERROR timeout"},
+		{name: "limit", maxLinesToSearch: 10, limit: 2, expected: "INFO started\nERROR disk full"},
+		{name: "limit of the filter", maxLinesToSearch: 10, filter: query.Filter{Text: "INFO"}, limit: 2, expected: "INFO started\nINFO request"},
+		{name: "lines searched", maxLinesToSearch: 2, expected: "ERROR timeout\nINFO stopped"},
+		{name: "virtual", maxLinesToSearch: 1, virtual: true, expected: "[app.log] INFO stopped"},
+		{name: "nothing found", maxLinesToSearch: 10, filter: query.Filter{Text: "WARN"}, expected: ""},
+	}
+	for _, test := range tests {
+		var buffer bytes.Buffer
+		n, err := WriteLines(&buffer, lines, test.virtual, test.maxLinesToSearch, test.filter, test.limit, test.mode, false)
+		if err != nil || buffer.String() != test.expected || n != len(strings.Split(test.expected, "\n")) && test.expected != "" {
+			t.Errorf("%s: WriteLines() = %q, %d (%v), expected %q", test.name, buffer.String(), n, err, test.expected)
+		}
+	}
+}
+
+func TestWriteLinesJSON(t *testing.T) {
+	lines := logLines("/var/log/app.log", specialLines...)
+	for _, mode := range []parser.ANSIMode{parser.ANSIKeep, parser.ANSIStrip} {
+		var buffer bytes.Buffer
+		if _, err := WriteLines(&buffer, lines, false, len(lines), query.Filter{}, 0, mode, true); err != nil {
+			t.Fatal(err)
+		}
+		var decoded string
+		if err := json.Unmarshal([]byte(`"`+buffer.String()+`"`), &decoded); err != nil {
+			t.Fatalf("invalid json string %q: %v", buffer.String(), err)
+		}
+		expected := strings.Join(specialLines, "\n")
+		if mode == parser.ANSIStrip {
+			expected = string(parser.StripANSI([]byte(expected)))
+		}
+		if decoded != expected {
+			t.Errorf("decoded %q, expected %q", decoded, expected)
+		}
+	}
+}
+
+func TestWriteLinesStream(t *testing.T) {
+	texts := make([]string, 2500)
+	for i := range texts {
+		texts[i] = "line " + strconv.Itoa(i)
+	}
+	var written chunks
+	n, err := WriteLines(&written, logLines("/var/log/app.log", texts...), false, len(texts), query.Filter{}, 0, parser.ANSIKeep, false)
+	if err != nil || n != len(texts) {
+		t.Fatalf("WriteLines() = %d (%v), expected %d", n, err, len(texts))
+	}
+	if len(written) < 3 || len(written[0]) > 4096 {
+		t.Errorf("%d writes (the first of %d bytes), expected the lines sent while they are found", len(written), len(written[0]))
+	}
+	if joined := string(bytes.Join(written, nil)); joined != strings.Join(texts, "\n") {
+		t.Errorf("unexpected output %q", joined)
+	}
+	// The search stop when the client is gone
+	if n, err := WriteLines(failingWriter{}, logLines("/var/log/app.log", texts...), false, len(texts), query.Filter{}, 0, parser.ANSIKeep, false); err == nil || n > 1000 {
+		t.Errorf("WriteLines() = %d (%v), expected an error before the first 1000 lines", n, err)
+	}
+}
+
+// testConfiguration return the configuration of the viewer with the default settings
+func testConfiguration(t *testing.T) *datastructure.Configuration {
+	settings, err := config.NewStore(config.Settings{MinLinesToPrint: 100, MaxLinesToSearch: 100, Sleep: 1, GCSleep: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &datastructure.Configuration{Settings: settings, VirtualFiles: []datastructure.VirtualFile{{Name: "all", Files: "*.log"}}}
+}
+
+func TestFastGetFileHTTP(t *testing.T) {
+	fileList := []datastructure.LogFileStruct{logFile("/var/log/app.log", specialLines...)}
+	fileList[0].LogFileInfoStruct.Timestamp = 1704189600
+	logCfg := testConfiguration(t)
+	tests := []struct {
+		file     string
+		name     string
+		expected string
+	}{
+		{file: "/var/log/app.log", name: "app.log", expected: strings.Join(specialLines, "\n") + "\n"},
+		{file: "all", name: "all", expected: "[app.log] " + strings.Join(specialLines, "\n[app.log] ") + "\n"},
+	}
+	for _, test := range tests {
+		var ctx fasthttp.RequestCtx
+		ctx.Request.SetRequestURI("/getFile?json=true&file=" + test.file)
+		FastGetFileHTTP(&ctx, fileList, logCfg)
+		var status struct {
+			Status bool
+			Data   map[string]string
+		}
+		if err := json.Unmarshal(ctx.Response.Body(), &status); err != nil {
+			t.Fatalf("%s: invalid json %q: %v", test.file, ctx.Response.Body(), err)
+		}
+		if !status.Status || status.Data["Name"] != test.name || status.Data["Data"] != test.expected || status.Data["Timestamp"] == "" {
+			t.Errorf("%s: unexpected response %+v", test.file, status)
+		}
+
+		ctx = fasthttp.RequestCtx{}
+		ctx.Request.SetRequestURI("/getFile?file=" + test.file)
+		FastGetFileHTTP(&ctx, fileList, logCfg)
+		if body := string(ctx.Response.Body()); body != test.expected || !strings.HasPrefix(string(ctx.Response.Header.ContentType()), "text/plain") {
+			t.Errorf("%s: unexpected body %q", test.file, body)
+		}
+	}
+	var ctx fasthttp.RequestCtx
+	ctx.Request.SetRequestURI("/getFile?json=true&file=/var/log/app.log")
+	FastGetFileHTTP(&ctx, fileList, logCfg)
+	if !strings.Contains(string(ctx.Response.Body()), `"Timestamp":"1704189600"`) {
+		t.Errorf("unexpected response %q", ctx.Response.Body())
+	}
+}
+
+func TestFastFilterFileHTTP(t *testing.T) {
+	fileList := []datastructure.LogFileStruct{logFile("/var/log/app.log", append([]string{"INFO not found"}, specialLines...)...)}
+	logCfg := testConfiguration(t)
+	var ctx fasthttp.RequestCtx
+	ctx.Request.SetRequestURI("/filterFromFile?json=true&file=/var/log/app.log&filter=INFO&reverse=true&limit=4")
+	FastFilterFileHTTP(&ctx, fileList, logCfg)
+	var status datastructure.Status
+	if err := json.Unmarshal(ctx.Response.Body(), &status); err != nil {
+		t.Fatalf("invalid json %q: %v", ctx.Response.Body(), err)
+	}
+	if expected := strings.Join(specialLines[:4], "\n"); !status.Status || status.Data != expected {
+		t.Errorf("unexpected response %+v, expected the data %q", status, expected)
+	}
+}
+
+func TestCheckFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	write := func(prefix string) { // Same size and modification time, only a new read load the new lines
+		var lines []string
+		for i := 1; i <= 10; i++ {
+			lines = append(lines, prefix+strconv.Itoa(i%10))
+		}
+		if err := ioutil.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		modified := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+		if err := os.Chtimes(path, modified, modified); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("first ")
+	fileList := []datastructure.LogFileStruct{logFile(path)}
+	fileList[0].Templates = drain.NewHistory()
+	logCfg := testConfiguration(t)
+	base := config.Settings{MinLinesToPrint: 5, MaxLinesToSearch: 100, Sleep: 60, GCSleep: 1}
+	now := time.Now()
+
+	lines := func() string {
+		var texts []string
+		for _, line := range parser.SplitLines(mustDecompress(t, fileList[0].Data)) {
+			texts = append(texts, string(line))
+		}
+		return strings.Join(texts, ",")
+	}
+	if !CheckFile(&fileList[0], logCfg, base, now) || lines() != "first 6,first 7,first 8,first 9,first 0" {
+		t.Fatalf("unexpected lines %q after the first check", lines())
+	}
+	write("second ")
+
+	tests := []struct {
+		name     string
+		change   func(settings *config.Settings)
+		after    time.Duration
+		changed  bool
+		expected string
+	}{
+		{name: "checked recently", after: time.Second, expected: "first 6,first 7,first 8,first 9,first 0"},
+		{name: "lines searched", change: func(s *config.Settings) { s.MaxLinesToSearch = 3 }, expected: "first 6,first 7,first 8,first 9,first 0"},
+		{name: "interval", change: func(s *config.Settings) { s.Sleep = 1 }, after: 2 * time.Second, expected: "first 6,first 7,first 8,first 9,first 0"},
+		{name: "lines of other files", change: func(s *config.Settings) { s.Files = []config.FileSettings{{Files: "db.log", MinLinesToPrint: 2}} },
+			expected: "first 6,first 7,first 8,first 9,first 0"},
+		{name: "lines of the file", change: func(s *config.Settings) { s.Files = []config.FileSettings{{Files: "*.log", MinLinesToPrint: 2}} },
+			changed: true, expected: "second 9,second 0"},
+		{name: "lines in memory", change: func(s *config.Settings) { s.MinLinesToPrint = 3 }, changed: true, expected: "second 8,second 9,second 0"},
+		{name: "bytes in memory", change: func(s *config.Settings) { s.MinLinesToPrint = 3; s.MaxBytes = 20 }, changed: true, expected: "second 9,second 0"},
+	}
+	for _, test := range tests {
+		settings := base
+		if test.change != nil {
+			test.change(&settings)
+		}
+		changed := CheckFile(&fileList[0], logCfg, settings, now.Add(test.after))
+		if changed != test.changed || lines() != test.expected {
+			t.Errorf("%s: CheckFile() = %v, lines %q, expected %v, %q", test.name, changed, lines(), test.changed, test.expected)
+		}
+		if limits := settings.Limits(path); fileList[0].LogFileInfoStruct.Limits != limits {
+			t.Errorf("%s: limits %+v, expected %+v", test.name, fileList[0].LogFileInfoStruct.Limits, limits)
+		}
+		if !test.changed { // The next test start from the lines read with the base settings
+			continue
+		}
+		write("first ")
+		CheckFile(&fileList[0], logCfg, base, now)
+		write("second ")
+	}
+
+	excluded := base
+	excluded.Exclude = []string{"*.log"}
+	if CheckFile(&fileList[0], logCfg, excluded, now) || !fileList[0].Excluded || fileList[0].Data != nil {
+		t.Error("expected the file excluded and the lines released")
+	}
+}
+
+func mustDecompress(t *testing.T, data []byte) []byte {
+	uncompressed, err := gozstd.Decompress(nil, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return uncompressed
+}
+
+func TestTrimLines(t *testing.T) {
+	data := "first\nsecond\nthird\n"
+	tests := []struct {
+		maxBytes int
+		expected string
+	}{
+		{maxBytes: 100, expected: data},
+		{maxBytes: len(data), expected: data},
+		{maxBytes: len(data) - 1, expected: "second\nthird\n"},          // The first line is truncated
+		{maxBytes: len("second\nthird\n"), expected: "second\nthird\n"}, // Cut on the line boundary
+		{maxBytes: len("second\nthird\n") - 1, expected: "third\n"},
+		{maxBytes: len("third\n"), expected: "third\n"},
+		{maxBytes: 3, expected: ""}, // The last line does not fit
+	}
+	for _, test := range tests {
+		if trimmed := string(TrimLines([]byte(data), test.maxBytes)); trimmed != test.expected {
+			t.Errorf("TrimLines(%d) = %q, expected %q", test.maxBytes, trimmed, test.expected)
+		}
+	}
+}
+
+func TestNextCheck(t *testing.T) {
+	now := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+	type file struct {
+		sleep    int
+		checked  time.Duration // Time since the last check
+		excluded bool
+	}
+	settings := config.Settings{Sleep: 30}
+	tests := []struct {
+		name     string
+		files    []file
+		expected time.Duration
+	}{
+		{name: "no files", expected: 30 * time.Second},
+		{name: "global interval", files: []file{{sleep: 30, checked: 10 * time.Second}}, expected: 20 * time.Second},
+		{name: "per-file interval", files: []file{{sleep: 30}, {sleep: 5, checked: 2 * time.Second}}, expected: 3 * time.Second},
+		{name: "longer interval", files: []file{{sleep: 300}}, expected: 30 * time.Second}, // The settings are applied at least every global interval
+		{name: "excluded", files: []file{{sleep: 5, excluded: true}}, expected: 30 * time.Second},
+		{name: "overdue", files: []file{{sleep: 5, checked: time.Minute}}, expected: time.Second},
+	}
+	for _, test := range tests {
+		fileList := make([]datastructure.LogFileStruct, len(test.files))
+		for i, f := range test.files {
+			fileList[i].LogFileInfoStruct.Limits.Sleep = f.sleep
+			fileList[i].Checked = now.Add(-f.checked)
+			fileList[i].Excluded = f.excluded
+		}
+		if sleep := NextCheck(fileList, settings, now); sleep != test.expected {
+			t.Errorf("%s: NextCheck() = %s, expected %s", test.name, sleep, test.expected)
+		}
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	var delivered int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(200 * time.Millisecond)
+			atomic.StoreInt32(&delivered, 1)
+			return
+		}
+		<-release // Never answer before the end of the test
+	}))
+	defer server.Close()
+	defer close(release) // Before closing the server, that wait the requests in progress
+	tests := []struct {
+		webhook   string
+		delivered bool
+	}{
+		{webhook: "/slow", delivered: true},
+		{webhook: "/stuck", delivered: false},
+	}
+	for _, test := range tests {
+		atomic.StoreInt32(&delivered, 0)
+		rule := alert.Rule{Name: "oom", Files: "app.log", Filter: "out of memory"}
+		engine, err := alert.LoadEngine(filepath.Join(t.TempDir(), "alerts.json"), alert.Configuration{Webhooks: []string{server.URL + test.webhook}, Rules: []alert.Rule{rule}}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		now := time.Now()
+		engine.Observe("/var/log/app.log", [][]byte{[]byte("FATAL out of memory")}, now)
+		engine.Evaluate(now)
+		timeout, snapshot := 1, ""
+		logCfg := testConfiguration(t)
+		logCfg.ShutdownTimeout, logCfg.Snapshot, logCfg.AlertEngine = &timeout, &snapshot, engine
+		var engines sync.WaitGroup
+		start := time.Now()
+		Shutdown(nil, logCfg, &engines)
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("%s: Shutdown took %v, expected at most the timeout", test.webhook, elapsed)
+		}
+		if (atomic.LoadInt32(&delivered) == 1) != test.delivered {
+			t.Errorf("%s: delivered = %v, expected %v", test.webhook, !test.delivered, test.delivered)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+// Package aggregate group the log lines in time buckets, counting the lines and computing the statistics of a numeric field.
+package aggregate
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// MaxBuckets is the maximum number of buckets (for every group) that can be returned
+const MaxBuckets = 10000
+
+// Stats contains the statistics of the numeric field for a bucket
+type Stats struct {
+	Min         float64            `json:"Min"`
+	Max         float64            `json:"Max"`
+	Avg         float64            `json:"Avg"`
+	Sum         float64            `json:"Sum"`
+	Count       int                `json:"Count"`       // Number of lines that contains the numeric field
+	Percentiles map[string]float64 `json:"Percentiles"` // Percentiles, by name (p50, p90, p99)
+}
+
+// Bucket contains the number of lines (and the stats of the numeric field) related to a time range
+type Bucket struct {
+	Time  time.Time `json:"Time"`            // Start of the bucket
+	Count int       `json:"Count"`           // Number of lines in the bucket
+	Stats *Stats    `json:"Stats,omitempty"` // Statistics of the numeric field, nil if no value was found
+}
+
+// Series contains the buckets related to a value of the group-by field
+type Series struct {
+	Group   string   `json:"Group"`
+	Total   int      `json:"Total"`
+	Buckets []Bucket `json:"Buckets"`
+}
+
+// Result is the output of the aggregation, the buckets of every series are aligned in order to be used in a chart
+type Result struct {
+	Bucket   string    `json:"Bucket"`   // Size of the bucket
+	From     time.Time `json:"From"`     // Start of the first bucket
+	To       time.Time `json:"To"`       // End of the last bucket
+	Total    int       `json:"Total"`    // Number of lines aggregated
+	Unparsed int       `json:"Unparsed"` // Number of lines that satisfy the query but without a recognizable timestamp
+	Series   []Series  `json:"Series"`
+}
+
+type accumulator struct {
+	count  int
+	values []float64
+}
+
+// Aggregator collect the lines in time buckets
+type Aggregator struct {
+	bucket   time.Duration
+	from, to time.Time // Optional range, zero means unbounded
+	min, max time.Time // Range of the collected lines
+	unparsed int
+	groups   map[string]map[int64]*accumulator
+}
+
+// New initialize an aggregator with the given bucket size. Lines outside the [from, to) range (if not zero) are ignored
+func New(bucket time.Duration, from, to time.Time) (*Aggregator, error) {
+	if bucket <= 0 {
+		return nil, errors.New("bucket have to be greater than zero")
+	}
+	if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+		return nil, errors.New("since have to be before until")
+	}
+	if !from.IsZero() && !to.IsZero() && to.Sub(from)/bucket > MaxBuckets {
+		return nil, errors.New("too many buckets, use a bigger bucket or a smaller time range")
+	}
+	return &Aggregator{bucket: bucket, from: from, to: to, groups: make(map[string]map[int64]*accumulator)}, nil
+}
+
+// AddUnparsed count a line without timestamp
+func (a *Aggregator) AddUnparsed() {
+	a.unparsed++
+}
+
+// Add collect a line. The value is used only if hasValue is true
+func (a *Aggregator) Add(t time.Time, group string, value float64, hasValue bool) {
+	if !a.from.IsZero() && t.Before(a.from) || !a.to.IsZero() && !t.Before(a.to) {
+		return
+	}
+	if a.min.IsZero() || t.Before(a.min) {
+		a.min = t
+	}
+	if t.After(a.max) {
+		a.max = t
+	}
+	buckets, ok := a.groups[group]
+	if !ok {
+		buckets = make(map[int64]*accumulator)
+		a.groups[group] = buckets
+	}
+	key := t.Truncate(a.bucket).UnixNano()
+	acc, ok := buckets[key]
+	if !ok {
+		acc = &accumulator{}
+		buckets[key] = acc
+	}
+	acc.count++
+	if hasValue {
+		acc.values = append(acc.values, value)
+	}
+}
+
+// Result return the series of buckets, filling the empty buckets with zero in order to have a continuous range
+func (a *Aggregator) Result(percentiles []float64) (Result, error) {
+	result := Result{Bucket: a.bucket.String(), Unparsed: a.unparsed, Series: []Series{}}
+	from, to := a.from, a.to
+	if from.IsZero() {
+		from = a.min
+	}
+	if to.IsZero() { // End of the bucket of the last line
+		to = a.max.Truncate(a.bucket).Add(a.bucket)
+	}
+	if len(a.groups) == 0 {
+		return result, nil
+	}
+	result.From = from.Truncate(a.bucket)
+	result.To = to
+	if result.To.Sub(result.From)/a.bucket > MaxBuckets {
+		return result, errors.New("too many buckets, use a bigger bucket or a smaller time range")
+	}
+	groups := make([]string, 0, len(a.groups))
+	for group := range a.groups {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	for _, group := range groups {
+		series := Series{Group: group}
+		for t := result.From; t.Before(result.To); t = t.Add(a.bucket) {
+			bucket := Bucket{Time: t}
+			if acc, ok := a.groups[group][t.UnixNano()]; ok {
+				bucket.Count = acc.count
+				bucket.Stats = computeStats(acc.values, percentiles)
+			}
+			series.Total += bucket.Count
+			series.Buckets = append(series.Buckets, bucket)
+		}
+		result.Total += series.Total
+		result.Series = append(result.Series, series)
+	}
+	return result, nil
+}
+
+// computeStats calculate min, max, avg and the percentiles (nearest rank) of the values
+func computeStats(values []float64, percentiles []float64) *Stats {
+	if len(values) == 0 {
+		return nil
+	}
+	sort.Float64s(values)
+	stats := &Stats{Min: values[0], Max: values[len(values)-1], Count: len(values), Percentiles: make(map[string]float64, len(percentiles))}
+	for _, v := range values {
+		stats.Sum += v
+	}
+	stats.Avg = stats.Sum / float64(len(values))
+	for _, p := range percentiles {
+		rank := int(math.Ceil(p/100*float64(len(values)))) - 1
+		if rank < 0 {
+			rank = 0
+		}
+		if rank >= len(values) {
+			rank = len(values) - 1
+		}
+		stats.Percentiles["p"+strconv.FormatFloat(p, 'f', -1, 64)] = values[rank]
+	}
+	return stats
+}
@@ -0,0 +1,210 @@
+package aggregate
+
+import (
+	"testing"
+	"time"
+)
+
+var base = time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+
+func at(offset string) time.Time {
+	d, err := time.ParseDuration(offset)
+	if err != nil {
+		panic(err)
+	}
+	return base.Add(d)
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name     string
+		bucket   time.Duration
+		from, to time.Time
+		invalid  bool
+	}{
+		{name: "unbounded", bucket: time.Minute},
+		{name: "range", bucket: time.Minute, from: at("0s"), to: at("1h")},
+		{name: "only since", bucket: time.Minute, from: at("0s")},
+		{name: "zero bucket", bucket: 0, invalid: true},
+		{name: "negative bucket", bucket: -time.Second, invalid: true},
+		{name: "empty range", bucket: time.Minute, from: at("1h"), to: at("1h"), invalid: true},
+		{name: "inverted range", bucket: time.Minute, from: at("2h"), to: at("1h"), invalid: true},
+		{name: "max buckets", bucket: time.Second, from: at("0s"), to: at("10000s")},
+		{name: "too many buckets", bucket: time.Second, from: at("0s"), to: at("10001s"), invalid: true},
+	}
+	for _, test := range tests {
+		_, err := New(test.bucket, test.from, test.to)
+		if test.invalid != (err != nil) {
+			t.Errorf("%s: New() error = %v, expected invalid %v", test.name, err, test.invalid)
+		}
+	}
+}
+
+func TestBuckets(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to time.Time
+		lines    []string // Offset of the lines from the base time
+		start    time.Time
+		counts   []int
+	}{
+		{name: "single bucket", lines: []string{"10s", "50s"}, start: at("0s"), counts: []int{2}},
+		{name: "empty buckets filled", lines: []string{"10s", "50s", "2m5s"}, start: at("0s"), counts: []int{2, 0, 1}},
+		{name: "unordered lines", lines: []string{"2m5s", "10s", "1m"}, start: at("0s"), counts: []int{1, 1, 1}},
+		{name: "since and until", from: at("1m"), to: at("4m"), lines: []string{"10s", "1m", "1m59s", "3m59s", "4m"}, start: at("1m"), counts: []int{2, 0, 1}},
+		{name: "since not aligned", from: at("30s"), lines: []string{"10s", "40s", "1m10s"}, start: at("0s"), counts: []int{1, 1}},
+	}
+	for _, test := range tests {
+		aggregator, err := New(time.Minute, test.from, test.to)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		for _, line := range test.lines {
+			aggregator.Add(at(line), "", 0, false)
+		}
+		result, err := aggregator.Result(nil)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		if len(result.Series) != 1 {
+			t.Fatalf("%s: %d series, expected 1", test.name, len(result.Series))
+		}
+		buckets := result.Series[0].Buckets
+		if len(buckets) != len(test.counts) {
+			t.Errorf("%s: %d buckets, expected %d (%v)", test.name, len(buckets), len(test.counts), buckets)
+			continue
+		}
+		total := 0
+		for i, bucket := range buckets {
+			if expected := test.start.Add(time.Duration(i) * time.Minute); !bucket.Time.Equal(expected) {
+				t.Errorf("%s: bucket %d start at %s, expected %s", test.name, i, bucket.Time, expected)
+			}
+			if bucket.Count != test.counts[i] {
+				t.Errorf("%s: bucket %d contains %d lines, expected %d", test.name, i, bucket.Count, test.counts[i])
+			}
+			total += bucket.Count
+		}
+		if result.Total != total || result.Series[0].Total != total {
+			t.Errorf("%s: total %d (series %d), expected %d", test.name, result.Total, result.Series[0].Total, total)
+		}
+		if !result.From.Equal(test.start) {
+			t.Errorf("%s: from %s, expected %s", test.name, result.From, test.start)
+		}
+	}
+}
+
+func TestGroups(t *testing.T) {
+	aggregator, err := New(time.Minute, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	aggregator.Add(at("10s"), "error", 0, false)
+	aggregator.Add(at("2m"), "warn", 0, false)
+	aggregator.Add(at("2m30s"), "warn", 0, false)
+	aggregator.AddUnparsed()
+	result, err := aggregator.Result(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Total != 3 || result.Unparsed != 1 {
+		t.Errorf("total %d, unparsed %d, expected 3 and 1", result.Total, result.Unparsed)
+	}
+	expected := map[string][]int{"error": {1, 0, 0}, "warn": {0, 0, 2}}
+	if len(result.Series) != 2 || result.Series[0].Group != "error" || result.Series[1].Group != "warn" {
+		t.Fatalf("series %v, expected error and warn (sorted)", result.Series)
+	}
+	for _, series := range result.Series {
+		if len(series.Buckets) != len(expected[series.Group]) {
+			t.Errorf("%s: %d buckets, expected %d (the series have to be aligned)", series.Group, len(series.Buckets), len(expected[series.Group]))
+			continue
+		}
+		for i, bucket := range series.Buckets {
+			if bucket.Count != expected[series.Group][i] {
+				t.Errorf("%s: bucket %d contains %d lines, expected %d", series.Group, i, bucket.Count, expected[series.Group][i])
+			}
+		}
+	}
+}
+
+func TestEmpty(t *testing.T) {
+	aggregator, err := New(time.Minute, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	aggregator.AddUnparsed()
+	result, err := aggregator.Result([]float64{50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Total != 0 || result.Unparsed != 1 || result.Series == nil || len(result.Series) != 0 {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
+
+func TestTooManyBuckets(t *testing.T) {
+	aggregator, err := New(time.Second, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	aggregator.Add(at("0s"), "", 0, false)
+	aggregator.Add(at("24h"), "", 0, false)
+	if _, err := aggregator.Result(nil); err == nil {
+		t.Error("expected an error for a day of one second buckets")
+	}
+}
+
+func TestStats(t *testing.T) {
+	aggregator, err := New(time.Hour, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 100; i >= 1; i-- { // Unordered values
+		aggregator.Add(at("1m"), "", float64(i), true)
+	}
+	aggregator.Add(at("2m"), "", 0, false) // Counted, but without value
+	result, err := aggregator.Result([]float64{0, 50, 90, 99, 99.9, 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bucket := result.Series[0].Buckets[0]
+	if bucket.Count != 101 {
+		t.Errorf("count %d, expected 101", bucket.Count)
+	}
+	stats := bucket.Stats
+	if stats == nil {
+		t.Fatal("missing stats")
+	}
+	if stats.Min != 1 || stats.Max != 100 || stats.Sum != 5050 || stats.Avg != 50.5 || stats.Count != 100 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	expected := map[string]float64{"p0": 1, "p50": 50, "p90": 90, "p99": 99, "p99.9": 100, "p100": 100}
+	for name, value := range expected {
+		if stats.Percentiles[name] != value {
+			t.Errorf("%s = %v, expected %v", name, stats.Percentiles[name], value)
+		}
+	}
+}
+
+func TestStatsWithoutValues(t *testing.T) {
+	aggregator, err := New(time.Minute, time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	aggregator.Add(at("0s"), "", 0, false)
+	result, err := aggregator.Result([]float64{50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats := result.Series[0].Buckets[0].Stats; stats != nil {
+		t.Errorf("stats %+v, expected nil when no line contains the field", stats)
+	}
+}
+
+func TestPercentilesSingleValue(t *testing.T) {
+	stats := computeStats([]float64{42}, []float64{1, 50, 99})
+	for name, value := range stats.Percentiles {
+		if value != 42 {
+			t.Errorf("%s = %v, expected 42", name, value)
+		}
+	}
+}
@@ -0,0 +1,583 @@
+package alert
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/alessiosavi/GoLog-Viewer/parser"
+	"github.com/alessiosavi/GoLog-Viewer/query"
+	log "github.com/sirupsen/logrus"
+	"github.com/valyala/fasthttp"
+)
+
+// State of an alert
+type State string
+
+// States of the alert lifecycle
+const (
+	StateInactive State = "inactive" // Condition not satisfied
+	StatePending  State = "pending"  // Condition satisfied, waiting the "For" duration
+	StateFiring   State = "firing"   // Condition satisfied for the "For" duration, webhooks notified
+	StateResolved State = "resolved" // Condition no more satisfied after firing, webhooks notified
+)
+
+// maxSamples is the number of the latest matching lines saved for every alert
+const maxSamples = 5
+
+// maxHistory is the number of evaluations saved for every rule
+const maxHistory = 100
+
+// Errors returned by the management methods of the engine
+var (
+	ErrRuleNotFound = errors.New("rule not found")
+	ErrRuleExists   = errors.New("rule already exists")
+	ErrNoFile       = errors.New("no file where save the rules, the rules can't be changed")
+)
+
+// PersistError is returned when the change is applied but the configuration file can't be saved
+type PersistError struct {
+	Err error
+}
+
+func (e *PersistError) Error() string {
+	return "change applied but not saved: " + e.Err.Error()
+}
+
+// Alert is the status of a rule for a single file
+type Alert struct {
+	Rule         string    `json:"Rule"`
+	File         string    `json:"File"`
+	State        State     `json:"State"`
+	Count        int       `json:"Count"`        // Number of matching lines in the current window
+	ActiveSince  time.Time `json:"ActiveSince"`  // When the condition became true
+	FiredAt      time.Time `json:"FiredAt"`      // Last time that the alert started firing
+	ResolvedAt   time.Time `json:"ResolvedAt"`   // Last time that the alert was resolved
+	LastNotified time.Time `json:"LastNotified"` // Last notification sent to the webhooks
+	Samples      []string  `json:"Samples"`      // Latest matching lines
+}
+
+// Notification is the payload sent (POST, json) to the webhooks
+type Notification struct {
+	Fingerprint string     `json:"Fingerprint"` // Identify the alert (rule + file), used by the receiver for deduplicate
+	Rule        string     `json:"Rule"`
+	File        string     `json:"File"`
+	State       State      `json:"State"`
+	Count       int        `json:"Count"`
+	Threshold   int        `json:"Threshold"`
+	Window      string     `json:"Window"`
+	StartsAt    time.Time  `json:"StartsAt"`
+	EndsAt      *time.Time `json:"EndsAt,omitempty"` // Populated only for the resolved alerts
+	Samples     []string   `json:"Samples"`
+}
+
+type event struct {
+	time    time.Time
+	count   int
+	samples []string // Latest matching lines of the event
+}
+
+type instance struct {
+	Alert
+	events []event // Matching lines by ingestion time, older than the window are discarded
+}
+
+// Evaluation is an entry of the history of a rule: it's saved every time that an alert change state or is notified
+type Evaluation struct {
+	Time     time.Time `json:"Time"`
+	File     string    `json:"File"`
+	State    State     `json:"State"`
+	Count    int       `json:"Count"`
+	Notified bool      `json:"Notified"` // The webhooks were notified (false for the silenced rules)
+}
+
+// RuleStatus contains the definition of a rule with the related alerts
+type RuleStatus struct {
+	Rule
+	Silenced       bool      `json:"Silenced"`
+	LastEvaluation time.Time `json:"LastEvaluation"`
+	LastFiring     time.Time `json:"LastFiring"`
+	Alerts         []Alert   `json:"Alerts"`
+}
+
+type compiledRule struct {
+	rule           Rule
+	filter         query.Filter
+	instances      map[string]*instance // Alert by file
+	history        []Evaluation
+	lastEvaluation time.Time
+	lastFiring     time.Time
+}
+
+// Engine evaluate the rules against the new lines of the files. It's safe for concurrent use.
+// The rules can be managed at runtime, every change is saved in the configuration file (the engines without file refuse the changes)
+type Engine struct {
+	mutex    sync.Mutex
+	rules    []*compiledRule
+	webhooks []string
+	parser   *parser.Parser
+	client   *fasthttp.Client
+	path     string // Configuration file used for persist the rules, empty if the rules can't be changed
+
+	notifications sync.WaitGroup // Notifications in progress, awaited by Wait
+}
+
+// LoadEngine initialize the engine with the rules of the configuration file, the changes made at runtime are saved in the same file.
+// A missing file is not an error: the default rules are used, the file will be created on the first change
+func LoadEngine(path string, defaults Configuration, lineParser *parser.Parser) (*Engine, error) {
+	if path == "" {
+		return nil, ErrNoFile
+	}
+	cfg := defaults
+	if _, err := os.Stat(path); err == nil {
+		if cfg, err = LoadConfiguration(path); err != nil {
+			return nil, err
+		}
+	}
+	e, err := NewEngine(cfg, lineParser)
+	if err != nil {
+		return nil, err
+	}
+	e.path = path
+	return e, nil
+}
+
+// NewEngine validate and compile the rules of the configuration
+func NewEngine(cfg Configuration, lineParser *parser.Parser) (*Engine, error) {
+	e := &Engine{parser: lineParser, client: &fasthttp.Client{Name: "GoLog-Viewer", ReadTimeout: 5 * time.Second, WriteTimeout: 5 * time.Second}}
+	for _, webhook := range cfg.Webhooks {
+		if err := validateWebhook(webhook); err != nil {
+			return nil, err
+		}
+	}
+	e.webhooks = cfg.Webhooks
+	names := make(map[string]struct{}, len(cfg.Rules))
+	for _, rule := range cfg.Rules {
+		compiled, err := e.compile(rule)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := names[compiled.rule.Name]; ok {
+			return nil, errors.New("duplicated rule " + compiled.rule.Name)
+		}
+		names[compiled.rule.Name] = struct{}{}
+		e.rules = append(e.rules, compiled)
+	}
+	return e, nil
+}
+
+func (e *Engine) compile(rule Rule) (*compiledRule, error) {
+	if err := rule.Validate(); err != nil {
+		return nil, err
+	}
+	filter, err := rule.filter(e.parser)
+	if err != nil {
+		return nil, err
+	}
+	return &compiledRule{rule: rule, filter: filter, instances: make(map[string]*instance)}, nil
+}
+
+// Observe count the new lines of the file that satisfy the rules watching the file
+func (e *Engine) Observe(path string, lines [][]byte, now time.Time) {
+	if e == nil {
+		return
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	for _, r := range e.rules {
+		if !r.rule.matchFile(path) {
+			continue
+		}
+		var matched int
+		var samples []string
+		for _, line := range lines {
+			if r.filter.Match(path, line) {
+				matched++
+				samples = append(samples, string(line))
+			}
+		}
+		if matched == 0 {
+			continue
+		}
+		a, ok := r.instances[path]
+		if !ok {
+			a = &instance{Alert: Alert{Rule: r.rule.Name, File: path, State: StateInactive}}
+			r.instances[path] = a
+		}
+		a.events = append(a.events, event{time: now, count: matched, samples: latest(samples)})
+		a.Samples = latest(append(a.Samples, samples...))
+	}
+}
+
+// Evaluate update the state of every alert and notify the webhooks about the alerts that start firing or are resolved
+func (e *Engine) Evaluate(now time.Time) {
+	if e == nil {
+		return
+	}
+	e.mutex.Lock()
+	var notifications []Notification
+	var targets [][]string
+	for _, r := range e.rules {
+		for _, a := range r.instances {
+			if notification, ok := e.evaluate(r, a, now); ok {
+				notifications = append(notifications, notification)
+				targets = append(targets, e.webhooksOf(r))
+			}
+		}
+	}
+	e.mutex.Unlock()
+	e.notifications.Add(len(notifications))
+	for i := range notifications {
+		go func(webhooks []string, notification Notification) {
+			defer e.notifications.Done()
+			e.notify(webhooks, notification)
+		}(targets[i], notifications[i])
+	}
+}
+
+// Wait wait the notifications in progress for at most timeout, return false if some notification is still in progress
+func (e *Engine) Wait(timeout time.Duration) bool {
+	if e == nil {
+		return true
+	}
+	done := make(chan struct{})
+	go func() {
+		e.notifications.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// evaluate apply the state machine to the alert, return the notification to send (if any)
+func (e *Engine) evaluate(r *compiledRule, a *instance, now time.Time) (Notification, bool) {
+	start := now.Add(-time.Duration(r.rule.Window))
+	var count, keep int
+	for _, ev := range a.events {
+		if ev.time.After(start) {
+			a.events[keep] = ev
+			keep++
+			count += ev.count
+		}
+	}
+	a.events = a.events[:keep]
+	a.Count = count
+	active := count > r.rule.Threshold
+
+	previous := a.State
+	notify := false
+	switch {
+	case active && (a.State == StateInactive || a.State == StateResolved):
+		a.State, a.ActiveSince = StatePending, now
+		a.Samples = a.windowSamples() // The lines of the previous episode are not related to the new one
+		fallthrough
+	case active && a.State == StatePending:
+		if now.Sub(a.ActiveSince) >= time.Duration(r.rule.For) {
+			a.State, a.FiredAt = StateFiring, now
+			notify = true
+		}
+	case active && a.State == StateFiring:
+		notify = r.rule.RepeatInterval > 0 && now.Sub(a.LastNotified) >= time.Duration(r.rule.RepeatInterval)
+	case !active && a.State == StatePending:
+		a.State = StateInactive
+	case !active && a.State == StateFiring:
+		a.State, a.ResolvedAt = StateResolved, now
+		notify = true
+	}
+	r.lastEvaluation = now
+	if a.State == StateFiring && previous != StateFiring {
+		r.lastFiring = now
+	}
+	silenced := now.Before(r.rule.SilencedUntil)
+	if previous != a.State || notify {
+		r.history = append(r.history, Evaluation{Time: now, File: a.File, State: a.State, Count: count, Notified: notify && !silenced && len(e.webhooksOf(r)) > 0})
+		if len(r.history) > maxHistory {
+			r.history = r.history[len(r.history)-maxHistory:]
+		}
+	}
+	if !notify || silenced {
+		return Notification{}, false
+	}
+	a.LastNotified = now
+	notification := Notification{Fingerprint: r.rule.Name + "|" + a.File, Rule: r.rule.Name, File: a.File, State: a.State, Count: count,
+		Threshold: r.rule.Threshold, Window: time.Duration(r.rule.Window).String(), StartsAt: a.ActiveSince, Samples: append([]string(nil), a.Samples...)}
+	if a.State == StateResolved {
+		notification.EndsAt = &now
+	}
+	log.Warn("Alert | ", notification.Rule, " | ", notification.File, " -> ", notification.State, " | Count: ", count)
+	return notification, true
+}
+
+// latest return the last maxSamples lines
+func latest(samples []string) []string {
+	if len(samples) > maxSamples {
+		return samples[len(samples)-maxSamples:]
+	}
+	return samples
+}
+
+// windowSamples return the latest matching lines of the events in the window
+func (a *instance) windowSamples() []string {
+	var samples []string
+	for _, ev := range a.events {
+		samples = latest(append(samples, ev.samples...))
+	}
+	return samples
+}
+
+func (e *Engine) webhooksOf(r *compiledRule) []string {
+	if len(r.rule.Webhooks) > 0 {
+		return r.rule.Webhooks
+	}
+	return e.webhooks
+}
+
+// notify send the notification to every webhook, retrying few times in case of error
+func (e *Engine) notify(webhooks []string, notification Notification) {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		log.Error("Alert | Unable to encode the notification | Err: ", err)
+		return
+	}
+	for _, webhook := range webhooks {
+		for attempt := 1; attempt <= 3; attempt++ {
+			if err = e.post(webhook, body); err == nil {
+				break
+			}
+			log.Warn("Alert | Unable to notify ", webhook, " (attempt ", attempt, ") | Err: ", err)
+			time.Sleep(time.Duration(attempt) * time.Second)
+		}
+	}
+}
+
+func (e *Engine) post(webhook string, body []byte) error {
+	req := fasthttp.AcquireRequest()
+	resp := fasthttp.AcquireResponse()
+	defer fasthttp.ReleaseRequest(req)
+	defer fasthttp.ReleaseResponse(resp)
+	req.SetRequestURI(webhook)
+	req.Header.SetMethod("POST")
+	req.Header.SetContentType("application/json")
+	req.SetBody(body)
+	if err := e.client.DoTimeout(req, resp, 10*time.Second); err != nil {
+		return err
+	}
+	if resp.StatusCode() >= 300 {
+		return errors.New("unexpected status code " + fasthttp.StatusMessage(resp.StatusCode()))
+	}
+	return nil
+}
+
+// Alerts return the status of every alert, sorted by rule and file
+func (e *Engine) Alerts() []Alert {
+	alerts := []Alert{}
+	if e == nil {
+		return alerts
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	for _, r := range e.rules {
+		for _, a := range r.instances {
+			alert := a.Alert
+			alert.Samples = append([]string(nil), a.Samples...)
+			alerts = append(alerts, alert)
+		}
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		if alerts[i].Rule != alerts[j].Rule {
+			return alerts[i].Rule < alerts[j].Rule
+		}
+		return alerts[i].File < alerts[j].File
+	})
+	return alerts
+}
+
+/* ------------- MANAGEMENT ------------- */
+
+// Rules return the definition and the status of every rule
+func (e *Engine) Rules() []RuleStatus {
+	if e == nil {
+		return []RuleStatus{}
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	now := time.Now()
+	rules := make([]RuleStatus, 0, len(e.rules))
+	for _, r := range e.rules {
+		rules = append(rules, e.status(r, now))
+	}
+	return rules
+}
+
+// Rule return the definition and the status of the given rule
+func (e *Engine) Rule(name string) (RuleStatus, error) {
+	if e == nil {
+		return RuleStatus{}, ErrRuleNotFound
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	i := e.find(name)
+	if i < 0 {
+		return RuleStatus{}, ErrRuleNotFound
+	}
+	return e.status(e.rules[i], time.Now()), nil
+}
+
+func (e *Engine) status(r *compiledRule, now time.Time) RuleStatus {
+	status := RuleStatus{Rule: r.rule, Silenced: now.Before(r.rule.SilencedUntil), LastEvaluation: r.lastEvaluation, LastFiring: r.lastFiring, Alerts: []Alert{}}
+	for _, a := range r.instances {
+		alert := a.Alert
+		alert.Samples = append([]string(nil), a.Samples...)
+		status.Alerts = append(status.Alerts, alert)
+	}
+	sort.Slice(status.Alerts, func(i, j int) bool { return status.Alerts[i].File < status.Alerts[j].File })
+	return status
+}
+
+// History return the latest evaluations of the rule, the newest first
+func (e *Engine) History(name string) ([]Evaluation, error) {
+	if e == nil {
+		return nil, ErrRuleNotFound
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	i := e.find(name)
+	if i < 0 {
+		return nil, ErrRuleNotFound
+	}
+	history := make([]Evaluation, len(e.rules[i].history))
+	for j, evaluation := range e.rules[i].history {
+		history[len(history)-1-j] = evaluation
+	}
+	return history, nil
+}
+
+// CreateRule validate and add a new rule
+func (e *Engine) CreateRule(rule Rule) (Rule, error) {
+	if e == nil || e.path == "" {
+		return rule, ErrNoFile
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	compiled, err := e.compile(rule)
+	if err != nil {
+		return rule, err
+	}
+	if e.find(compiled.rule.Name) >= 0 {
+		return rule, ErrRuleExists
+	}
+	e.rules = append(e.rules, compiled)
+	return compiled.rule, e.save()
+}
+
+// UpdateRule replace the definition of the rule. The alerts of the rule are reset, the history and the silence are preserved
+func (e *Engine) UpdateRule(name string, rule Rule) (Rule, error) {
+	if e == nil || e.path == "" {
+		return rule, ErrNoFile
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	i := e.find(name)
+	if i < 0 {
+		return rule, ErrRuleNotFound
+	}
+	compiled, err := e.compile(rule)
+	if err != nil {
+		return rule, err
+	}
+	if j := e.find(compiled.rule.Name); j >= 0 && j != i {
+		return rule, ErrRuleExists
+	}
+	if compiled.rule.SilencedUntil.IsZero() { // Keep the silence if not explicitly provided
+		compiled.rule.SilencedUntil = e.rules[i].rule.SilencedUntil
+	}
+	compiled.history = e.rules[i].history
+	compiled.lastEvaluation, compiled.lastFiring = e.rules[i].lastEvaluation, e.rules[i].lastFiring
+	e.rules[i] = compiled
+	return compiled.rule, e.save()
+}
+
+// SilenceRule suppress the notifications of the rule until the given time (the zero time remove the silence).
+// The alerts are still evaluated
+func (e *Engine) SilenceRule(name string, until time.Time) (Rule, error) {
+	if e == nil || e.path == "" {
+		return Rule{}, ErrNoFile
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	i := e.find(name)
+	if i < 0 {
+		return Rule{}, ErrRuleNotFound
+	}
+	e.rules[i].rule.SilencedUntil = until
+	return e.rules[i].rule, e.save()
+}
+
+// DeleteRule remove the rule and the related alerts
+func (e *Engine) DeleteRule(name string) error {
+	if e == nil || e.path == "" {
+		return ErrNoFile
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	i := e.find(name)
+	if i < 0 {
+		return ErrRuleNotFound
+	}
+	e.rules = append(e.rules[:i], e.rules[i+1:]...)
+	return e.save()
+}
+
+func (e *Engine) find(name string) int {
+	for i, r := range e.rules {
+		if r.rule.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// save write the configuration file
+func (e *Engine) save() error {
+	if err := e.write(); err != nil {
+		log.Error("Alert | Unable to save the rules in ", e.path, " | Err: ", err)
+		return &PersistError{Err: err}
+	}
+	return nil
+}
+
+// write replace atomically the configuration file, in order to never leave a truncated file
+func (e *Engine) write() error {
+	cfg := Configuration{Webhooks: e.webhooks, Rules: make([]Rule, len(e.rules))}
+	for i, r := range e.rules {
+		cfg.Rules[i] = r.rule
+	}
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(e.path), filepath.Base(e.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), e.path)
+}
@@ -0,0 +1,461 @@
+package alert
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var start = time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+
+// webhook is a receiver of the notifications
+type webhook struct {
+	*httptest.Server
+	received chan Notification
+}
+
+func newWebhook() *webhook {
+	w := &webhook{received: make(chan Notification, 10)}
+	w.Server = httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		var notification Notification
+		if err := json.NewDecoder(r.Body).Decode(&notification); err == nil {
+			w.received <- notification
+		}
+	}))
+	return w
+}
+
+// next return the next notification received, failing after a timeout
+func (w *webhook) next(t *testing.T) Notification {
+	t.Helper()
+	select {
+	case notification := <-w.received:
+		return notification
+	case <-time.After(5 * time.Second):
+		t.Fatal("notification not received")
+	}
+	return Notification{}
+}
+
+// newEngine return an engine that save the rules in a temporary file
+func newEngine(t *testing.T, rule Rule, webhooks ...string) *Engine {
+	t.Helper()
+	e, err := LoadEngine(filepath.Join(t.TempDir(), "alerts.json"), Configuration{Webhooks: webhooks, Rules: []Rule{rule}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func lines(n int, text string) [][]byte {
+	var lines [][]byte
+	for i := 0; i < n; i++ {
+		lines = append(lines, []byte(text))
+	}
+	return lines
+}
+
+func TestEvaluateTransitions(t *testing.T) {
+	receiver := newWebhook()
+	defer receiver.Close()
+	e := newEngine(t, Rule{Name: "errors", Files: "*.log", Filter: "ERROR", Threshold: 2, Window: Duration(time.Minute), For: Duration(30 * time.Second)}, receiver.URL)
+	steps := []struct {
+		offset  time.Duration
+		matched int // Matching lines observed before the evaluation
+		state   State
+		count   int
+		notify  bool
+	}{
+		{offset: 0, matched: 2, state: StateInactive, count: 2}, // The threshold have to be exceeded
+		{offset: 5 * time.Second, matched: 1, state: StatePending, count: 3},
+		{offset: 20 * time.Second, state: StatePending, count: 3},
+		{offset: 35 * time.Second, state: StateFiring, count: 3, notify: true},
+		{offset: 50 * time.Second, state: StateFiring, count: 3},                 // No RepeatInterval
+		{offset: 61 * time.Second, state: StateResolved, count: 1, notify: true}, // The lines observed at 0s left the window
+		{offset: 66 * time.Second, state: StateResolved, count: 0},
+		{offset: 80 * time.Second, matched: 3, state: StatePending, count: 3},
+		{offset: 145 * time.Second, state: StateInactive, count: 0}, // Never fired, no notification
+	}
+	for i, step := range steps {
+		now := start.Add(step.offset)
+		e.Observe("/var/log/app.log", append(lines(step.matched, "ERROR failed"), []byte("INFO ok")), now)
+		e.Evaluate(now)
+		alerts := e.Alerts()
+		if len(alerts) != 1 {
+			t.Fatalf("step %d: %d alerts, expected 1", i, len(alerts))
+		}
+		if alerts[0].State != step.state || alerts[0].Count != step.count {
+			t.Errorf("step %d: %s with %d lines, expected %s with %d lines", i, alerts[0].State, alerts[0].Count, step.state, step.count)
+		}
+		if step.notify {
+			notification := receiver.next(t)
+			if notification.State != step.state || notification.Rule != "errors" || notification.File != "/var/log/app.log" || notification.Fingerprint != "errors|/var/log/app.log" {
+				t.Errorf("step %d: unexpected notification %+v", i, notification)
+			}
+			if step.state == StateResolved && (notification.EndsAt == nil || !notification.EndsAt.Equal(now)) {
+				t.Errorf("step %d: resolved notification without the end %+v", i, notification)
+			}
+		}
+	}
+	history, err := e.History("errors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []State{StateInactive, StatePending, StateResolved, StateFiring, StatePending} // Newest first
+	if len(history) != len(expected) {
+		t.Fatalf("history %+v, expected %v", history, expected)
+	}
+	for i, evaluation := range history {
+		if evaluation.State != expected[i] || evaluation.Notified != (evaluation.State == StateFiring || evaluation.State == StateResolved) {
+			t.Errorf("history %d: %+v, expected %s", i, evaluation, expected[i])
+		}
+	}
+	select {
+	case notification := <-receiver.received:
+		t.Errorf("unexpected notification %+v", notification)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestEvaluateFireImmediately(t *testing.T) {
+	e := newEngine(t, Rule{Name: "oom", Files: "app.log", Filter: "out of memory"})
+	e.Observe("/var/log/app.log", lines(1, "FATAL out of memory"), start)
+	e.Evaluate(start)
+	alerts := e.Alerts()
+	if len(alerts) != 1 || alerts[0].State != StateFiring || !alerts[0].FiredAt.Equal(start) || !alerts[0].ActiveSince.Equal(start) {
+		t.Fatalf("alerts %+v, expected firing without For", alerts)
+	}
+	history, _ := e.History("oom")
+	if len(history) != 1 || history[0].Notified {
+		t.Errorf("history %+v, expected a single evaluation not notified (no webhook)", history)
+	}
+}
+
+func TestEvaluateRepeatInterval(t *testing.T) {
+	receiver := newWebhook()
+	defer receiver.Close()
+	e := newEngine(t, Rule{Name: "errors", Files: "app.log", Filter: "ERROR", Window: Duration(time.Hour), RepeatInterval: Duration(time.Minute)}, receiver.URL)
+	e.Observe("app.log", lines(1, "ERROR"), start)
+	for _, offset := range []time.Duration{0, 30 * time.Second, time.Minute, 90 * time.Second, 2 * time.Minute} {
+		e.Evaluate(start.Add(offset))
+	}
+	for _, expected := range []time.Duration{0, time.Minute, 2 * time.Minute} {
+		if alert := e.Alerts()[0]; alert.State != StateFiring {
+			t.Fatalf("state %s, expected firing", alert.State)
+		}
+		notification := receiver.next(t)
+		if notification.State != StateFiring || !notification.StartsAt.Equal(start) {
+			t.Errorf("unexpected notification %+v (expected the one repeated at %s)", notification, expected)
+		}
+	}
+	if history, _ := e.History("errors"); len(history) != 3 {
+		t.Errorf("history %+v, expected 3 notified evaluations", history)
+	}
+}
+
+func TestSilence(t *testing.T) {
+	receiver := newWebhook()
+	defer receiver.Close()
+	e := newEngine(t, Rule{Name: "errors", Files: "app.log", Filter: "ERROR", Window: Duration(time.Minute)}, receiver.URL)
+	if _, err := e.SilenceRule("errors", start.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.SilenceRule("missing", start); err != ErrRuleNotFound {
+		t.Errorf("SilenceRule() error = %v, expected %v", err, ErrRuleNotFound)
+	}
+	e.Observe("app.log", lines(1, "ERROR"), start)
+	e.Evaluate(start)
+	if alert := e.Alerts()[0]; alert.State != StateFiring {
+		t.Errorf("state %s, the silenced rules have to be evaluated", alert.State)
+	}
+	e.Evaluate(start.Add(2 * time.Minute)) // Resolved while silenced
+	if _, err := e.SilenceRule("errors", time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	e.Observe("app.log", lines(1, "ERROR"), start.Add(3*time.Minute))
+	e.Evaluate(start.Add(3 * time.Minute))
+
+	history, _ := e.History("errors")
+	expected := []struct {
+		state    State
+		notified bool
+	}{{StateFiring, true}, {StateResolved, false}, {StateFiring, false}}
+	if len(history) != len(expected) {
+		t.Fatalf("history %+v, expected %d evaluations", history, len(expected))
+	}
+	for i, evaluation := range history {
+		if evaluation.State != expected[i].state || evaluation.Notified != expected[i].notified {
+			t.Errorf("history %d: %+v, expected %+v", i, evaluation, expected[i])
+		}
+	}
+	if notification := receiver.next(t); notification.State != StateFiring || notification.StartsAt != start.Add(3*time.Minute) {
+		t.Errorf("unexpected notification %+v, the silenced ones have to be suppressed", notification)
+	}
+	select {
+	case notification := <-receiver.received:
+		t.Errorf("unexpected notification %+v", notification)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestSilenceExpired(t *testing.T) {
+	e := newEngine(t, Rule{Name: "errors", Files: "app.log", Filter: "ERROR", SilencedUntil: start.Add(time.Minute)}, "http://127.0.0.1:1/hook")
+	e.Observe("app.log", lines(1, "ERROR"), start.Add(time.Minute))
+	e.Evaluate(start.Add(time.Minute)) // The silence is over
+	history, _ := e.History("errors")
+	if len(history) != 1 || !history[0].Notified {
+		t.Errorf("history %+v, expected a notified evaluation", history)
+	}
+}
+
+func TestObserve(t *testing.T) {
+	e := newEngine(t, Rule{Name: "errors", Files: "app.log, api-*.log", Level: "error+", Threshold: 10})
+	e.Observe("/var/log/db.log", lines(3, "ERROR failed"), start)                   // File not watched
+	e.Observe("/var/log/app.log", lines(3, "INFO ok"), start)                       // Lines not matching
+	e.Observe("/var/log/api-1.log", lines(3, "\x1b[31mERROR\x1b[0m failed"), start) // The colours are ignored
+	for i := 0; i < 8; i++ {
+		e.Observe("/var/log/app.log", [][]byte{[]byte("FATAL sample " + string(rune('a'+i)))}, start)
+	}
+	e.Evaluate(start)
+	alerts := e.Alerts()
+	if len(alerts) != 2 || alerts[0].File != "/var/log/api-1.log" || alerts[1].File != "/var/log/app.log" {
+		t.Fatalf("alerts %+v, expected api-1.log and app.log", alerts)
+	}
+	if alerts[0].Count != 3 || alerts[1].Count != 8 || alerts[1].State != StateInactive {
+		t.Errorf("unexpected alerts %+v", alerts)
+	}
+	if samples := alerts[1].Samples; len(samples) != maxSamples || samples[maxSamples-1] != "FATAL sample h" {
+		t.Errorf("samples %q, expected the latest %d lines", samples, maxSamples)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    Rule
+		invalid bool
+	}{
+		{name: "valid", rule: Rule{Name: "a", Files: "*.log", Filter: "ERROR"}},
+		{name: "missing name", rule: Rule{Name: " ", Files: "*.log", Filter: "ERROR"}, invalid: true},
+		{name: "missing files", rule: Rule{Name: "a", Filter: "ERROR"}, invalid: true},
+		{name: "invalid glob", rule: Rule{Name: "a", Files: "[", Filter: "ERROR"}, invalid: true},
+		{name: "missing criteria", rule: Rule{Name: "a", Files: "*.log"}, invalid: true},
+		{name: "invalid level", rule: Rule{Name: "a", Files: "*.log", Level: "verbose"}, invalid: true},
+		{name: "invalid query", rule: Rule{Name: "a", Files: "*.log", Query: "latency>"}, invalid: true},
+		{name: "negative threshold", rule: Rule{Name: "a", Files: "*.log", Filter: "ERROR", Threshold: -1}, invalid: true},
+		{name: "invalid webhook", rule: Rule{Name: "a", Files: "*.log", Filter: "ERROR", Webhooks: []string{"ftp://host"}}, invalid: true},
+	}
+	for _, test := range tests {
+		rule := test.rule
+		err := rule.Validate()
+		if test.invalid != (err != nil) {
+			t.Errorf("%s: Validate() error = %v, expected invalid %v", test.name, err, test.invalid)
+		}
+		if err == nil && rule.Window != Duration(time.Minute) {
+			t.Errorf("%s: window %s, expected the default of 1m", test.name, time.Duration(rule.Window))
+		}
+	}
+}
+
+func TestPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "alerts.json")
+	defaults := Configuration{Webhooks: []string{"http://127.0.0.1:9000/hook"}, Rules: []Rule{{Name: "errors", Files: "*.log", Filter: "ERROR"}}}
+	e, err := LoadEngine(path, defaults, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("the file have to be created on the first change (%v)", err)
+	}
+	if _, err = e.CreateRule(Rule{Name: "oom", Files: "app.log", Filter: "out of memory", Threshold: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = e.UpdateRule("errors", Rule{Name: "errors", Files: "*.log", Level: "error"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = e.SilenceRule("oom", start.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The rules saved in the file win on the default ones after the restart
+	restarted, err := LoadEngine(path, Configuration{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules := restarted.Rules()
+	if len(rules) != 2 || rules[0].Name != "errors" || rules[0].Level != "error" || rules[0].Filter != "" ||
+		rules[1].Name != "oom" || rules[1].Threshold != 2 || !rules[1].SilencedUntil.Equal(start.Add(time.Hour)) {
+		t.Errorf("rules %+v, expected the ones saved before the restart", rules)
+	}
+	if err = restarted.DeleteRule("errors"); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := LoadConfiguration(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Rules) != 1 || cfg.Rules[0].Name != "oom" || len(cfg.Webhooks) != 1 {
+		t.Errorf("saved %+v, expected only the oom rule with the webhooks", cfg)
+	}
+}
+
+func TestPersistenceErrors(t *testing.T) {
+	if _, err := LoadEngine("", Configuration{}, nil); err != ErrNoFile {
+		t.Errorf("LoadEngine() error = %v, expected %v", err, ErrNoFile)
+	}
+	rule := Rule{Name: "errors", Files: "*.log", Filter: "ERROR"}
+
+	// The engines without file refuse every change
+	e, err := NewEngine(Configuration{Rules: []Rule{rule}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = e.CreateRule(Rule{Name: "oom", Files: "*.log", Filter: "oom"}); err != ErrNoFile {
+		t.Errorf("CreateRule() error = %v, expected %v", err, ErrNoFile)
+	}
+	if _, err = e.UpdateRule("errors", rule); err != ErrNoFile {
+		t.Errorf("UpdateRule() error = %v, expected %v", err, ErrNoFile)
+	}
+	if _, err = e.SilenceRule("errors", start); err != ErrNoFile {
+		t.Errorf("SilenceRule() error = %v, expected %v", err, ErrNoFile)
+	}
+	if err = e.DeleteRule("errors"); err != ErrNoFile {
+		t.Errorf("DeleteRule() error = %v, expected %v", err, ErrNoFile)
+	}
+	if rules := e.Rules(); len(rules) != 1 || rules[0].Name != "errors" {
+		t.Errorf("rules %+v, expected the rules unchanged", rules)
+	}
+
+	// The changes that can't be written are reported
+	e, err = LoadEngine(filepath.Join(t.TempDir(), "missing", "alerts.json"), Configuration{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = e.CreateRule(rule); err == nil {
+		t.Error("CreateRule() expected an error")
+	} else if _, ok := err.(*PersistError); !ok {
+		t.Errorf("CreateRule() error = %v, expected a PersistError", err)
+	}
+
+	// An invalid file is not replaced by the default rules
+	path := filepath.Join(t.TempDir(), "alerts.json")
+	if err = ioutil.WriteFile(path, []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = LoadEngine(path, Configuration{Rules: []Rule{rule}}, nil); err == nil {
+		t.Error("LoadEngine() expected an error for the invalid file")
+	}
+}
+
+func TestEvaluateSamples(t *testing.T) {
+	receiver := newWebhook()
+	defer receiver.Close()
+	e := newEngine(t, Rule{Name: "errors", Files: "app.log", Filter: "ERROR", Window: Duration(time.Minute)}, receiver.URL)
+	steps := []struct {
+		offset  time.Duration
+		lines   []string // Lines observed before the evaluation
+		state   State
+		samples []string // Samples of the notification
+	}{
+		{offset: 0, lines: []string{"ERROR first", "INFO ok", "ERROR second"}, state: StateFiring, samples: []string{"ERROR first", "ERROR second"}},
+		{offset: 30 * time.Second, lines: []string{"ERROR third"}},
+		{offset: 2 * time.Minute, state: StateResolved, samples: []string{"ERROR first", "ERROR second", "ERROR third"}},
+		{offset: 3 * time.Minute, lines: []string{"ERROR fourth"}, state: StateFiring, samples: []string{"ERROR fourth"}}, // New episode
+	}
+	for i, step := range steps {
+		var lines [][]byte
+		for _, line := range step.lines {
+			lines = append(lines, []byte(line))
+		}
+		e.Observe("app.log", lines, start.Add(step.offset))
+		e.Evaluate(start.Add(step.offset))
+		if step.state == "" {
+			continue
+		}
+		notification := receiver.next(t)
+		if notification.State != step.state || strings.Join(notification.Samples, "|") != strings.Join(step.samples, "|") {
+			t.Errorf("step %d: %s with samples %q, expected %s with %q", i, notification.State, notification.Samples, step.state, step.samples)
+		}
+	}
+	if samples := e.Alerts()[0].Samples; strings.Join(samples, "|") != "ERROR fourth" {
+		t.Errorf("samples %q, expected only the ones of the new episode", samples)
+	}
+
+	// The samples of the new episode are limited too
+	e = newEngine(t, Rule{Name: "errors", Files: "app.log", Filter: "ERROR", Window: Duration(time.Minute), Threshold: 6})
+	e.Observe("app.log", lines(4, "ERROR old"), start)
+	e.Evaluate(start.Add(2 * time.Minute))
+	for i := 0; i < 7; i++ {
+		e.Observe("app.log", [][]byte{[]byte("ERROR new " + string(rune('a'+i)))}, start.Add(3*time.Minute))
+	}
+	e.Evaluate(start.Add(3 * time.Minute))
+	if samples := e.Alerts()[0].Samples; len(samples) != maxSamples || samples[0] != "ERROR new c" || samples[maxSamples-1] != "ERROR new g" {
+		t.Errorf("samples %q, expected the latest %d lines of the new episode", samples, maxSamples)
+	}
+}
+
+func TestNilEngine(t *testing.T) {
+	var e *Engine
+	e.Observe("app.log", lines(1, "ERROR"), start)
+	e.Evaluate(start)
+	if len(e.Alerts()) != 0 || len(e.Rules()) != 0 {
+		t.Error("expected no alerts and no rules")
+	}
+	if _, err := e.Rule("errors"); err != ErrRuleNotFound {
+		t.Errorf("Rule() error = %v, expected %v", err, ErrRuleNotFound)
+	}
+	if _, err := e.History("errors"); err != ErrRuleNotFound {
+		t.Errorf("History() error = %v, expected %v", err, ErrRuleNotFound)
+	}
+	rule := Rule{Name: "errors", Files: "*.log", Filter: "ERROR"}
+	if _, err := e.CreateRule(rule); err != ErrNoFile {
+		t.Errorf("CreateRule() error = %v, expected %v", err, ErrNoFile)
+	}
+	if _, err := e.UpdateRule("errors", rule); err != ErrNoFile {
+		t.Errorf("UpdateRule() error = %v, expected %v", err, ErrNoFile)
+	}
+	if _, err := e.SilenceRule("errors", start); err != ErrNoFile {
+		t.Errorf("SilenceRule() error = %v, expected %v", err, ErrNoFile)
+	}
+	if err := e.DeleteRule("errors"); err != ErrNoFile {
+		t.Errorf("DeleteRule() error = %v, expected %v", err, ErrNoFile)
+	}
+}
+
+func TestWait(t *testing.T) {
+	release := make(chan struct{})
+	received := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		received <- struct{}{}
+		<-release
+	}))
+	defer server.Close()
+	e := newEngine(t, Rule{Name: "oom", Files: "app.log", Filter: "out of memory"}, server.URL)
+	if !e.Wait(time.Millisecond) {
+		t.Fatal("Wait() = false without notifications")
+	}
+	e.Observe("/var/log/app.log", lines(1, "FATAL out of memory"), start)
+	e.Evaluate(start)
+	select {
+	case <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("notification not received")
+	}
+	if e.Wait(50 * time.Millisecond) {
+		t.Error("Wait() = true while the webhook is still answering")
+	}
+	close(release)
+	if !e.Wait(5 * time.Second) {
+		t.Error("Wait() = false after the webhook answered")
+	}
+	var nilEngine *Engine
+	if !nilEngine.Wait(time.Millisecond) {
+		t.Error("Wait() = false for a nil engine")
+	}
+}
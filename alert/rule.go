@@ -0,0 +1,159 @@
+// Package alert implements the rule engine that watch the new lines of the log files and notify the configured webhooks.
+//
+// A rule count the lines that satisfy its criteria (filter, level, query) in a sliding window, for every file that match the rule.
+// When the count is greater than the threshold the alert become pending, and firing if the condition hold for the "For" duration.
+// When the condition is no more satisfied a firing alert become resolved. The webhooks are notified only when the alert start
+// firing and when it's resolved (optionally every RepeatInterval while firing).
+package alert
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/alessiosavi/GoLog-Viewer/parser"
+	"github.com/alessiosavi/GoLog-Viewer/query"
+)
+
+// Duration is a time.Duration that is (un)marshalled as string (1m, 30s)
+type Duration time.Duration
+
+// MarshalJSON encode the duration as string
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// UnmarshalJSON decode a duration expressed as string or as number of seconds
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	switch v := value.(type) {
+	case string:
+		parsed, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		*d = Duration(parsed)
+	case float64:
+		*d = Duration(time.Duration(v * float64(time.Second)))
+	default:
+		return fmt.Errorf("invalid duration %s", data)
+	}
+	return nil
+}
+
+// Rule is the definition of an alert
+type Rule struct {
+	Name           string    `json:"Name"`           // Unique name of the rule
+	Files          string    `json:"Files"`          // Comma separated list of glob of the files watched by the rule
+	Filter         string    `json:"Filter"`         // Text that the line have to contains
+	IgnoreCase     bool      `json:"IgnoreCase"`     // Search the text ignoring the case
+	Level          string    `json:"Level"`          // Level filter (error, warn+)
+	Query          string    `json:"Query"`          // Query on the fields of the line
+	Threshold      int       `json:"Threshold"`      // The alert is active when the number of lines in the window is greater than the threshold
+	Window         Duration  `json:"Window"`         // Size of the sliding window (default 1m)
+	For            Duration  `json:"For"`            // How long the condition have to hold before firing (0 = fire immediately)
+	RepeatInterval Duration  `json:"RepeatInterval"` // Resend the notification while firing (0 = never)
+	Webhooks       []string  `json:"Webhooks"`       // Webhooks of the rule, the global ones are used if empty
+	SilencedUntil  time.Time `json:"SilencedUntil"`  // The notifications are suppressed until this time
+}
+
+// Configuration is the content of the alerting configuration file
+type Configuration struct {
+	Webhooks []string `json:"Webhooks"` // Default webhooks, notified for every rule without its own webhooks
+	Rules    []Rule   `json:"Rules"`
+}
+
+// LoadConfiguration read the (json) alerting configuration from the given file
+func LoadConfiguration(path string) (Configuration, error) {
+	var cfg Configuration
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+	if err = json.Unmarshal(data, &cfg); err != nil {
+		return cfg, errors.New("unable to parse " + path + ": " + err.Error())
+	}
+	return cfg, nil
+}
+
+// Validate verify the rule and set the default values
+func (r *Rule) Validate() error {
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" {
+		return errors.New("the name of the rule is mandatory")
+	}
+	if strings.TrimSpace(r.Files) == "" {
+		return fmt.Errorf("rule %s: Files is mandatory", r.Name)
+	}
+	for _, glob := range strings.Split(r.Files, ",") {
+		if _, err := filepath.Match(strings.TrimSpace(glob), ""); err != nil {
+			return fmt.Errorf("rule %s: invalid glob %q", r.Name, glob)
+		}
+	}
+	if r.Filter == "" && r.Level == "" && r.Query == "" {
+		return fmt.Errorf("rule %s: at least one of Filter, Level, Query is mandatory", r.Name)
+	}
+	if _, err := parser.ParseLevelFilter(r.Level); err != nil {
+		return fmt.Errorf("rule %s: %s", r.Name, err)
+	}
+	if _, err := query.Parse(r.Query); err != nil {
+		return fmt.Errorf("rule %s: invalid query: %s", r.Name, err)
+	}
+	if r.Threshold < 0 || r.Window < 0 || r.For < 0 || r.RepeatInterval < 0 {
+		return fmt.Errorf("rule %s: Threshold, Window, For and RepeatInterval can't be negative", r.Name)
+	}
+	if r.Window == 0 {
+		r.Window = Duration(time.Minute)
+	}
+	for _, webhook := range r.Webhooks {
+		if err := validateWebhook(webhook); err != nil {
+			return fmt.Errorf("rule %s: %s", r.Name, err)
+		}
+	}
+	return nil
+}
+
+func validateWebhook(webhook string) error {
+	u, err := url.Parse(webhook)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid webhook %q", webhook)
+	}
+	return nil
+}
+
+// filter compile the criteria of the rule
+func (r *Rule) filter(lineParser *parser.Parser) (query.Filter, error) {
+	filter := query.Filter{Text: r.Filter, IgnoreCase: r.IgnoreCase, Parser: lineParser}
+	var err error
+	if filter.Level, err = parser.ParseLevelFilter(r.Level); err != nil {
+		return filter, err
+	}
+	filter.Query, err = query.Parse(r.Query)
+	return filter, err
+}
+
+// matchFile verify if the file is watched by the rule
+func (r *Rule) matchFile(path string) bool {
+	name := filepath.Base(path)
+	for _, glob := range strings.Split(r.Files, ",") {
+		glob = strings.TrimSpace(glob)
+		if glob == path || glob == name {
+			return true
+		}
+		if ok, _ := filepath.Match(glob, path); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(glob, name); ok {
+			return true
+		}
+	}
+	return false
+}
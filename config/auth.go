@@ -0,0 +1,94 @@
+package config
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Credentials are the accepted credentials of the requests. The authentication is disabled when no credential is defined
+type Credentials struct {
+	Tokens []string          `json:"Tokens"` // Accepted tokens (Authorization: Bearer <token>)
+	Users  map[string]string `json:"Users"`  // Bcrypt hash of the password of every user (Authorization: Basic)
+}
+
+// Validate verify the credentials
+func (c Credentials) Validate() error {
+	var invalid []string
+	for _, token := range c.Tokens {
+		if strings.TrimSpace(token) == "" {
+			invalid = append(invalid, "empty token")
+		}
+	}
+	for user, hash := range c.Users {
+		if user == "" || strings.Contains(user, ":") {
+			invalid = append(invalid, "invalid user "+user)
+		}
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			invalid = append(invalid, "the password of "+user+" have to be the bcrypt hash of the password")
+		}
+	}
+	if len(invalid) > 0 {
+		return errors.New(strings.Join(invalid, "; "))
+	}
+	return nil
+}
+
+// Auth verify the credentials of the requests. It's safe for concurrent use, the credentials can be replaced at runtime
+type Auth struct {
+	mutex       sync.RWMutex
+	credentials Credentials
+}
+
+// Set replace the accepted credentials
+func (a *Auth) Set(credentials Credentials) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.credentials = credentials
+}
+
+// Enabled verify if at least a credential is defined
+func (a *Auth) Enabled() bool {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	return len(a.credentials.Tokens) > 0 || len(a.credentials.Users) > 0
+}
+
+// Authorize verify the Authorization header of the request. Every request is authorized when the authentication is disabled
+func (a *Auth) Authorize(authorization string) bool {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	if len(a.credentials.Tokens) == 0 && len(a.credentials.Users) == 0 {
+		return true
+	}
+	tokens := strings.SplitN(authorization, " ", 2)
+	if len(tokens) != 2 {
+		return false
+	}
+	switch strings.ToLower(tokens[0]) {
+	case "bearer":
+		authorized := false
+		for _, token := range a.credentials.Tokens { // Every token is compared in order to not leak the position of the valid one
+			if subtle.ConstantTimeCompare([]byte(token), []byte(tokens[1])) == 1 {
+				authorized = true
+			}
+		}
+		return authorized
+	case "basic":
+		decoded, err := base64.StdEncoding.DecodeString(tokens[1])
+		if err != nil {
+			return false
+		}
+		user := strings.SplitN(string(decoded), ":", 2)
+		hash, ok := a.credentials.Users[user[0]]
+		if !ok || len(user) != 2 {
+			return false
+		}
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte(user[1])) == nil
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func basic(credentials string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials))
+}
+
+func TestAuthorize(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var auth Auth
+	if !auth.Authorize("") || auth.Enabled() {
+		t.Error("every request have to be authorized without credentials")
+	}
+	auth.Set(Credentials{Tokens: []string{"token-1", "token-2"}, Users: map[string]string{"admin": string(hash)}})
+	if !auth.Enabled() {
+		t.Error("the authentication have to be enabled")
+	}
+	tests := []struct {
+		name          string
+		authorization string
+		authorized    bool
+	}{
+		{name: "missing", authorization: ""},
+		{name: "bearer", authorization: "Bearer token-2", authorized: true},
+		{name: "scheme case", authorization: "bearer token-1", authorized: true},
+		{name: "invalid token", authorization: "Bearer token-3"},
+		{name: "token prefix", authorization: "Bearer token-"},
+		{name: "basic", authorization: basic("admin:secret"), authorized: true},
+		{name: "wrong password", authorization: basic("admin:wrong")},
+		{name: "unknown user", authorization: basic("guest:secret")},
+		{name: "without colon", authorization: basic("admin")},
+		{name: "password as hash", authorization: basic("admin:" + string(hash))},
+		{name: "malformed base64", authorization: "Basic !!!"},
+		{name: "unknown scheme", authorization: "Digest token-1"},
+		{name: "without scheme", authorization: "token-1"},
+	}
+	for _, test := range tests {
+		if authorized := auth.Authorize(test.authorization); authorized != test.authorized {
+			t.Errorf("%s: Authorize(%q) = %v, expected %v", test.name, test.authorization, authorized, test.authorized)
+		}
+	}
+}
+
+func TestValidateCredentials(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name        string
+		credentials Credentials
+		invalid     bool
+	}{
+		{name: "empty", credentials: Credentials{}},
+		{name: "valid", credentials: Credentials{Tokens: []string{"token"}, Users: map[string]string{"admin": string(hash)}}},
+		{name: "empty token", credentials: Credentials{Tokens: []string{" "}}, invalid: true},
+		{name: "invalid user", credentials: Credentials{Users: map[string]string{"ad:min": string(hash)}}, invalid: true},
+		{name: "plain password", credentials: Credentials{Users: map[string]string{"admin": "secret"}}, invalid: true},
+		{name: "sha256 hash", credentials: Credentials{Users: map[string]string{"admin": "2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b"}}, invalid: true},
+	}
+	for _, test := range tests {
+		if err := test.credentials.Validate(); test.invalid != (err != nil) {
+			t.Errorf("%s: Validate() error = %v, expected invalid %v", test.name, err, test.invalid)
+		}
+	}
+}
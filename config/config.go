@@ -0,0 +1,244 @@
+package config
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Settings are the options of the tool that can be changed at runtime
+type Settings struct {
+	MinLinesToPrint  int            `json:"MinLinesToPrint"`  // Number of lines of every file saved in memory
+	MaxLinesToSearch int            `json:"MaxLinesToSearch"` // Max lines of the file evaluated by the search
+	Sleep            int            `json:"Sleep"`            // Seconds to sleep among every scan of the files
+	GCSleep          int            `json:"GCSleep"`          // Minutes to sleep among every manual garbage collection
+	MaxBytes         int            `json:"MaxBytes"`         // Max size (bytes) of the lines of every file saved in memory, 0 for no limit
+	Include          []string       `json:"Include"`          // Path/glob of the managed files (every file if empty)
+	Exclude          []string       `json:"Exclude"`          // Path/glob of the ignored files, applied after Include
+	Files            []FileSettings `json:"Files"`            // Settings of specific files, the first matching entry is applied
+}
+
+// FileSettings override the global settings for the files that match the path/glob. The zero values inherit the global ones
+type FileSettings struct {
+	Files            string `json:"Files"` // Comma separated list of path/glob
+	MinLinesToPrint  int    `json:"MinLinesToPrint,omitempty"`
+	MaxLinesToSearch int    `json:"MaxLinesToSearch,omitempty"`
+	Sleep            int    `json:"Sleep,omitempty"`    // Seconds among every check of the file
+	MaxBytes         int    `json:"MaxBytes,omitempty"` // Max size (bytes) of the lines saved in memory
+}
+
+// Limits are the settings applied to a file, the global ones overridden by the settings of the file
+type Limits struct {
+	MinLinesToPrint  int `json:"MinLinesToPrint"`
+	MaxLinesToSearch int `json:"MaxLinesToSearch"`
+	Sleep            int `json:"Sleep"`
+	MaxBytes         int `json:"MaxBytes"` // 0 for no limit
+}
+
+// SameLines verify if the limits keep the same lines in memory. The other limits (lines searched, interval among the checks)
+// can change without read again the file
+func (l Limits) SameLines(other Limits) bool {
+	return l.MinLinesToPrint == other.MinLinesToPrint && l.MaxBytes == other.MaxBytes
+}
+
+// Change is a setting that differ between two configurations
+type Change struct {
+	Field  string      `json:"Field"`
+	Before interface{} `json:"Before"`
+	After  interface{} `json:"After"`
+}
+
+// Diff is the result of a change of the settings
+type Diff struct {
+	Before  Settings `json:"Before"`
+	After   Settings `json:"After"`
+	Changes []Change `json:"Changes"`
+	Applied bool     `json:"Applied"` // False for the dry run and when nothing changed
+}
+
+// Validate verify the settings, every invalid setting is reported in the error
+func (s Settings) Validate() error {
+	var invalid []string
+	positive := func(name string, value int) {
+		if value < 1 {
+			invalid = append(invalid, name+" have to be greater than 0")
+		}
+	}
+	positive("MinLinesToPrint", s.MinLinesToPrint)
+	positive("MaxLinesToSearch", s.MaxLinesToSearch)
+	positive("Sleep", s.Sleep)
+	positive("GCSleep", s.GCSleep)
+	if s.MaxBytes < 0 {
+		invalid = append(invalid, "MaxBytes can't be negative")
+	}
+	for _, list := range [][]string{s.Include, s.Exclude} {
+		for _, pattern := range list {
+			if _, err := filepath.Match(pattern, ""); err != nil || strings.TrimSpace(pattern) == "" {
+				invalid = append(invalid, "invalid glob "+pattern)
+			}
+		}
+	}
+	for _, file := range s.Files {
+		if strings.TrimSpace(file.Files) == "" {
+			invalid = append(invalid, "Files have to contain the path/glob of the files")
+		}
+		for _, pattern := range strings.Split(file.Files, ",") {
+			if _, err := filepath.Match(strings.TrimSpace(pattern), ""); err != nil {
+				invalid = append(invalid, "invalid glob "+pattern+" for the files settings")
+			}
+		}
+		if file.MinLinesToPrint < 0 || file.MaxLinesToSearch < 0 || file.Sleep < 0 || file.MaxBytes < 0 {
+			invalid = append(invalid, "the settings of "+file.Files+" can't be negative")
+		}
+	}
+	if len(invalid) > 0 {
+		return errors.New(strings.Join(invalid, "; "))
+	}
+	return nil
+}
+
+// Managed verify if the file is selected by the include/exclude rules
+func (s Settings) Managed(path string) bool {
+	return (len(s.Include) == 0 || matchAny(s.Include, path)) && !matchAny(s.Exclude, path)
+}
+
+// Limits return the settings applied to the file
+func (s Settings) Limits(path string) Limits {
+	limits := Limits{MinLinesToPrint: s.MinLinesToPrint, MaxLinesToSearch: s.MaxLinesToSearch, Sleep: s.Sleep, MaxBytes: s.MaxBytes}
+	file, ok := s.file(path)
+	if !ok {
+		return limits
+	}
+	if file.MinLinesToPrint > 0 {
+		limits.MinLinesToPrint = file.MinLinesToPrint
+	}
+	if file.MaxLinesToSearch > 0 {
+		limits.MaxLinesToSearch = file.MaxLinesToSearch
+	}
+	if file.Sleep > 0 {
+		limits.Sleep = file.Sleep
+	}
+	if file.MaxBytes > 0 {
+		limits.MaxBytes = file.MaxBytes
+	}
+	return limits
+}
+
+// file return the first settings that match the file
+func (s Settings) file(path string) (FileSettings, bool) {
+	for _, file := range s.Files {
+		if matchAny(strings.Split(file.Files, ","), path) {
+			return file, true
+		}
+	}
+	return FileSettings{}, false
+}
+
+// matchAny verify if one of the path/glob match the full path or the name of the file
+func matchAny(patterns []string, path string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == path || pattern == filepath.Base(path) {
+			return true
+		}
+		matchPath, _ := filepath.Match(pattern, path)
+		matchName, _ := filepath.Match(pattern, filepath.Base(path))
+		if matchPath || matchName {
+			return true
+		}
+	}
+	return false
+}
+
+// clone return a copy of the settings that does not share the lists
+func (s Settings) clone() Settings {
+	s.Include = append([]string(nil), s.Include...)
+	s.Exclude = append([]string(nil), s.Exclude...)
+	s.Files = append([]FileSettings(nil), s.Files...)
+	return s
+}
+
+// Compare return the settings that differ between the two configurations
+func Compare(before, after Settings) []Change {
+	changes := []Change{}
+	b, a := reflect.ValueOf(before), reflect.ValueOf(after)
+	for i := 0; i < b.NumField(); i++ {
+		if !reflect.DeepEqual(b.Field(i).Interface(), a.Field(i).Interface()) {
+			changes = append(changes, Change{Field: b.Type().Field(i).Name, Before: b.Field(i).Interface(), After: a.Field(i).Interface()})
+		}
+	}
+	return changes
+}
+
+// Merge apply to the settings only the fields changed between the two configurations, the other fields are kept
+func (s *Settings) Merge(before, after Settings) {
+	after = after.clone()
+	target, b, a := reflect.ValueOf(s).Elem(), reflect.ValueOf(before), reflect.ValueOf(after)
+	for i := 0; i < b.NumField(); i++ {
+		if !reflect.DeepEqual(b.Field(i).Interface(), a.Field(i).Interface()) {
+			target.Field(i).Set(a.Field(i))
+		}
+	}
+}
+
+// Store contains the active settings. It's safe for concurrent use: every change is validated and applied atomically
+type Store struct {
+	mutex    sync.RWMutex
+	settings Settings
+	version  int
+	changed  chan struct{}
+}
+
+// NewStore return a store that contains the given (valid) settings
+func NewStore(settings Settings) (*Store, error) {
+	if err := settings.Validate(); err != nil {
+		return nil, err
+	}
+	return &Store{settings: settings.clone(), changed: make(chan struct{}, 1)}, nil
+}
+
+// Changed return the channel notified when the settings change. The notifications are coalesced, the channel is meant for a single consumer
+func (s *Store) Changed() <-chan struct{} {
+	return s.changed
+}
+
+// Get return a copy of the active settings
+func (s *Store) Get() Settings {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.settings.clone()
+}
+
+// Version return the number of changes applied since the creation of the store
+func (s *Store) Version() int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.version
+}
+
+// Update apply the change to a copy of the active settings. The copy replace the active settings only if the change succeed
+// and the result is valid, with dryRun the result is only validated
+func (s *Store) Update(change func(settings *Settings) error, dryRun bool) (Diff, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	diff := Diff{Before: s.settings.clone(), After: s.settings.clone()}
+	if err := change(&diff.After); err != nil {
+		return diff, err
+	}
+	if err := diff.After.Validate(); err != nil {
+		return diff, err
+	}
+	diff.Changes = Compare(diff.Before, diff.After)
+	if !dryRun && len(diff.Changes) > 0 {
+		s.settings = diff.After.clone()
+		s.version++
+		diff.Applied = true
+		select {
+		case s.changed <- struct{}{}:
+		default: // A notification is already pending
+		}
+	}
+	return diff, nil
+}
@@ -0,0 +1,288 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func defaults() Settings {
+	return Settings{MinLinesToPrint: 200, MaxLinesToSearch: 100000, Sleep: 15, GCSleep: 5}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(s *Settings)
+		invalid []string // Parts of the error, one for every invalid setting
+	}{
+		{name: "defaults", change: func(s *Settings) {}},
+		{name: "lists", change: func(s *Settings) {
+			s.Include, s.Exclude = []string{"*.log", "/var/log/app/*"}, []string{"*.gz"}
+			s.Files = []FileSettings{{Files: "debug*.log, audit.log", MinLinesToPrint: 50, MaxBytes: 1 << 20}}
+		}},
+		{name: "zero", change: func(s *Settings) { s.MinLinesToPrint, s.Sleep = 0, 0 }, invalid: []string{"MinLinesToPrint", "Sleep"}},
+		{name: "negative", change: func(s *Settings) { s.MaxLinesToSearch, s.GCSleep, s.MaxBytes = -1, -1, -1 },
+			invalid: []string{"MaxLinesToSearch", "GCSleep", "MaxBytes"}},
+		{name: "invalid glob", change: func(s *Settings) { s.Exclude = []string{"[a-"} }, invalid: []string{"invalid glob [a-"}},
+		{name: "empty glob", change: func(s *Settings) { s.Include = []string{" "} }, invalid: []string{"invalid glob"}},
+		{name: "file without glob", change: func(s *Settings) { s.Files = []FileSettings{{MinLinesToPrint: 10}} }, invalid: []string{"Files have to contain"}},
+		{name: "file with invalid glob", change: func(s *Settings) { s.Files = []FileSettings{{Files: "a.log,[", Sleep: 1}} },
+			invalid: []string{"invalid glob [ for the files"}},
+		{name: "file negative", change: func(s *Settings) { s.Files = []FileSettings{{Files: "a.log", MaxBytes: -1}} }, invalid: []string{"a.log can't be negative"}},
+	}
+	for _, test := range tests {
+		settings := defaults()
+		test.change(&settings)
+		err := settings.Validate()
+		if len(test.invalid) == 0 {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", test.name, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("%s: expected an error", test.name)
+			continue
+		}
+		for _, part := range test.invalid {
+			if !strings.Contains(err.Error(), part) {
+				t.Errorf("%s: error %q does not report %q", test.name, err, part)
+			}
+		}
+	}
+}
+
+func TestNewStore(t *testing.T) {
+	if _, err := NewStore(Settings{}); err == nil {
+		t.Error("expected an error for the invalid settings")
+	}
+	store, err := NewStore(defaults())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if store.Version() != 0 || store.Get().MinLinesToPrint != 200 {
+		t.Errorf("unexpected store %+v (version %d)", store.Get(), store.Version())
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(s *Settings) error
+		dryRun  bool
+		invalid bool
+		changes []string // Fields changed
+		applied bool
+	}{
+		{name: "change", change: func(s *Settings) error { s.MinLinesToPrint = 500; return nil }, changes: []string{"MinLinesToPrint"}, applied: true},
+		{name: "lists", change: func(s *Settings) error {
+			s.Exclude = []string{"*.gz"}
+			s.Files = []FileSettings{{Files: "a.log", Sleep: 60}}
+			return nil
+		}, changes: []string{"Exclude", "Files"}, applied: true},
+		{name: "dry run", change: func(s *Settings) error { s.Sleep = 30; return nil }, dryRun: true, changes: []string{"Sleep"}},
+		{name: "nothing changed", change: func(s *Settings) error { s.Sleep = 15; return nil }, changes: []string{}},
+		{name: "invalid", change: func(s *Settings) error { s.Sleep, s.MinLinesToPrint = 0, 10; return nil }, invalid: true},
+		{name: "failed change", change: func(s *Settings) error { s.MinLinesToPrint = 10; return errors.New("invalid json") }, invalid: true},
+	}
+	for _, test := range tests {
+		store, err := NewStore(defaults())
+		if err != nil {
+			t.Fatal(err)
+		}
+		diff, err := store.Update(test.change, test.dryRun)
+		if test.invalid != (err != nil) {
+			t.Errorf("%s: Update() error = %v, expected invalid %v", test.name, err, test.invalid)
+			continue
+		}
+		version, notified := store.Version(), false
+		select {
+		case <-store.Changed():
+			notified = true
+		default:
+		}
+		if test.invalid || !test.applied {
+			if diff.Applied || version != 0 || notified || store.Get().MinLinesToPrint != 200 || store.Get().Sleep != 15 {
+				t.Errorf("%s: the settings have to be unchanged (applied %v, version %d, notified %v, %+v)", test.name, diff.Applied, version, notified, store.Get())
+			}
+			if test.invalid {
+				continue
+			}
+		} else if !diff.Applied || version != 1 || !notified {
+			t.Errorf("%s: the settings have to be applied (applied %v, version %d, notified %v)", test.name, diff.Applied, version, notified)
+		}
+		var fields []string
+		for _, change := range diff.Changes {
+			fields = append(fields, change.Field)
+		}
+		if strings.Join(fields, ",") != strings.Join(test.changes, ",") {
+			t.Errorf("%s: changes %v, expected %v", test.name, fields, test.changes)
+		}
+		if diff.Before.MinLinesToPrint != 200 || diff.Before.Sleep != 15 {
+			t.Errorf("%s: unexpected settings before the change %+v", test.name, diff.Before)
+		}
+	}
+}
+
+func TestUpdateDiff(t *testing.T) {
+	store, err := NewStore(defaults())
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff, err := store.Update(func(s *Settings) error { s.MaxBytes = 1024; return nil }, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.Changes) != 1 || diff.Changes[0].Before != 0 || diff.Changes[0].After != 1024 || diff.After.MaxBytes != 1024 {
+		t.Errorf("unexpected diff %+v", diff)
+	}
+	// The notifications are coalesced
+	if _, err = store.Update(func(s *Settings) error { s.MaxBytes = 2048; return nil }, false); err != nil {
+		t.Fatal(err)
+	}
+	<-store.Changed()
+	select {
+	case <-store.Changed():
+		t.Error("expected a single pending notification")
+	default:
+	}
+	if store.Version() != 2 {
+		t.Errorf("version %d, expected 2", store.Version())
+	}
+}
+
+func TestGetCopy(t *testing.T) {
+	settings := defaults()
+	settings.Exclude = []string{"*.gz"}
+	store, err := NewStore(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	settings.Exclude[0] = "changed"
+	copied := store.Get()
+	copied.Exclude[0] = "changed"
+	if store.Get().Exclude[0] != "*.gz" {
+		t.Error("the store have to be isolated from the settings of the callers")
+	}
+}
+
+func TestLimits(t *testing.T) {
+	settings := defaults()
+	settings.MaxBytes = 1024
+	settings.Files = []FileSettings{{Files: "debug*.log, /var/log/audit.log", MinLinesToPrint: 50, Sleep: 60}, {Files: "debug-1.log", MinLinesToPrint: 10}}
+	tests := []struct {
+		path   string
+		limits Limits
+	}{
+		{path: "/var/log/app.log", limits: Limits{MinLinesToPrint: 200, MaxLinesToSearch: 100000, Sleep: 15, MaxBytes: 1024}},
+		{path: "/var/log/debug-1.log", limits: Limits{MinLinesToPrint: 50, MaxLinesToSearch: 100000, Sleep: 60, MaxBytes: 1024}}, // First match
+		{path: "/var/log/audit.log", limits: Limits{MinLinesToPrint: 50, MaxLinesToSearch: 100000, Sleep: 60, MaxBytes: 1024}},
+		{path: "/srv/audit.log", limits: Limits{MinLinesToPrint: 200, MaxLinesToSearch: 100000, Sleep: 15, MaxBytes: 1024}},
+	}
+	for _, test := range tests {
+		if limits := settings.Limits(test.path); limits != test.limits {
+			t.Errorf("Limits(%s) = %+v, expected %+v", test.path, limits, test.limits)
+		}
+	}
+}
+
+func TestLimitsPrecedence(t *testing.T) {
+	// Several overrides match: only the first one is applied, its zero values inherit the global settings (not the next overrides)
+	settings := defaults()
+	settings.MaxBytes = 1024
+	settings.Files = []FileSettings{
+		{Files: "/var/log/nginx/*", Sleep: 5},
+		{Files: "*.log", MinLinesToPrint: 20, MaxBytes: 4096},
+		{Files: "access.log", MinLinesToPrint: 30, MaxLinesToSearch: 500, Sleep: 1, MaxBytes: 64},
+	}
+	tests := []struct {
+		path   string
+		limits Limits
+	}{
+		{path: "/var/log/nginx/access.log", limits: Limits{MinLinesToPrint: 200, MaxLinesToSearch: 100000, Sleep: 5, MaxBytes: 1024}},
+		{path: "/var/log/access.log", limits: Limits{MinLinesToPrint: 20, MaxLinesToSearch: 100000, Sleep: 15, MaxBytes: 4096}},
+		{path: "/srv/access.log", limits: Limits{MinLinesToPrint: 20, MaxLinesToSearch: 100000, Sleep: 15, MaxBytes: 4096}},
+		{path: "/var/log/nginx/error", limits: Limits{MinLinesToPrint: 200, MaxLinesToSearch: 100000, Sleep: 5, MaxBytes: 1024}},
+		{path: "/srv/nginx/error", limits: Limits{MinLinesToPrint: 200, MaxLinesToSearch: 100000, Sleep: 15, MaxBytes: 1024}},
+	}
+	for _, test := range tests {
+		if limits := settings.Limits(test.path); limits != test.limits {
+			t.Errorf("Limits(%s) = %+v, expected %+v", test.path, limits, test.limits)
+		}
+	}
+	settings.Files = settings.Files[2:] // Without the generic overrides the specific one is applied
+	if limits := settings.Limits("/var/log/nginx/access.log"); limits != (Limits{MinLinesToPrint: 30, MaxLinesToSearch: 500, Sleep: 1, MaxBytes: 64}) {
+		t.Errorf("Limits() = %+v, expected the settings of access.log", limits)
+	}
+}
+
+func TestSameLines(t *testing.T) {
+	limits := Limits{MinLinesToPrint: 200, MaxLinesToSearch: 1000, Sleep: 15, MaxBytes: 1024}
+	tests := []struct {
+		other Limits
+		same  bool
+	}{
+		{other: limits, same: true},
+		{other: Limits{MinLinesToPrint: 200, MaxLinesToSearch: 10, Sleep: 1, MaxBytes: 1024}, same: true},
+		{other: Limits{MinLinesToPrint: 100, MaxLinesToSearch: 1000, Sleep: 15, MaxBytes: 1024}},
+		{other: Limits{MinLinesToPrint: 200, MaxLinesToSearch: 1000, Sleep: 15}},
+	}
+	for _, test := range tests {
+		if same := limits.SameLines(test.other); same != test.same {
+			t.Errorf("SameLines(%+v) = %v, expected %v", test.other, same, test.same)
+		}
+	}
+}
+
+func TestManaged(t *testing.T) {
+	tests := []struct {
+		include, exclude []string
+		path             string
+		managed          bool
+	}{
+		{path: "/var/log/app.log", managed: true},
+		{include: []string{"*.log"}, path: "/var/log/app.log", managed: true},
+		{include: []string{"*.log"}, path: "/var/log/app.gz", managed: false},
+		{exclude: []string{"*.gz"}, path: "/var/log/app.gz", managed: false},
+		{include: []string{"/var/log/*"}, exclude: []string{"app.log"}, path: "/var/log/app.log", managed: false},
+		{include: []string{"/var/log/*"}, exclude: []string{"app.log"}, path: "/var/log/db.log", managed: true},
+	}
+	for _, test := range tests {
+		settings := Settings{Include: test.include, Exclude: test.exclude}
+		if managed := settings.Managed(test.path); managed != test.managed {
+			t.Errorf("Managed(%s) with include %v and exclude %v = %v, expected %v", test.path, test.include, test.exclude, managed, test.managed)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	before := defaults()
+	before.Exclude = []string{"*.gz"}
+	after := defaults() // The configuration file changed MinLinesToPrint and Exclude
+	after.MinLinesToPrint = 500
+	after.Exclude = []string{"*.zst"}
+
+	active := defaults() // Sleep and Exclude changed at runtime
+	active.Sleep = 60
+	active.Exclude = []string{"*.tmp"}
+	active.Merge(before, after)
+
+	if active.MinLinesToPrint != 500 || active.Sleep != 60 || active.GCSleep != 5 {
+		t.Errorf("unexpected settings %+v, expected the change of the file and the one at runtime", active)
+	}
+	if len(active.Exclude) != 1 || active.Exclude[0] != "*.zst" {
+		t.Errorf("exclude %v, the changes of the file have to win", active.Exclude)
+	}
+	after.Exclude[0] = "changed"
+	if active.Exclude[0] != "*.zst" {
+		t.Error("the merged settings have to be isolated from the ones of the file")
+	}
+
+	unchanged := defaults()
+	unchanged.Exclude = []string{"*.tmp"}
+	unchanged.Merge(before, before)
+	if len(unchanged.Exclude) != 1 || unchanged.Exclude[0] != "*.tmp" || unchanged.MinLinesToPrint != 200 {
+		t.Errorf("unexpected settings %+v, nothing changed in the file", unchanged)
+	}
+}
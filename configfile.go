@@ -0,0 +1,297 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
+	"time"
+
+	stringutils "github.com/alessiosavi/GoGPUtils/string"
+	"github.com/alessiosavi/GoLog-Viewer/alert"
+	"github.com/alessiosavi/GoLog-Viewer/config"
+	"github.com/alessiosavi/GoLog-Viewer/datastructure"
+	"github.com/alessiosavi/GoLog-Viewer/metrics"
+	"github.com/alessiosavi/GoLog-Viewer/parser"
+	utils "github.com/alessiosavi/GoUtils"
+	log "github.com/sirupsen/logrus"
+)
+
+/* ------------- CONFIGURATION FILE ------------- */
+
+// configPollInterval is the interval among the checks of the modification of the configuration file
+const configPollInterval = 5 * time.Second
+
+// defaultAlertsFile is the file of the alert rules used when -alerts is not provided
+const defaultAlertsFile = "alerts.json"
+
+// ConfigFile is the content of the (json) configuration file. The settings not present in the file keep the default value of the
+// related flag, the flags set on the command line override the file
+type ConfigFile struct {
+	Path             string                       `json:"Path"`             // Log folder
+	Port             int                          `json:"Port"`             // Port to bind the service
+	Hostname         string                       `json:"Hostname"`         // Host to bind the service
+	Patterns         string                       `json:"Patterns"`         // Json file that contains the custom grok patterns
+	Parsers          *parser.PatternConfiguration `json:"Parsers"`          // Custom grok patterns defined inline, in alternative to Patterns
+	Alerts           string                       `json:"Alerts"`           // Json file that contains the alert rules (the rules managed by the API are saved here)
+	Alerting         *alert.Configuration         `json:"Alerting"`         // Alert rules defined inline, used until the rules are saved in the Alerts file
+	Metrics          []metrics.Rule               `json:"Metrics"`          // Metrics derived from the new lines, exposed by /metrics
+	CorrelationField string                       `json:"CorrelationField"` // Field that contains the correlation id of the requests
+	VirtualFiles     []datastructure.VirtualFile  `json:"VirtualFiles"`     // Files that merge other files by timestamp
+	Snapshot         string                       `json:"Snapshot"`         // File used for save the state of the files, loaded at startup
+	SnapshotInterval int                          `json:"SnapshotInterval"` // Minutes among every save of the snapshot
+	ShutdownTimeout  int                          `json:"ShutdownTimeout"`  // Seconds to wait the requests in progress on shutdown
+	Auth             config.Credentials           `json:"Auth"`             // Credentials accepted by the API (no authentication if empty)
+	config.Settings                               // Settings that can be changed at runtime (MinLinesToPrint, MaxLinesToSearch, Sleep, GCSleep, Include, Exclude, Files)
+}
+
+// ReadConfiguration return the configuration obtained from the default value of the flags, the configuration file (if any) and the flags set
+// on the command line, in this order. Every invalid setting is reported in the error
+func ReadConfiguration(path string) (ConfigFile, error) {
+	var cfg ConfigFile
+	if err := applyFlags(&cfg, flag.VisitAll); err != nil { // Default values
+		return cfg, err
+	}
+	if path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return cfg, err
+		}
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields() // The typos are reported instead of being ignored
+		if err = decoder.Decode(&cfg); err != nil {
+			return cfg, errors.New("unable to parse " + path + ": " + err.Error())
+		}
+	}
+	if err := applyFlags(&cfg, flag.Visit); err != nil { // Flags set on the command line
+		return cfg, err
+	}
+	if cfg.Alerts == "" { // The rules managed by the API are saved next to the configuration file (or in the working directory)
+		cfg.Alerts = filepath.Join(filepath.Dir(path), defaultAlertsFile)
+	}
+	return cfg, cfg.Validate()
+}
+
+// applyFlags copy the value of the flags visited in the configuration
+func applyFlags(cfg *ConfigFile, visit func(func(*flag.Flag))) error {
+	var err error
+	visit(func(f *flag.Flag) {
+		getter, ok := f.Value.(flag.Getter)
+		if !ok {
+			return
+		}
+		switch value := getter.Get().(type) {
+		case int:
+			switch f.Name {
+			case "lines":
+				cfg.MinLinesToPrint = value
+			case "maxlines":
+				cfg.MaxLinesToSearch = value
+			case "port":
+				cfg.Port = value
+			case "sleep":
+				cfg.Sleep = value
+			case "gcSleep":
+				cfg.GCSleep = value
+			case "snapshotInterval":
+				cfg.SnapshotInterval = value
+			case "shutdownTimeout":
+				cfg.ShutdownTimeout = value
+			}
+		case string:
+			switch f.Name {
+			case "path":
+				cfg.Path = value
+			case "host":
+				cfg.Hostname = value
+			case "patterns":
+				cfg.Patterns = value
+			case "alerts":
+				cfg.Alerts = value
+			case "correlationField":
+				cfg.CorrelationField = value
+			case "snapshot":
+				cfg.Snapshot = value
+			case "virtual":
+				if cfg.VirtualFiles, err = ParseVirtualFiles(value); err != nil {
+					err = errors.New("invalid -virtual parameter: " + err.Error())
+				}
+			}
+		}
+	})
+	return err
+}
+
+// Validate verify the configuration and set the default values of the unpopulated settings
+func (cfg *ConfigFile) Validate() error {
+	var invalid []string
+	if strings.TrimSpace(cfg.Path) == "" {
+		invalid = append(invalid, "Path is mandatory (-path)")
+	} else if !utils.IsDir(cfg.Path) {
+		invalid = append(invalid, "no folder found like "+cfg.Path)
+	}
+	if cfg.MinLinesToPrint == 0 { // If no lines provided set 1000 as standard output lines
+		cfg.MinLinesToPrint = 1000
+		log.Warn("Validate | Use -lines 2000 if you want to choose to print 2000 lines")
+	}
+	if cfg.MaxLinesToSearch == 0 { // If no lines provided select 1000000 as default search lines for text
+		cfg.MaxLinesToSearch = 1000000
+		log.Warn("Validate | Use -maxlines 1000000 to choose search the text among 1000000 lines ")
+	}
+	if stringutils.IsBlank(cfg.Hostname) {
+		cfg.Hostname = "localhost" //if no host provided set localhost
+		log.Error("Validate | Use -host localhost for bind the service to 127.0.0.1 | Binded @", cfg.Hostname)
+	}
+	if cfg.Port < 0 || cfg.Port > 65535 {
+		invalid = append(invalid, "Port have to be between 0 and 65535")
+	}
+	if cfg.Patterns != "" && cfg.Parsers != nil {
+		invalid = append(invalid, "use Patterns or Parsers, not both")
+	}
+	if cfg.Parsers != nil {
+		if _, err := parser.NewParser(*cfg.Parsers); err != nil {
+			invalid = append(invalid, "Parsers: "+err.Error())
+		}
+	}
+	if cfg.Snapshot != "" && cfg.SnapshotInterval < 1 {
+		invalid = append(invalid, "SnapshotInterval have to be greater than 0")
+	}
+	if cfg.ShutdownTimeout < 1 {
+		invalid = append(invalid, "ShutdownTimeout have to be greater than 0")
+	}
+	if _, err := metrics.NewEngine(cfg.Metrics, nil, metrics.NewRegistry()); err != nil { // Verify the rules and the uniqueness of the names
+		invalid = append(invalid, err.Error())
+	}
+	for _, virtualFile := range cfg.VirtualFiles {
+		if err := ValidateVirtualFile(virtualFile); err != nil {
+			invalid = append(invalid, err.Error())
+		}
+	}
+	for _, err := range []error{cfg.Settings.Validate(), cfg.Auth.Validate()} {
+		if err != nil {
+			invalid = append(invalid, err.Error())
+		}
+	}
+	if len(invalid) > 0 {
+		return errors.New(strings.Join(invalid, "; "))
+	}
+	return nil
+}
+
+// LoadParser return the parser of the custom patterns: the ones of the Patterns file or the ones defined inline
+func (cfg ConfigFile) LoadParser() (*parser.Parser, error) {
+	if cfg.Parsers != nil {
+		return parser.NewParser(*cfg.Parsers)
+	}
+	if stringutils.IsBlank(cfg.Patterns) {
+		return nil, nil
+	}
+	return parser.LoadParser(cfg.Patterns)
+}
+
+// LoadAlertEngine return the engine of the alert rules saved in the Alerts file. The rules defined inline are used until the file is created
+func (cfg ConfigFile) LoadAlertEngine(lineParser *parser.Parser) (*alert.Engine, error) {
+	var defaults alert.Configuration
+	if cfg.Alerting != nil {
+		defaults = *cfg.Alerting
+		if _, err := os.Stat(cfg.Alerts); err == nil {
+			log.Warn("LoadAlertEngine | The rules of Alerting are ignored, the ones saved in ", cfg.Alerts, " are loaded")
+		}
+	}
+	return alert.LoadEngine(cfg.Alerts, defaults, lineParser)
+}
+
+// restartRequired return the settings that differ between the configurations and can't be applied at runtime
+func restartRequired(before, after ConfigFile) []string {
+	var changed []string
+	b, a := reflect.ValueOf(before), reflect.ValueOf(after)
+	for i := 0; i < b.NumField(); i++ {
+		name := b.Type().Field(i).Name
+		if name == "Settings" || name == "Auth" {
+			continue
+		}
+		if !reflect.DeepEqual(b.Field(i).Interface(), a.Field(i).Interface()) {
+			changed = append(changed, name)
+		}
+	}
+	return changed
+}
+
+// ReloadConfiguration read the configuration file and apply the settings that can be changed at runtime (settings and credentials).
+// Only the settings changed in the file since the last load are applied, the ones changed at runtime (/updateConfig) are kept.
+// The invalid configurations are discarded, the active one is kept
+func ReloadConfiguration(logCfg *datastructure.Configuration, active ConfigFile) (ConfigFile, error) {
+	cfg, err := ReadConfiguration(*logCfg.ConfigFile)
+	if err != nil {
+		log.Error("ReloadConfiguration | Invalid configuration ", *logCfg.ConfigFile, ", the active one is kept | Err: ", err)
+		return active, err
+	}
+	diff, err := logCfg.Settings.Update(func(settings *config.Settings) error {
+		settings.Merge(active.Settings, cfg.Settings)
+		return nil
+	}, false)
+	if err != nil {
+		log.Error("ReloadConfiguration | Unable to apply the settings | Err: ", err)
+		return active, err
+	}
+	for _, change := range diff.Changes {
+		log.Warn("ReloadConfiguration | ", change.Field, " changed from ", change.Before, " to ", change.After)
+	}
+	for _, override := range config.Compare(cfg.Settings, diff.After) {
+		log.Info("ReloadConfiguration | ", override.Field, " changed at runtime, ", override.After, " is kept instead of ", override.Before)
+	}
+	if !reflect.DeepEqual(active.Auth, cfg.Auth) {
+		logCfg.Auth.Set(cfg.Auth)
+		log.Warn("ReloadConfiguration | Credentials changed")
+	}
+	if changed := restartRequired(active, cfg); len(changed) > 0 {
+		log.Warn("ReloadConfiguration | ", strings.Join(changed, ", "), " changed, restart the service to apply the change")
+	}
+	log.Info("ReloadConfiguration | Configuration ", *logCfg.ConfigFile, " reloaded")
+	return cfg, nil
+}
+
+// WatchConfiguration reload the configuration file every time that the process receive SIGHUP or the file is modified, until the context is cancelled.
+// The connections are not interrupted, the settings are applied atomically
+func WatchConfiguration(ctx context.Context, logCfg *datastructure.Configuration, active ConfigFile) {
+	log.Trace("WatchConfiguration | START")
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+	defer signal.Stop(signals)
+	ticker := time.NewTicker(configPollInterval)
+	defer ticker.Stop()
+	modification := configModification(*logCfg.ConfigFile)
+	for {
+		select {
+		case <-ctx.Done():
+			log.Trace("WatchConfiguration | STOP")
+			return
+		case <-signals:
+			log.Info("WatchConfiguration | SIGHUP received, reloading ", *logCfg.ConfigFile)
+		case <-ticker.C:
+			if current := configModification(*logCfg.ConfigFile); current == modification || current.IsZero() {
+				continue
+			}
+			log.Info("WatchConfiguration | ", *logCfg.ConfigFile, " changed, reloading")
+		}
+		modification = configModification(*logCfg.ConfigFile)
+		active, _ = ReloadConfiguration(logCfg, active)
+	}
+}
+
+// configModification return the last modification of the configuration file (zero if the file is not readable)
+func configModification(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
@@ -1,5 +1,17 @@
 package datastructure
 
+import (
+	"sync"
+	"time"
+
+	"github.com/alessiosavi/GoLog-Viewer/alert"
+	"github.com/alessiosavi/GoLog-Viewer/config"
+	"github.com/alessiosavi/GoLog-Viewer/drain"
+	"github.com/alessiosavi/GoLog-Viewer/health"
+	"github.com/alessiosavi/GoLog-Viewer/metrics"
+	"github.com/alessiosavi/GoLog-Viewer/parser"
+)
+
 /* ------------- DATA STRUCTURE ------------- */
 
 // LogFileStruct Base structure for manage log file information
@@ -7,29 +19,104 @@ type LogFileStruct struct {
 	FileName          string            `json:"Filename"`          // Name of the log file
 	Data              []byte            `json:"Data"`              // Compress data of log files
 	LogFileInfoStruct LogFileInfoStruct `json:"LogFileInfoStruct"` // Path and timestamp of the logfile
+	Templates         *drain.History    `json:"-"`                 // Templates observed since the start, used for recognize the new ones
+	Excluded          bool              `json:"-"`                 // Excluded by the include/exclude rules: the lines are not kept in memory and the file is hidden
+	Checked           time.Time         `json:"-"`                 // Last time that the file was checked for changes
+	Error             FileError         `json:"-"`                 // Errors occurred reading the file
+
+	mutex sync.RWMutex // Guard the fields above (except the name and the path), updated by the engines while the API read them
+}
+
+// FileState is a copy of the state of a file, consistent with the updates of the engines
+type FileState struct {
+	Data      []byte            // Compress data of log files
+	Info      LogFileInfoStruct // Path, timestamp and statistics of the logfile
+	Templates *drain.History    // Templates observed since the start (nil until the ingestion is initialized)
+	Excluded  bool              // Excluded by the include/exclude rules
+	Checked   time.Time         // Last time that the file was checked for changes
+	Error     FileError         // Errors occurred reading the file
+}
+
+// Lock acquire the file for update its state. The engines hold the lock during the whole update
+func (f *LogFileStruct) Lock() {
+	f.mutex.Lock()
+}
+
+// Unlock release the file acquired by Lock
+func (f *LogFileStruct) Unlock() {
+	f.mutex.Unlock()
+}
+
+// State return a copy of the state of the file. The data and the maps are replaced (never modified) by the updates, so they are shared with the copy
+func (f *LogFileStruct) State() FileState {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	return FileState{Data: f.Data, Info: f.LogFileInfoStruct, Templates: f.Templates, Excluded: f.Excluded, Checked: f.Checked, Error: f.Error}
+}
+
+// Kinds of the errors occurred reading a file
+const (
+	ErrorPermission = "permission_denied"
+	ErrorNotFound   = "not_found"
+	ErrorDecompress = "decompress"
+	ErrorRead       = "read"
+)
+
+// FileError contains the errors occurred reading a file (load of the lines, ingestion of the new lines)
+type FileError struct {
+	Kind    string    `json:"Kind"`    // Kind of the last error: permission_denied, not_found, decompress, read
+	Error   string    `json:"Error"`   // Last error
+	Count   int       `json:"Count"`   // Number of errors since the start
+	Since   time.Time `json:"Since"`   // First error of the current failure
+	Last    time.Time `json:"Last"`    // Last error
+	Failing bool      `json:"Failing"` // The last read of the file failed
 }
 
 // LogFileInfoStruct Base structure for save the metadata inoìformation of the log file
 type LogFileInfoStruct struct {
-	Timestamp int64  `json:"Timestamp"` // Last modification time of the log file (user for check change)
-	Path      string `json:"Path"`      // Path of the log file (symbolic link welcome)
+	Timestamp int64          `json:"Timestamp"`         // Last modification time of the log file (user for check change)
+	Path      string         `json:"Path"`              // Path of the log file (symbolic link welcome)
+	Levels    map[string]int `json:"Levels"`            // Number of lines in memory for every level (TRACE, DEBUG, INFO, WARN, ERROR, FATAL, UNKNOWN)
+	Offset    int64          `json:"Offset"`            // Bytes of the file already ingested, the new lines are read starting from here
+	Members   []string       `json:"Members,omitempty"` // Merged files (only for the virtual files)
+	Limits    config.Limits  `json:"Limits"`            // Settings applied to the file (lines in memory, lines searched, seconds among the checks, max bytes in memory)
+	Bytes     int            `json:"Bytes"`             // Size of the (uncompressed) lines in memory
 }
 
 // Status Structure used for populate the json response for the RESTfull HTTP API
 type Status struct {
-	Status      bool        `json:"Status"`      // Status of response [true,false] OK, KO
-	ErrorCode   string      `json:"ErrorCode"`   // Code linked to the error (KO)
-	Description string      `json:"Description"` // Description linked to the error (KO)
-	Data        interface{} `json:"Data"`        // Generic data to return in the response
+	Status      bool        `json:"Status"`              // Status of response [true,false] OK, KO
+	ErrorCode   string      `json:"ErrorCode"`           // Code linked to the error (KO)
+	Description string      `json:"Description"`         // Description linked to the error (KO)
+	Data        interface{} `json:"Data"`                // Generic data to return in the response
+	RequestID   string      `json:"RequestID,omitempty"` // Identifier of the request (X-Request-ID), populated in case of error
+}
+
+// VirtualFile is a file obtained merging the lines of other files, interleaved by timestamp
+type VirtualFile struct {
+	Name  string `json:"Name"`  // Name used in place of the path of the file
+	Files string `json:"Files"` // Comma separated list of path/glob of the merged files
 }
 
 // Configuration Structure for manage the configuration of the tool
 type Configuration struct {
 	Path             *string `json:"Path"`             // Path of the log folder that have to be scan recursively during the init phase of the configuration
-	MinLinesToPrint  *int    `json:"MinLinesToPrint"`  // Minium number of lines to save in memory
-	MaxLinesToSearch *int    `json:"MaxLinesToSearch"` // Max line to take care when search (filter) content
 	Port             *int    `json:"Port"`             // Port to bind the service
 	Hostname         *string `json:"Hostname"`         // Hostname to bind the service
-	Sleep            *int    `json:"Sleep"`            // Number of seconds to sleep every time that the "core engine" have scan the filess
-	GCSleep          *int    `json:"GCSleep"`          // Number of minutes to sleep among every time that the manual garbage collector is called
+	Patterns         *string `json:"Patterns"`         // Path of the (json) file that contains the custom grok patterns and their bindings to the files
+	Alerts           *string `json:"Alerts"`           // Path of the (json) file that contains the alert rules and the webhooks
+	CorrelationField *string `json:"CorrelationField"` // Field that contains the correlation id of the requests (trace_id)
+	ConfigFile       *string `json:"ConfigFile"`       // Path of the (json) configuration file, reloaded on SIGHUP or when modified
+	Snapshot         *string `json:"Snapshot"`         // Path of the file used for save the state of the files across the restarts
+	SnapshotInterval *int    `json:"SnapshotInterval"` // Minutes among every save of the snapshot
+	ShutdownTimeout  *int    `json:"ShutdownTimeout"`  // Seconds to wait the requests in progress on shutdown
+
+	VirtualFiles []VirtualFile `json:"VirtualFiles"` // Files obtained merging (by timestamp) the lines of other files
+
+	Parser      *parser.Parser  `json:"-"` // Parser used for extract the fields from the lines (grok patterns + json)
+	AlertEngine *alert.Engine   `json:"-"` // Engine that evaluate the alert rules against the new lines
+	LogMetrics  *metrics.Engine `json:"-"` // Engine that update the metrics derived from the new lines
+	Health      *health.Monitor `json:"-"` // Progress of the initial load and of the core engine
+	Settings    *config.Store   `json:"-"` // Settings that can be changed at runtime (lines, sleep, include/exclude, per-file settings)
+	Auth        *config.Auth    `json:"-"` // Credentials accepted by the API
 }
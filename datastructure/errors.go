@@ -0,0 +1,35 @@
+package datastructure
+
+import "net/http"
+
+/* ------------- ERROR CODES ------------- */
+
+// Error codes returned in the ErrorCode field of the Status. The Description contains the details for the humans
+const (
+	ErrMissingParameter  = "MISSING_PARAMETER"   // A mandatory parameter is not populated
+	ErrInvalidParameter  = "INVALID_PARAMETER"   // A parameter does not contain a valid value
+	ErrInvalidBody       = "INVALID_BODY"        // The json body of the request is not valid
+	ErrUnauthorized      = "UNAUTHORIZED"        // The credentials (Authorization header) are missing or not valid
+	ErrFileNotFound      = "FILE_NOT_FOUND"      // The file is not managed
+	ErrRuleNotFound      = "RULE_NOT_FOUND"      // The alert rule does not exist
+	ErrResourceNotFound  = "RESOURCE_NOT_FOUND"  // The path does not exist
+	ErrMethodNotAllowed  = "METHOD_NOT_ALLOWED"  // The resource does not support the method
+	ErrRuleAlreadyExists = "RULE_ALREADY_EXISTS" // An alert rule with the same name already exists
+	ErrInternal          = "INTERNAL_ERROR"      // Unexpected failure (data in memory not readable, encoding ...)
+	ErrUnavailable       = "SERVICE_UNAVAILABLE" // A dependency is not available (persistence of the rules) or the service is not ready, the request can be retried
+)
+
+// ErrorStatusCodes map every error code to the related HTTP status code
+var ErrorStatusCodes = map[string]int{
+	ErrMissingParameter:  http.StatusBadRequest,
+	ErrInvalidParameter:  http.StatusBadRequest,
+	ErrInvalidBody:       http.StatusBadRequest,
+	ErrUnauthorized:      http.StatusUnauthorized,
+	ErrFileNotFound:      http.StatusNotFound,
+	ErrRuleNotFound:      http.StatusNotFound,
+	ErrResourceNotFound:  http.StatusNotFound,
+	ErrMethodNotAllowed:  http.StatusMethodNotAllowed,
+	ErrRuleAlreadyExists: http.StatusConflict,
+	ErrInternal:          http.StatusInternalServerError,
+	ErrUnavailable:       http.StatusServiceUnavailable,
+}
@@ -0,0 +1,241 @@
+// Package drain implements the Drain log template mining algorithm.
+//
+// The lines are tokenized and the variable tokens (numbers, uuid, ip, hex) are masked, then the lines are routed into a fixed depth tree
+// (number of tokens -> first tokens) and merged with the most similar cluster of the leaf. The tokens that differ among the lines
+// of the same cluster are replaced with the <*> wildcard.
+package drain
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Wildcard is the placeholder used for the tokens that change among the lines of the same template
+const Wildcard = "<*>"
+
+// masks replace the well known variable tokens with a named placeholder, in order of priority
+var masks = []struct {
+	regex       *regexp.Regexp
+	placeholder string
+}{
+	{regexp.MustCompile(`^[0-9a-fA-F]{8}-(?:[0-9a-fA-F]{4}-){3}[0-9a-fA-F]{12}$`), "<UUID>"},
+	{regexp.MustCompile(`^(?:\d{1,3}\.){3}\d{1,3}(?::\d+)?$`), "<IP>"},
+	{regexp.MustCompile(`^(?:[0-9A-Fa-f]{0,4}:){2,7}[0-9A-Fa-f]{0,4}$`), "<IP>"},
+	{regexp.MustCompile(`^\d{4}[-/]\d{2}[-/]\d{2}(?:[T_]\d{2}:\d{2}:\d{2}\S*)?$|^\d{2}:\d{2}:\d{2}(?:[.,]\d+)?\S*$`), "<TIME>"},
+	{regexp.MustCompile(`^[+-]?\d+(?:[.,]\d+)*(?:[a-zA-Z%]{0,3})$`), "<NUM>"},
+	{regexp.MustCompile(`^0[xX][0-9a-fA-F]+$|^[0-9a-fA-F]{8,}$`), "<HEX>"},
+}
+
+// trimChars are removed from the boundaries of the token before the masking (user=42, "id",)
+const trimChars = `"'()[]{},;`
+
+// Cluster is a template with the related statistics
+type Cluster struct {
+	ID        int       `json:"ID"`
+	Template  string    `json:"Template"`  // Tokens of the template joined by space
+	Count     int       `json:"Count"`     // Number of lines that match the template
+	FirstSeen time.Time `json:"FirstSeen"` // Timestamp of the oldest line (zero if the lines does not contains a timestamp)
+	LastSeen  time.Time `json:"LastSeen"`  // Timestamp of the newest line
+	Examples  []string  `json:"Examples"`  // Few lines that match the template
+
+	tokens []string
+	route  []string // Keys of the nodes of the tree that contain the cluster
+}
+
+type node struct {
+	children map[string]*node
+	clusters []*Cluster
+}
+
+// Drain is the template miner. It's not safe for concurrent use
+type Drain struct {
+	depth       int     // Number of tokens used for route the lines in the tree
+	similarity  float64 // Minimum similarity for merge a line into a cluster
+	maxChildren int     // Max children of a node, the others tokens are routed in the wildcard node
+	maxExamples int     // Number of examples saved for every cluster
+	root        *node
+	clusters    []*Cluster
+	lastID      int // Id of the last cluster created, the ids of the removed clusters are not reused
+}
+
+// New initialize a Drain miner with the default parameters
+func New() *Drain {
+	return &Drain{depth: 4, similarity: 0.4, maxChildren: 100, maxExamples: 3, root: &node{children: make(map[string]*node)}}
+}
+
+// Tokenize split the line in tokens and mask the variable ones
+func Tokenize(line string) []string {
+	tokens := strings.Fields(line)
+	for i, token := range tokens {
+		tokens[i] = maskToken(token)
+	}
+	return tokens
+}
+
+func maskToken(token string) string {
+	if masked := maskValue(token); masked != token {
+		return masked
+	}
+	// key=value tokens are masked only in the value part
+	if i := strings.IndexAny(token, "=:"); i > 0 && i < len(token)-1 {
+		return token[:i+1] + maskValue(token[i+1:])
+	}
+	return token
+}
+
+func maskValue(token string) string {
+	trimmed := strings.Trim(token, trimChars)
+	if trimmed == "" {
+		return token
+	}
+	for _, mask := range masks {
+		if mask.regex.MatchString(trimmed) {
+			return strings.Replace(token, trimmed, mask.placeholder, 1)
+		}
+	}
+	return token
+}
+
+// Add insert the line in the tree and return the cluster that contains it.
+// The time is used for update the first/last seen of the cluster, a zero time is ignored
+func (d *Drain) Add(line string, t time.Time) *Cluster {
+	tokens := Tokenize(line)
+	leaf, route := d.route(tokens)
+	cluster := d.bestMatch(leaf.clusters, tokens)
+	if cluster == nil {
+		d.lastID++
+		cluster = &Cluster{ID: d.lastID, tokens: tokens, route: route}
+		leaf.clusters = append(leaf.clusters, cluster)
+		d.clusters = append(d.clusters, cluster)
+	} else {
+		for i := range cluster.tokens {
+			if cluster.tokens[i] != tokens[i] {
+				cluster.tokens[i] = Wildcard
+			}
+		}
+	}
+	cluster.Template = strings.Join(cluster.tokens, " ")
+	cluster.Count++
+	if !t.IsZero() {
+		if cluster.FirstSeen.IsZero() || t.Before(cluster.FirstSeen) {
+			cluster.FirstSeen = t
+		}
+		if t.After(cluster.LastSeen) {
+			cluster.LastSeen = t
+		}
+	}
+	if len(cluster.Examples) < d.maxExamples {
+		cluster.Examples = append(cluster.Examples, line)
+	}
+	return cluster
+}
+
+// Match return the cluster that match the line without modify the tree, nil if no cluster is similar enough
+func (d *Drain) Match(line string) *Cluster {
+	tokens := Tokenize(line)
+	leaf, _ := d.route(tokens)
+	return d.bestMatch(leaf.clusters, tokens)
+}
+
+// Remove delete the cluster from the miner, the nodes of the tree left empty are released.
+// The next lines of the template will create a new cluster
+func (d *Drain) Remove(cluster *Cluster) {
+	for i, c := range d.clusters {
+		if c == cluster {
+			d.clusters = append(d.clusters[:i], d.clusters[i+1:]...)
+			break
+		}
+	}
+	nodes := []*node{d.root}
+	for _, key := range cluster.route {
+		child, ok := nodes[len(nodes)-1].children[key]
+		if !ok {
+			return
+		}
+		nodes = append(nodes, child)
+	}
+	leaf := nodes[len(nodes)-1]
+	for i, c := range leaf.clusters {
+		if c == cluster {
+			leaf.clusters = append(leaf.clusters[:i], leaf.clusters[i+1:]...)
+			break
+		}
+	}
+	for i := len(nodes) - 1; i > 0 && len(nodes[i].clusters) == 0 && len(nodes[i].children) == 0; i-- {
+		delete(nodes[i-1].children, cluster.route[i-1])
+	}
+}
+
+// route follow (and create) the path of the tree related to the tokens: length -> first tokens. The leaf is returned with the keys of the path
+func (d *Drain) route(tokens []string) (*node, []string) {
+	route := []string{strconv.Itoa(len(tokens))}
+	current := d.child(d.root, route[0])
+	for i := 0; i < d.depth-2 && i < len(tokens); i++ {
+		key := tokens[i]
+		if strings.ContainsAny(key, "0123456789<") {
+			key = Wildcard
+		}
+		if _, ok := current.children[key]; !ok && len(current.children) >= d.maxChildren {
+			key = Wildcard
+		}
+		current = d.child(current, key)
+		route = append(route, key)
+	}
+	return current, route
+}
+
+// child return the child of the node related to the key, creating it if not present
+func (d *Drain) child(parent *node, key string) *node {
+	child, ok := parent.children[key]
+	if !ok {
+		child = &node{children: make(map[string]*node)}
+		parent.children[key] = child
+	}
+	return child
+}
+
+// bestMatch return the most similar cluster, if the similarity is over the threshold
+func (d *Drain) bestMatch(clusters []*Cluster, tokens []string) *Cluster {
+	var best *Cluster
+	bestSimilarity, bestWildcards := -1.0, -1
+	for _, cluster := range clusters {
+		if len(cluster.tokens) != len(tokens) {
+			continue
+		}
+		similarity, wildcards := Similarity(cluster.tokens, tokens)
+		if similarity > bestSimilarity || similarity == bestSimilarity && wildcards > bestWildcards {
+			best, bestSimilarity, bestWildcards = cluster, similarity, wildcards
+		}
+	}
+	if best != nil && bestSimilarity >= d.similarity {
+		return best
+	}
+	return nil
+}
+
+// Similarity return the ratio of equal tokens (wildcard excluded) and the number of wildcards of the template
+func Similarity(template, tokens []string) (float64, int) {
+	if len(template) == 0 {
+		return 1, 0
+	}
+	var equals, wildcards int
+	for i := range template {
+		if template[i] == Wildcard {
+			wildcards++
+		} else if template[i] == tokens[i] {
+			equals++
+		}
+	}
+	return float64(equals) / float64(len(template)), wildcards
+}
+
+// Clusters return the clusters sorted by number of lines (descending)
+func (d *Drain) Clusters() []*Cluster {
+	clusters := make([]*Cluster, len(d.clusters))
+	copy(clusters, d.clusters)
+	sort.SliceStable(clusters, func(i, j int) bool { return clusters[i].Count > clusters[j].Count })
+	return clusters
+}
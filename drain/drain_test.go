@@ -0,0 +1,176 @@
+package drain
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected string
+	}{
+		{line: "user 42 logged in", expected: "user <NUM> logged in"},
+		{line: "took 1.5ms (3 retries)", expected: "took <NUM> (<NUM> retries)"},
+		{line: "request 3f2b8c1e-9a4d-4e2f-8b6a-1c2d3e4f5a6b done", expected: "request <UUID> done"},
+		{line: "connect to 10.0.0.12:5432 failed", expected: "connect to <IP> failed"},
+		{line: "peer fe80::1ff:fe23:4567:890a", expected: "peer <IP>"},
+		{line: "started at 2024-01-02T10:00:00Z", expected: "started at <TIME>"},
+		{line: "pointer 0x7ffd5e8c deadbeefcafe", expected: "pointer <HEX> <HEX>"},
+		{line: "user_id=42 status=ok", expected: "user_id=<NUM> status=ok"},
+		{line: `id="17",`, expected: `id="<NUM>",`},
+		{line: "v2 is not a number", expected: "v2 is not a number"},
+		{line: "  spaces\tand\ttabs  ", expected: "spaces and tabs"},
+	}
+	for _, test := range tests {
+		if tokens := strings.Join(Tokenize(test.line), " "); tokens != test.expected {
+			t.Errorf("Tokenize(%q) = %q, expected %q", test.line, tokens, test.expected)
+		}
+	}
+}
+
+func TestAdd(t *testing.T) {
+	tests := []struct {
+		name      string
+		lines     []string
+		templates []string // Templates of the clusters, by id
+	}{
+		{name: "same template",
+			lines:     []string{"user 42 logged in", "user 7 logged in"},
+			templates: []string{"user <NUM> logged in"}},
+		{name: "variable word become wildcard",
+			lines:     []string{"connection from alice closed", "connection from bob closed"},
+			templates: []string{"connection from <*> closed"}},
+		{name: "different length",
+			lines:     []string{"cache miss", "cache miss for key"},
+			templates: []string{"cache miss", "cache miss for key"}},
+		{name: "not similar",
+			lines:     []string{"disk full on sda", "reading config from file"},
+			templates: []string{"disk full on sda", "reading config from file"}},
+		{name: "different first tokens",
+			lines:     []string{"GET /index.html returned 200", "POST /index.html returned 200"},
+			templates: []string{"GET /index.html returned <NUM>", "POST /index.html returned <NUM>"}},
+		{name: "empty line",
+			lines:     []string{"", ""},
+			templates: []string{""}},
+	}
+	for _, test := range tests {
+		miner := New()
+		for _, line := range test.lines {
+			miner.Add(line, time.Time{})
+		}
+		clusters := miner.clusters
+		if len(clusters) != len(test.templates) {
+			var templates []string
+			for _, cluster := range clusters {
+				templates = append(templates, cluster.Template)
+			}
+			t.Errorf("%s: templates %q, expected %q", test.name, templates, test.templates)
+			continue
+		}
+		for i, cluster := range clusters {
+			if cluster.ID != i+1 || cluster.Template != test.templates[i] {
+				t.Errorf("%s: cluster %d %q, expected %d %q", test.name, cluster.ID, cluster.Template, i+1, test.templates[i])
+			}
+		}
+	}
+}
+
+func TestAddStatistics(t *testing.T) {
+	miner := New()
+	first := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+	lines := []string{"job 1 done", "job 2 done", "job 3 done", "job 4 done"}
+	times := []time.Time{first.Add(time.Minute), first, time.Time{}, first.Add(time.Hour)}
+	var cluster *Cluster
+	for i, line := range lines {
+		cluster = miner.Add(line, times[i])
+	}
+	if cluster.Count != 4 {
+		t.Errorf("count %d, expected 4", cluster.Count)
+	}
+	if !cluster.FirstSeen.Equal(first) || !cluster.LastSeen.Equal(first.Add(time.Hour)) {
+		t.Errorf("seen from %s to %s, expected from %s to %s (the zero time is ignored)", cluster.FirstSeen, cluster.LastSeen, first, first.Add(time.Hour))
+	}
+	if len(cluster.Examples) != 3 || cluster.Examples[0] != "job 1 done" {
+		t.Errorf("examples %q, expected the first 3 lines", cluster.Examples)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	miner := New()
+	miner.Add("user 42 logged in", time.Time{})
+	if cluster := miner.Match("user 7 logged in"); cluster == nil || cluster.ID != 1 {
+		t.Errorf("Match() = %v, expected the cluster 1", cluster)
+	}
+	if cluster := miner.Match("disk full on sda"); cluster != nil {
+		t.Errorf("Match() = %v, expected no cluster", cluster)
+	}
+	if len(miner.clusters) != 1 || miner.clusters[0].Count != 1 {
+		t.Error("Match have to leave the clusters untouched")
+	}
+}
+
+func TestClusters(t *testing.T) {
+	miner := New()
+	for _, line := range []string{"disk full on sda", "user 1 logged in", "user 2 logged in", "cache miss", "user 3 logged in", "cache miss"} {
+		miner.Add(line, time.Time{})
+	}
+	expected := []struct {
+		template string
+		count    int
+	}{{"user <NUM> logged in", 3}, {"cache miss", 2}, {"disk full on sda", 1}}
+	clusters := miner.Clusters()
+	if len(clusters) != len(expected) {
+		t.Fatalf("%d clusters, expected %d", len(clusters), len(expected))
+	}
+	for i, cluster := range clusters {
+		if cluster.Template != expected[i].template || cluster.Count != expected[i].count {
+			t.Errorf("cluster %d: %q (%d), expected %q (%d)", i, cluster.Template, cluster.Count, expected[i].template, expected[i].count)
+		}
+	}
+}
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		template, tokens string
+		similarity       float64
+		wildcards        int
+	}{
+		{template: "a b c d", tokens: "a b c d", similarity: 1},
+		{template: "a b c d", tokens: "a x c y", similarity: 0.5},
+		{template: "a <*> c d", tokens: "a x c d", similarity: 0.75, wildcards: 1},
+		{template: "", tokens: "", similarity: 1},
+	}
+	for _, test := range tests {
+		similarity, wildcards := Similarity(strings.Fields(test.template), strings.Fields(test.tokens))
+		if similarity != test.similarity || wildcards != test.wildcards {
+			t.Errorf("Similarity(%q, %q) = %v, %d, expected %v, %d", test.template, test.tokens, similarity, wildcards, test.similarity, test.wildcards)
+		}
+	}
+}
+
+func TestRemove(t *testing.T) {
+	miner := New()
+	user := miner.Add("user 1 logged in", time.Time{})
+	disk := miner.Add("disk full on sda", time.Time{})
+	miner.Add("disk usage at 90", time.Time{})
+	miner.Remove(user)
+	if len(miner.clusters) != 2 || miner.Match("user 2 logged in") != nil {
+		t.Errorf("unexpected clusters %v after the remove", miner.Clusters())
+	}
+	if _, ok := miner.root.children["4"]; !ok || len(miner.root.children) != 1 {
+		t.Errorf("the nodes of the removed cluster have to be released, the others kept: %v", miner.root.children)
+	}
+	if cluster := miner.Add("user 2 logged in", time.Time{}); cluster.ID != 4 || cluster.Count != 1 {
+		t.Errorf("Add() = %+v, expected a new cluster with a new id", cluster)
+	}
+	miner.Remove(disk)
+	miner.Remove(disk) // Already removed
+	if cluster := miner.Match("disk usage at 95"); cluster == nil || cluster.ID != 3 {
+		t.Errorf("Match() = %v, expected the cluster 3 kept", cluster)
+	}
+	if len(miner.clusters) != 2 {
+		t.Errorf("%d clusters, expected 2", len(miner.clusters))
+	}
+}
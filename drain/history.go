@@ -0,0 +1,175 @@
+package drain
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Seen contains the first/last time that a template was observed
+type Seen struct {
+	Template  string    `json:"Template"`
+	FirstSeen time.Time `json:"FirstSeen"` // Time of the first line observed for the template
+	LastSeen  time.Time `json:"LastSeen"`  // Time of the last line observed for the template
+	Count     int       `json:"Count"`     // Number of lines observed
+	Examples  []string  `json:"Examples"`  // First lines observed for the template
+	Baseline  bool      `json:"Baseline"`  // The template was already present during the initial load
+}
+
+// Limits of the templates kept by the history, in order to bound the memory of the long running processes
+const (
+	templateRetention = 7 * 24 * time.Hour // The templates not observed for the retention are forgotten by Expire
+	maxTemplates      = 10000              // Over the limit the least recently observed templates are forgotten
+)
+
+// History keep track of every template observed for a file, in order to recognize the new ones.
+// The lines are clustered by a dedicated miner, so the identity of the template does not change when the template become more generic.
+// The forgotten templates (expired or over the limit) are reported as new when observed again. It's safe for concurrent use
+type History struct {
+	mutex        sync.Mutex
+	miner        *Drain
+	seen         map[int]*Seen    // Seen templates by cluster id
+	clusters     map[int]*Cluster // Clusters of the miner by id, used for forget the templates
+	retention    time.Duration
+	maxTemplates int
+}
+
+// NewHistory initialize an empty history
+func NewHistory() *History {
+	return &History{miner: New(), seen: make(map[int]*Seen), clusters: make(map[int]*Cluster), retention: templateRetention, maxTemplates: maxTemplates}
+}
+
+// Observe add the line to the history. The baseline lines (initial load) are never reported as new
+func (h *History) Observe(line string, now time.Time, baseline bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	cluster := h.miner.Add(line, time.Time{})
+	seen, ok := h.seen[cluster.ID]
+	if !ok {
+		seen = &Seen{FirstSeen: now, Baseline: baseline}
+		h.seen[cluster.ID] = seen
+		h.clusters[cluster.ID] = cluster
+	}
+	seen.Template = cluster.Template
+	seen.LastSeen = now
+	seen.Count++
+	if len(seen.Examples) < h.miner.maxExamples {
+		seen.Examples = append(seen.Examples, line)
+	}
+	if !ok && len(h.seen) > h.maxTemplates {
+		h.evict(cluster.ID)
+	}
+}
+
+// Expire forget the templates not observed for the retention period. The number of templates forgotten is returned
+func (h *History) Expire(now time.Time) int {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	limit := now.Add(-h.retention)
+	var n int
+	for id, seen := range h.seen {
+		if seen.LastSeen.Before(limit) {
+			h.forget(id)
+			n++
+		}
+	}
+	return n
+}
+
+// evict forget the least recently observed templates (the least frequent first), leaving room for the next ones.
+// The given template is always kept
+func (h *History) evict(keep int) {
+	ids := make([]int, 0, len(h.seen))
+	for id := range h.seen {
+		if id != keep {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		a, b := h.seen[ids[i]], h.seen[ids[j]]
+		if !a.LastSeen.Equal(b.LastSeen) {
+			return a.LastSeen.Before(b.LastSeen)
+		}
+		if a.Count != b.Count {
+			return a.Count < b.Count
+		}
+		return ids[i] < ids[j]
+	})
+	for _, id := range ids[:len(h.seen)-h.maxTemplates*9/10] {
+		h.forget(id)
+	}
+}
+
+func (h *History) forget(id int) {
+	if cluster, ok := h.clusters[id]; ok {
+		h.miner.Remove(cluster)
+	}
+	delete(h.seen, id)
+	delete(h.clusters, id)
+}
+
+// Novel return the templates observed for the first time after the given time, the newest first
+func (h *History) Novel(since time.Time) []Seen {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	novel := []Seen{}
+	for _, seen := range h.seen {
+		if !seen.Baseline && !seen.FirstSeen.Before(since) {
+			novel = append(novel, *seen)
+		}
+	}
+	sort.Slice(novel, func(i, j int) bool { return novel[i].FirstSeen.After(novel[j].FirstSeen) })
+	return novel
+}
+
+// Len return the number of templates observed
+func (h *History) Len() int {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return len(h.seen)
+}
+
+// Seen return a copy of the templates observed, used for save the history
+func (h *History) Seen() []Seen {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	seen := make([]Seen, 0, len(h.seen))
+	for _, s := range h.seen {
+		seen = append(seen, *s)
+	}
+	return seen
+}
+
+// RestoreHistory rebuild the history from the templates returned by Seen. The examples of the templates are clustered again,
+// the templates that end in the same cluster are merged
+func RestoreHistory(seen []Seen) *History {
+	h := NewHistory()
+	for _, s := range seen {
+		var cluster *Cluster
+		for _, example := range s.Examples {
+			cluster = h.miner.Add(example, time.Time{})
+		}
+		if cluster == nil {
+			continue
+		}
+		if current, ok := h.seen[cluster.ID]; ok {
+			current.Count += s.Count
+			current.Baseline = current.Baseline && s.Baseline
+			if s.FirstSeen.Before(current.FirstSeen) {
+				current.FirstSeen = s.FirstSeen
+			}
+			if s.LastSeen.After(current.LastSeen) {
+				current.LastSeen = s.LastSeen
+			}
+			continue
+		}
+		restored := s // The loop variable is reused among the iterations
+		restored.Template = cluster.Template
+		h.seen[cluster.ID] = &restored
+		h.clusters[cluster.ID] = cluster
+	}
+	if len(h.seen) > h.maxTemplates {
+		h.evict(-1)
+	}
+	return h
+}
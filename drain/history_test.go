@@ -0,0 +1,167 @@
+package drain
+
+import (
+	"testing"
+	"time"
+)
+
+var start = time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+
+func TestNovel(t *testing.T) {
+	h := NewHistory()
+	h.Observe("user 1 logged in", start, true)
+	h.Observe("user 2 logged in", start.Add(time.Minute), false) // Known template, even if observed after the baseline
+	h.Observe("disk full on sda", start.Add(2*time.Minute), false)
+	h.Observe("cache miss for key", start.Add(3*time.Minute), false)
+	h.Observe("disk full on sdb", start.Add(4*time.Minute), false)
+
+	tests := []struct {
+		since     time.Time
+		templates []string
+	}{
+		{since: time.Time{}, templates: []string{"cache miss for key", "disk full on <*>"}},
+		{since: start.Add(2 * time.Minute), templates: []string{"cache miss for key", "disk full on <*>"}},
+		{since: start.Add(3 * time.Minute), templates: []string{"cache miss for key"}},
+		{since: start.Add(time.Hour), templates: []string{}},
+	}
+	for _, test := range tests {
+		novel := h.Novel(test.since)
+		if len(novel) != len(test.templates) {
+			t.Errorf("Novel(%s) = %v, expected %q", test.since, novel, test.templates)
+			continue
+		}
+		for i, seen := range novel {
+			if seen.Template != test.templates[i] {
+				t.Errorf("Novel(%s)[%d] = %q, expected %q (newest first)", test.since, i, seen.Template, test.templates[i])
+			}
+		}
+	}
+	if h.Len() != 3 {
+		t.Errorf("%d templates, expected 3", h.Len())
+	}
+	disk := h.Novel(time.Time{})[1]
+	if disk.Count != 2 || !disk.FirstSeen.Equal(start.Add(2*time.Minute)) || !disk.LastSeen.Equal(start.Add(4*time.Minute)) || len(disk.Examples) != 2 {
+		t.Errorf("unexpected statistics %+v", disk)
+	}
+}
+
+func TestRestoreHistory(t *testing.T) {
+	h := NewHistory()
+	for i, line := range []string{"user 1 logged in", "user 2 logged in", "connection from alice closed", "connection from bob closed"} {
+		h.Observe(line, start.Add(time.Duration(i)*time.Minute), true)
+	}
+	h.Observe("disk full on sda", start.Add(time.Hour), false)
+	saved := make(map[string]Seen)
+	for _, seen := range h.Seen() {
+		saved[seen.Template] = seen
+	}
+
+	restored := RestoreHistory(h.Seen())
+	if restored.Len() != h.Len() {
+		t.Fatalf("%d templates restored, expected %d", restored.Len(), h.Len())
+	}
+	for _, seen := range restored.Seen() {
+		original, ok := saved[seen.Template]
+		if !ok {
+			t.Errorf("unexpected template %q", seen.Template)
+			continue
+		}
+		if seen.Count != original.Count || seen.Baseline != original.Baseline || !seen.FirstSeen.Equal(original.FirstSeen) || !seen.LastSeen.Equal(original.LastSeen) {
+			t.Errorf("%q restored as %+v, expected %+v", seen.Template, seen, original)
+		}
+	}
+	// The lines of the restored templates are not new, the history keep working after the restore
+	restored.Observe("user 3 logged in", start.Add(2*time.Hour), false)
+	restored.Observe("connection from carol closed", start.Add(2*time.Hour), false)
+	restored.Observe("cache miss for key", start.Add(3*time.Hour), false)
+	novel := restored.Novel(start.Add(time.Hour))
+	if len(novel) != 2 || novel[0].Template != "cache miss for key" || novel[1].Template != "disk full on sda" {
+		t.Errorf("Novel() = %v, expected the new template and the one novel before the restore", novel)
+	}
+	if restored.Len() != 4 {
+		t.Errorf("%d templates, expected 4", restored.Len())
+	}
+}
+
+func TestRestoreHistoryMerge(t *testing.T) {
+	// Two templates saved apart that are clustered together on restore
+	seen := []Seen{
+		{Template: "job <NUM> done", FirstSeen: start.Add(time.Minute), LastSeen: start.Add(time.Minute), Count: 2, Examples: []string{"job 1 done"}, Baseline: true},
+		{Template: "job <NUM> done", FirstSeen: start, LastSeen: start.Add(time.Hour), Count: 3, Examples: []string{"job 2 done"}, Baseline: false},
+		{Template: "empty", Count: 1}, // Without examples the template can't be clustered again
+	}
+	restored := RestoreHistory(seen)
+	if restored.Len() != 1 {
+		t.Fatalf("%d templates, expected 1", restored.Len())
+	}
+	merged := restored.Seen()[0]
+	if merged.Count != 5 || merged.Baseline || !merged.FirstSeen.Equal(start) || !merged.LastSeen.Equal(start.Add(time.Hour)) {
+		t.Errorf("unexpected merge %+v", merged)
+	}
+	if seen[0].Template != "job <NUM> done" || seen[0].Count != 2 {
+		t.Error("the restore have to leave the saved templates untouched")
+	}
+}
+
+func TestRestoreHistoryEmpty(t *testing.T) {
+	if h := RestoreHistory(nil); h == nil || h.Len() != 0 {
+		t.Error("expected an empty history")
+	}
+}
+
+func TestExpire(t *testing.T) {
+	h := NewHistory()
+	h.Observe("user 1 logged in", start, true)
+	h.Observe("disk full on sda", start, false)
+	h.Observe("user 2 logged in", start.Add(templateRetention), false)
+	if n := h.Expire(start.Add(templateRetention)); n != 0 {
+		t.Errorf("Expire() = %d, expected no templates forgotten before the retention", n)
+	}
+	if n := h.Expire(start.Add(templateRetention + time.Minute)); n != 1 || h.Len() != 1 {
+		t.Fatalf("Expire() = %d (%d templates left), expected the disk template forgotten", n, h.Len())
+	}
+	if seen := h.Seen()[0]; seen.Template != "user <NUM> logged in" || !seen.Baseline {
+		t.Errorf("unexpected template %+v", seen)
+	}
+	// A forgotten template is new when observed again, even if it was known before
+	h.Observe("disk full on sdb", start.Add(templateRetention+time.Hour), false)
+	novel := h.Novel(time.Time{})
+	if len(novel) != 1 || novel[0].Template != "disk full on sdb" || novel[0].Count != 1 || !novel[0].FirstSeen.Equal(start.Add(templateRetention+time.Hour)) {
+		t.Errorf("Novel() = %+v, expected the forgotten template as new", novel)
+	}
+	if n := h.Expire(start.Add(3 * templateRetention)); n != 2 || h.Len() != 0 || len(h.miner.Clusters()) != 0 {
+		t.Errorf("Expire() = %d, expected every template forgotten, %d templates and %d clusters left", n, h.Len(), len(h.miner.Clusters()))
+	}
+}
+
+func TestObserveLimit(t *testing.T) {
+	h := NewHistory()
+	h.maxTemplates = 10
+	words := []string{"alpha", "bravo", "charlie", "delta", "echo", "foxtrot", "golf", "hotel", "india", "juliet", "kilo"}
+	for i, word := range words[:10] {
+		h.Observe("event "+word+" happened", start.Add(time.Duration(i)*time.Minute), false)
+	}
+	h.Observe("event alpha happened", start.Add(time.Hour), false) // The oldest template become the most recent
+	if h.Len() != 10 {
+		t.Fatalf("%d templates, expected 10", h.Len())
+	}
+	h.Observe("event kilo happened", start.Add(time.Minute), false) // Over the limit, the new template is kept even if older
+	kept := make(map[string]bool)
+	for _, seen := range h.Seen() {
+		kept[seen.Template] = true
+	}
+	if len(kept) != 9 || len(h.miner.Clusters()) != 9 {
+		t.Fatalf("%d templates and %d clusters, expected 9: %v", len(kept), len(h.miner.Clusters()), kept)
+	}
+	for _, word := range []string{"bravo", "charlie"} {
+		if kept["event "+word+" happened"] {
+			t.Errorf("the least recent template %q have to be forgotten", word)
+		}
+	}
+	for _, word := range []string{"alpha", "delta", "juliet", "kilo"} {
+		if !kept["event "+word+" happened"] {
+			t.Errorf("template %q forgotten, expected kept", word)
+		}
+	}
+
+}
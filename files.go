@@ -0,0 +1,211 @@
+package main
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/alessiosavi/GoLog-Viewer/datastructure"
+	"github.com/alessiosavi/GoLog-Viewer/parser"
+	log "github.com/sirupsen/logrus"
+	"github.com/valyala/gozstd"
+)
+
+/* ------------- FILE LOOKUP ------------- */
+
+// LogLine is a line in memory with the file that contains it
+type LogLine struct {
+	File   string
+	Number int // Position of the line among the lines in memory of the file (starting from 1)
+	Text   []byte
+}
+
+// ParseVirtualFiles parse the definition of the virtual files: a semicolon separated list of name=files, where files is a
+// comma separated list of path/glob (app.log=app-*.log;edge=gateway.log,lb.log)
+func ParseVirtualFiles(definition string) ([]datastructure.VirtualFile, error) {
+	var virtualFiles []datastructure.VirtualFile
+	for _, item := range strings.Split(definition, ";") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		tokens := strings.SplitN(item, "=", 2)
+		if len(tokens) != 2 || strings.TrimSpace(tokens[0]) == "" || strings.TrimSpace(tokens[1]) == "" {
+			return nil, errors.New("invalid virtual file " + item + ", use name=file1,file2")
+		}
+		virtualFile := datastructure.VirtualFile{Name: strings.TrimSpace(tokens[0]), Files: strings.TrimSpace(tokens[1])}
+		if err := ValidateVirtualFile(virtualFile); err != nil {
+			return nil, err
+		}
+		virtualFiles = append(virtualFiles, virtualFile)
+	}
+	return virtualFiles, nil
+}
+
+// ValidateVirtualFile verify the name and the globs of the merged files
+func ValidateVirtualFile(virtualFile datastructure.VirtualFile) error {
+	if strings.TrimSpace(virtualFile.Name) == "" || strings.TrimSpace(virtualFile.Files) == "" {
+		return errors.New("invalid virtual file " + virtualFile.Name + ", the name and the files are mandatory")
+	}
+	for _, glob := range strings.Split(virtualFile.Files, ",") {
+		if _, err := filepath.Match(strings.TrimSpace(glob), ""); err != nil {
+			return errors.New("invalid glob " + glob + " for virtual file " + virtualFile.Name)
+		}
+	}
+	return nil
+}
+
+// FindVirtualFile return the virtual file related to the name
+func FindVirtualFile(virtualFiles []datastructure.VirtualFile, name string) (datastructure.VirtualFile, bool) {
+	for _, virtualFile := range virtualFiles {
+		if virtualFile.Name == name {
+			return virtualFile, true
+		}
+	}
+	return datastructure.VirtualFile{}, false
+}
+
+// SelectFiles return the index of the files that match the comma separated list of path/glob.
+// The glob are matched against the full path and the name of the file, the virtual files are expanded to their members.
+// The files excluded by the include/exclude rules are never selected
+func SelectFiles(fileList []datastructure.LogFileStruct, virtualFiles []datastructure.VirtualFile, files string) []int {
+	var patterns []string
+	for _, pattern := range strings.Split(files, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if virtualFile, ok := FindVirtualFile(virtualFiles, pattern); ok {
+			patterns = append(patterns, strings.Split(virtualFile.Files, ",")...)
+		} else if pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+	var selected []int
+	for i := 0; i < len(fileList); i++ {
+		if fileList[i].State().Excluded {
+			continue
+		}
+		for _, pattern := range patterns {
+			pattern = strings.TrimSpace(pattern)
+			if pattern == fileList[i].LogFileInfoStruct.Path || pattern == fileList[i].FileName {
+				selected = append(selected, i)
+				break
+			}
+			matchPath, _ := filepath.Match(pattern, fileList[i].LogFileInfoStruct.Path)
+			matchName, _ := filepath.Match(pattern, fileList[i].FileName)
+			if matchPath || matchName {
+				selected = append(selected, i)
+				break
+			}
+		}
+	}
+	return selected
+}
+
+// ReadVirtualFile return the lines in memory of the members of the virtual file, interleaved by timestamp.
+// The lines without timestamp (stack traces, multiline messages) inherit the time of the previous line of the same file.
+// An error is returned if the data of a member can't be decompressed
+func ReadVirtualFile(fileList []datastructure.LogFileStruct, virtualFiles []datastructure.VirtualFile, virtualFile datastructure.VirtualFile, lineParser *parser.Parser) ([]LogLine, error) {
+	type source struct {
+		lines []LogLine
+		times []time.Time
+	}
+	var sources []source
+	for _, i := range SelectFiles(fileList, virtualFiles, virtualFile.Files) {
+		path := fileList[i].LogFileInfoStruct.Path
+		lines, err := FileLines(path, fileList[i].State().Data)
+		if err != nil {
+			return nil, err
+		}
+		src := source{lines: lines}
+		var last time.Time
+		for _, line := range lines {
+			if t, ok := parser.ParseTimestamp(line.Text, lineParser.Fields(path, line.Text)); ok {
+				last = t
+			}
+			src.times = append(src.times, last)
+		}
+		sources = append(sources, src)
+	}
+	// K-way merge: the order of the lines of the same file is always preserved
+	var merged []LogLine
+	heads := make([]int, len(sources))
+	for {
+		best := -1
+		for i := range sources {
+			if heads[i] < len(sources[i].lines) && (best < 0 || sources[i].times[heads[i]].Before(sources[best].times[heads[best]])) {
+				best = i
+			}
+		}
+		if best < 0 {
+			return merged, nil
+		}
+		merged = append(merged, sources[best].lines[heads[best]])
+		heads[best]++
+	}
+}
+
+// ManagedFiles return the index of the files that are not excluded by the include/exclude rules
+func ManagedFiles(fileList []datastructure.LogFileStruct) []int {
+	var selected []int
+	for i := 0; i < len(fileList); i++ {
+		if !fileList[i].State().Excluded {
+			selected = append(selected, i)
+		}
+	}
+	return selected
+}
+
+// ReadLines return the lines in memory of the given file (path or virtual file). False is returned if the file is not managed,
+// an error if the data in memory can't be decompressed
+func ReadLines(fileList []datastructure.LogFileStruct, virtualFiles []datastructure.VirtualFile, file string, lineParser *parser.Parser) ([]LogLine, bool, error) {
+	if virtualFile, ok := FindVirtualFile(virtualFiles, file); ok {
+		lines, err := ReadVirtualFile(fileList, virtualFiles, virtualFile, lineParser)
+		return lines, true, err
+	}
+	for i := 0; i < len(fileList); i++ {
+		if fileList[i].LogFileInfoStruct.Path != file {
+			continue
+		}
+		state := fileList[i].State()
+		if state.Excluded {
+			continue
+		}
+		lines, err := FileLines(file, state.Data)
+		return lines, true, err
+	}
+	return nil, false, nil
+}
+
+// FileLines decompress the lines in memory of the file
+func FileLines(path string, data []byte) ([]LogLine, error) {
+	uncompressed, err := gozstd.Decompress(nil, data)
+	if err != nil {
+		log.Error("FileLines | Unable to decompress data of ", path, " | Err: ", err)
+		return nil, errors.New("unable to decompress the data of " + path)
+	}
+	var lines []LogLine
+	for i, line := range parser.SplitLines(uncompressed) {
+		lines = append(lines, LogLine{File: path, Number: i + 1, Text: line})
+	}
+	return lines, nil
+}
+
+// FormatVirtualLine prefix the line with the name of the origin file
+func FormatVirtualLine(line LogLine) string {
+	return "[" + filepath.Base(line.File) + "] " + string(line.Text)
+}
+
+// VirtualFileInfo return the metadata of the virtual file: the last modification and the level statistics are the ones of the members
+func VirtualFileInfo(fileList []datastructure.LogFileStruct, virtualFiles []datastructure.VirtualFile, virtualFile datastructure.VirtualFile) datastructure.LogFileInfoStruct {
+	info := datastructure.LogFileInfoStruct{Path: virtualFile.Name, Levels: parser.CountLevels(nil), Members: []string{}}
+	for _, i := range SelectFiles(fileList, virtualFiles, virtualFile.Files) {
+		member := fileList[i].State().Info
+		info.Members = append(info.Members, member.Path)
+		if member.Timestamp > info.Timestamp {
+			info.Timestamp = member.Timestamp
+		}
+		for level, n := range member.Levels {
+			info.Levels[level] += n
+		}
+	}
+	return info
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alessiosavi/GoLog-Viewer/datastructure"
+	"github.com/alessiosavi/GoLog-Viewer/query"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/gozstd"
+)
+
+// logFile return a managed file that contains the given lines in memory
+func logFile(path string, lines ...string) datastructure.LogFileStruct {
+	var data []byte
+	if len(lines) > 0 {
+		data = gozstd.Compress(nil, []byte(strings.Join(lines, "\n")+"\n"))
+	}
+	return datastructure.LogFileStruct{FileName: filepath.Base(path), Data: data, LogFileInfoStruct: datastructure.LogFileInfoStruct{Path: path}}
+}
+
+func texts(lines []LogLine) string {
+	var formatted []string
+	for _, line := range lines {
+		formatted = append(formatted, FormatVirtualLine(line))
+	}
+	return strings.Join(formatted, "|")
+}
+
+func TestReadLines(t *testing.T) {
+	fileList := []datastructure.LogFileStruct{
+		logFile("/var/log/api.log", "2024-01-02T10:00:01Z api start", "2024-01-02T10:00:03Z api stop"),
+		logFile("/var/log/db.log", "2024-01-02T10:00:02Z db query", "  at stack trace", "2024-01-02T10:00:04Z db done"),
+		logFile("/var/log/old.log", "excluded"),
+		logFile("/var/log/broken.log"),
+	}
+	fileList[2].Excluded = true
+	fileList[3].Data = []byte("not zstd")
+	virtualFiles := []datastructure.VirtualFile{{Name: "all", Files: "api.log,db.log"}, {Name: "broken", Files: "api.log,broken.log"}}
+	tests := []struct {
+		file     string
+		expected string
+		found    bool
+		invalid  bool
+	}{
+		{file: "/var/log/api.log", expected: "[api.log] 2024-01-02T10:00:01Z api start|[api.log] 2024-01-02T10:00:03Z api stop", found: true},
+		{file: "all", found: true, // The stack trace keep the time of the previous line
+			expected: "[api.log] 2024-01-02T10:00:01Z api start|[db.log] 2024-01-02T10:00:02Z db query|[db.log]   at stack trace|[api.log] 2024-01-02T10:00:03Z api stop|[db.log] 2024-01-02T10:00:04Z db done"},
+		{file: "/var/log/broken.log", found: true, invalid: true},
+		{file: "broken", found: true, invalid: true},
+		{file: "/var/log/old.log"},
+		{file: "/var/log/missing.log"},
+		{file: "api.log"}, // Only the full path
+	}
+	for _, test := range tests {
+		lines, found, err := ReadLines(fileList, virtualFiles, test.file, nil)
+		if found != test.found || test.invalid != (err != nil) {
+			t.Errorf("ReadLines(%s) found %v, error %v, expected found %v, invalid %v", test.file, found, err, test.found, test.invalid)
+			continue
+		}
+		if formatted := texts(lines); formatted != test.expected {
+			t.Errorf("ReadLines(%s) = %q, expected %q", test.file, formatted, test.expected)
+		}
+	}
+	lines, _, _ := ReadLines(fileList, virtualFiles, "all", nil)
+	if lines[2].Number != 2 || lines[3].Number != 2 || lines[3].File != "/var/log/api.log" {
+		t.Errorf("unexpected lines %+v, the lines keep the number of the origin file", lines)
+	}
+}
+
+func TestExportHTTPDecompressError(t *testing.T) {
+	fileList := []datastructure.LogFileStruct{logFile("/var/log/broken.log")}
+	fileList[0].Data = []byte("not zstd")
+	logCfg := &datastructure.Configuration{VirtualFiles: []datastructure.VirtualFile{{Name: "all", Files: "*.log"}}}
+	for _, file := range []string{"/var/log/broken.log", "all"} {
+		var ctx fasthttp.RequestCtx
+		ctx.Request.SetRequestURI("/filterFromFile?format=csv")
+		ExportHTTP(&ctx, fileList, logCfg, file, query.Filter{}, 0, 0, 0)
+		var status datastructure.Status
+		if err := json.Unmarshal(ctx.Response.Body(), &status); err != nil {
+			t.Fatalf("%s: %v (%q)", file, err, ctx.Response.Body())
+		}
+		if ctx.Response.StatusCode() != fasthttp.StatusInternalServerError || status.ErrorCode != datastructure.ErrInternal || len(ctx.Response.Header.Peek("Content-Disposition")) > 0 {
+			t.Errorf("%s: %d %+v, expected an internal error instead of an empty attachment", file, ctx.Response.StatusCode(), status)
+		}
+	}
+}
@@ -11,5 +11,7 @@ require (
 	github.com/stretchr/testify v1.4.0 // indirect
 	github.com/valyala/fasthttp v1.18.0
 	github.com/valyala/gozstd v1.9.0
+	golang.org/x/crypto v0.0.0-20211117183948-ae814b36b871
+	golang.org/x/sys v0.7.0 // indirect
 	gopkg.in/check.v1 v1.0.0-20190902080502-41f04d3bba15 // indirect
 )
@@ -0,0 +1,78 @@
+// Package health track the progress of the service: the initial load of the files and the rounds of the core engine.
+//
+// The service is live while the core engine complete its rounds, and ready when the files are loaded and the engine is live.
+package health
+
+import (
+	"sync"
+	"time"
+)
+
+// State is the progress of the service at a given time
+type State struct {
+	Started   time.Time `json:"Started"`
+	Loaded    time.Time `json:"Loaded"`    // End of the initial load of the files (zero while loading)
+	Files     int       `json:"Files"`     // Number of files to load
+	Read      int       `json:"Read"`      // Number of files already loaded
+	Rounds    int       `json:"Rounds"`    // Rounds completed by the core engine
+	LastRound time.Time `json:"LastRound"` // End of the last round of the core engine
+}
+
+// Stalled verify if the core engine did not complete a round in the timeout (since the end of the load for the first round)
+func (s State) Stalled(now time.Time, timeout time.Duration) bool {
+	if s.Loaded.IsZero() {
+		return false
+	}
+	last := s.LastRound
+	if last.IsZero() {
+		last = s.Loaded
+	}
+	return now.Sub(last) > timeout
+}
+
+// Monitor contains the progress of the service. It's safe for concurrent use
+type Monitor struct {
+	mutex sync.Mutex
+	state State
+}
+
+// NewMonitor initialize the monitor of a service started at the given time
+func NewMonitor(started time.Time) *Monitor {
+	return &Monitor{state: State{Started: started}}
+}
+
+// Loading set the number of files of the initial load
+func (m *Monitor) Loading(files int) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.state.Files = files
+}
+
+// FileLoaded register the load of a file
+func (m *Monitor) FileLoaded() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.state.Read++
+}
+
+// Loaded register the end of the initial load
+func (m *Monitor) Loaded(now time.Time) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.state.Loaded = now
+}
+
+// Round register the end of a round of the core engine
+func (m *Monitor) Round(now time.Time) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.state.Rounds++
+	m.state.LastRound = now
+}
+
+// State return the current progress
+func (m *Monitor) State() State {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.state
+}
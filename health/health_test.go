@@ -0,0 +1,45 @@
+package health
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMonitor(t *testing.T) {
+	start := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+	m := NewMonitor(start)
+	m.Loading(3)
+	m.FileLoaded()
+	m.FileLoaded()
+	if state := m.State(); state.Files != 3 || state.Read != 2 || !state.Loaded.IsZero() || state.Rounds != 0 || !state.Started.Equal(start) {
+		t.Errorf("unexpected state %+v while loading", state)
+	}
+	m.FileLoaded()
+	m.Loaded(start.Add(time.Second))
+	m.Round(start.Add(2 * time.Second))
+	m.Round(start.Add(3 * time.Second))
+	if state := m.State(); state.Read != 3 || !state.Loaded.Equal(start.Add(time.Second)) || state.Rounds != 2 || !state.LastRound.Equal(start.Add(3*time.Second)) {
+		t.Errorf("unexpected state %+v after the load", state)
+	}
+}
+
+func TestStalled(t *testing.T) {
+	start := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		state   State
+		now     time.Time
+		stalled bool
+	}{
+		{name: "loading", state: State{Started: start}, now: start.Add(time.Hour)}, // The load can take long
+		{name: "first round in progress", state: State{Started: start, Loaded: start.Add(time.Minute)}, now: start.Add(2 * time.Minute)},
+		{name: "first round late", state: State{Started: start, Loaded: start.Add(time.Minute)}, now: start.Add(2*time.Minute + time.Second), stalled: true},
+		{name: "progressing", state: State{Started: start, Loaded: start, Rounds: 10, LastRound: start.Add(time.Hour)}, now: start.Add(time.Hour + time.Minute)},
+		{name: "stopped", state: State{Started: start, Loaded: start, Rounds: 10, LastRound: start.Add(time.Hour)}, now: start.Add(time.Hour + time.Minute + 1), stalled: true},
+	}
+	for _, test := range tests {
+		if stalled := test.state.Stalled(test.now, time.Minute); stalled != test.stalled {
+			t.Errorf("%s: Stalled() = %v, expected %v", test.name, stalled, test.stalled)
+		}
+	}
+}
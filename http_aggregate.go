@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alessiosavi/GoLog-Viewer/aggregate"
+	"github.com/alessiosavi/GoLog-Viewer/datastructure"
+	"github.com/alessiosavi/GoLog-Viewer/parser"
+	log "github.com/sirupsen/logrus"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/gozstd"
+)
+
+/* ------------- AGGREGATION API ------------- */
+
+// AggregateHTTP count the lines that satisfy the search criteria, grouping them in time buckets.
+// It take in input the set of files (comma separated list of path or glob, every file if missing), the search criteria (filter, level, q ...), the bucket size,
+// the optional time range (since, until), the optional group-by field and the optional numeric field used for compute min/max/avg/percentiles
+func AggregateHTTP(ctx *fasthttp.RequestCtx, fileList []datastructure.LogFileStruct, logCfg *datastructure.Configuration) {
+	log.Trace("AggregateHTTP | START")
+	ctx.Response.Header.SetContentType("application/json; charset=utf-8")
+	result, errorCode, err := AggregateHTTPEngine(ctx, fileList, logCfg)
+	if err != nil {
+		WriteError(ctx, errorCode, err.Error())
+		log.Warn("AggregateHTTP | ", errorCode, " | Params -> ", string(ctx.QueryArgs().QueryString()))
+		log.Trace("AggregateHTTP | STOP")
+		return
+	}
+	err = json.NewEncoder(ctx).Encode(datastructure.Status{Status: true, Description: "", ErrorCode: "", Data: result})
+	check(err)
+	log.Trace("AggregateHTTP | STOP")
+}
+
+// AggregateHTTPEngine validate the INPUT parameters and aggregate the lines of the selected files
+func AggregateHTTPEngine(ctx *fasthttp.RequestCtx, fileList []datastructure.LogFileStruct, logCfg *datastructure.Configuration) (aggregate.Result, string, error) {
+	files := string(ctx.FormValue("files")) // Comma separated list of file (glob are welcome), every file if missing
+	filter, errorCode, err := ParseFilterParameters(ctx, logCfg)
+	if err != nil {
+		return aggregate.Result{}, errorCode, err
+	}
+	bucket := time.Minute
+	if value := string(ctx.FormValue("bucket")); value != "" {
+		if bucket, err = time.ParseDuration(value); err != nil {
+			return aggregate.Result{}, datastructure.ErrInvalidParameter, parameterError("bucket", err)
+		}
+	}
+	now := time.Now()
+	since, err := ParseTimeParameter(string(ctx.FormValue("since")), now)
+	if err != nil {
+		return aggregate.Result{}, datastructure.ErrInvalidParameter, parameterError("since", err)
+	}
+	until, err := ParseTimeParameter(string(ctx.FormValue("until")), now)
+	if err != nil {
+		return aggregate.Result{}, datastructure.ErrInvalidParameter, parameterError("until", err)
+	}
+	if !since.IsZero() && !until.IsZero() && !since.Before(until) { // The range is validated apart, the errors of the aggregator are related to the bucket
+		return aggregate.Result{}, datastructure.ErrInvalidParameter, parameterError("until", errors.New("have to be after since ("+since.Format(time.RFC3339)+")"))
+	}
+	percentiles := []float64{50, 90, 99}
+	if value := string(ctx.FormValue("percentiles")); value != "" {
+		percentiles = percentiles[:0]
+		for _, p := range strings.Split(value, ",") {
+			n, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+			if err != nil || n <= 0 || n > 100 {
+				return aggregate.Result{}, datastructure.ErrInvalidParameter, fmt.Errorf("invalid percentile %q, use a value in (0,100]", p)
+			}
+			percentiles = append(percentiles, n)
+		}
+	}
+	groupBy := string(ctx.FormValue("groupBy"))
+	field := string(ctx.FormValue("field"))
+
+	aggregator, err := aggregate.New(bucket, since, until)
+	if err != nil {
+		return aggregate.Result{}, datastructure.ErrInvalidParameter, parameterError("bucket", err)
+	}
+	var selected []int
+	if files == "" {
+		selected = ManagedFiles(fileList)
+	} else if selected = SelectFiles(fileList, logCfg.VirtualFiles, files); len(selected) == 0 {
+		return aggregate.Result{}, datastructure.ErrFileNotFound, errors.New("file not found: " + files)
+	}
+	for _, i := range selected {
+		path := fileList[i].LogFileInfoStruct.Path
+		data, err := gozstd.Decompress(nil, fileList[i].State().Data)
+		if err != nil {
+			log.Error("AggregateHTTPEngine | Unable to decompress data of ", path, " | Err: ", err)
+			continue
+		}
+		for _, line := range parser.SplitLines(data) {
+			fields := logCfg.Parser.Fields(path, line)
+			if !filter.MatchParsed(line, fields) {
+				continue
+			}
+			t, ok := parser.ParseTimestamp(line, fields)
+			if !ok {
+				aggregator.AddUnparsed()
+				continue
+			}
+			value, hasValue := parser.LookupNumber(fields, field)
+			aggregator.Add(t, groupValue(path, line, fields, groupBy), value, hasValue)
+		}
+	}
+	result, err := aggregator.Result(percentiles)
+	if err != nil {
+		return result, datastructure.ErrInvalidParameter, parameterError("bucket", err)
+	}
+	return result, "", nil
+}
+
+// ParseTimeParameter parse a time received in input. It can be a unix timestamp (seconds, 0 is the epoch), a RFC3339 time
+// or a duration relative to now (1h -> one hour ago). An empty value return the zero time
+func ParseTimeParameter(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(n, 0), nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		if d < 0 {
+			return time.Time{}, fmt.Errorf("invalid time %q, the duration is relative to now and can't be negative", value)
+		}
+		return now.Add(-d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, use a duration (10m), a RFC3339 time or a unix timestamp", value)
+}
+
+// groupValue return the value of the group-by field for the line. The "level" and "file" group use the detected level and the path
+// if the line does not contains a field with the same name
+func groupValue(path string, line []byte, fields map[string]interface{}, groupBy string) string {
+	if groupBy == "" {
+		return "*"
+	}
+	if value, ok := parser.Lookup(fields, groupBy); ok {
+		if s, ok := value.(string); ok {
+			return s
+		}
+		return fmt.Sprint(value)
+	}
+	switch groupBy {
+	case "level":
+		return parser.DetectLevel(line).String()
+	case "file":
+		return path
+	}
+	return ""
+}
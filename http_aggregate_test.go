@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTimeParameter(t *testing.T) {
+	now := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value    string
+		expected time.Time
+		invalid  bool
+	}{
+		{value: "", expected: time.Time{}},
+		{value: "0", expected: time.Unix(0, 0)}, // The epoch, not now
+		{value: "1704189600", expected: time.Unix(1704189600, 0)},
+		{value: "2024-01-01T08:30:00Z", expected: time.Date(2024, 1, 1, 8, 30, 0, 0, time.UTC)},
+		{value: "2024-01-01T08:30:00.5+01:00", expected: time.Date(2024, 1, 1, 7, 30, 0, 5e8, time.UTC)},
+		{value: "90m", expected: now.Add(-90 * time.Minute)},
+		{value: "0s", expected: now},
+		{value: "-1h", invalid: true},
+		{value: "yesterday", invalid: true},
+		{value: "2024-01-01", invalid: true},
+	}
+	for _, test := range tests {
+		parsed, err := ParseTimeParameter(test.value, now)
+		if test.invalid {
+			if err == nil {
+				t.Errorf("ParseTimeParameter(%q) = %s, expected an error", test.value, parsed)
+			}
+			continue
+		}
+		if err != nil || !parsed.Equal(test.expected) {
+			t.Errorf("ParseTimeParameter(%q) = %s (%v), expected %s", test.value, parsed, err, test.expected)
+		}
+	}
+}
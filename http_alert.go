@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/alessiosavi/GoLog-Viewer/alert"
+	"github.com/alessiosavi/GoLog-Viewer/datastructure"
+	log "github.com/sirupsen/logrus"
+	"github.com/valyala/fasthttp"
+)
+
+/* ------------- ALERT API ------------- */
+
+// AlertsHTTP return the status of every alert (one for every rule and file)
+func AlertsHTTP(ctx *fasthttp.RequestCtx, logCfg *datastructure.Configuration) {
+	log.Trace("AlertsHTTP | START")
+	ctx.Response.Header.SetContentType("application/json; charset=utf-8")
+	err := json.NewEncoder(ctx).Encode(datastructure.Status{Status: true, Description: "", ErrorCode: "", Data: logCfg.AlertEngine.Alerts()})
+	check(err)
+	log.Trace("AlertsHTTP | STOP")
+}
+
+// ListAlertRulesHTTP return every rule (or only the one related to the "name" parameter) with the status of the related alerts
+func ListAlertRulesHTTP(ctx *fasthttp.RequestCtx, logCfg *datastructure.Configuration) {
+	log.Trace("ListAlertRulesHTTP | START")
+	ctx.Response.Header.SetContentType("application/json; charset=utf-8")
+	var err error
+	var data interface{}
+	if name := string(ctx.FormValue("name")); name != "" {
+		data, err = logCfg.AlertEngine.Rule(name)
+	} else {
+		data = logCfg.AlertEngine.Rules()
+	}
+	writeAlertResponse(ctx, "", data, err)
+	log.Trace("ListAlertRulesHTTP | STOP")
+}
+
+// CreateAlertRuleHTTP create the rule received as json in the body of the request
+func CreateAlertRuleHTTP(ctx *fasthttp.RequestCtx, logCfg *datastructure.Configuration) {
+	log.Trace("CreateAlertRuleHTTP | START")
+	ctx.Response.Header.SetContentType("application/json; charset=utf-8")
+	var rule alert.Rule
+	if err := json.Unmarshal(ctx.PostBody(), &rule); err != nil {
+		writeAlertResponse(ctx, "", nil, errors.New("invalid json body: "+err.Error()))
+		log.Trace("CreateAlertRuleHTTP | STOP")
+		return
+	}
+	rule, err := logCfg.AlertEngine.CreateRule(rule)
+	writeAlertResponse(ctx, "Rule "+rule.Name+" created", rule, err)
+	log.Trace("CreateAlertRuleHTTP | STOP")
+}
+
+// UpdateAlertRuleHTTP replace the rule related to the "name" parameter with the one received as json in the body of the request
+func UpdateAlertRuleHTTP(ctx *fasthttp.RequestCtx, logCfg *datastructure.Configuration) {
+	log.Trace("UpdateAlertRuleHTTP | START")
+	ctx.Response.Header.SetContentType("application/json; charset=utf-8")
+	name := string(ctx.FormValue("name"))
+	var rule alert.Rule
+	if err := json.Unmarshal(ctx.PostBody(), &rule); err != nil {
+		writeAlertResponse(ctx, "", nil, errors.New("invalid json body: "+err.Error()))
+		log.Trace("UpdateAlertRuleHTTP | STOP")
+		return
+	}
+	if name == "" { // The name of the body is used if the rule is not renamed
+		name = rule.Name
+	}
+	rule, err := logCfg.AlertEngine.UpdateRule(name, rule)
+	writeAlertResponse(ctx, "Rule "+name+" updated", rule, err)
+	log.Trace("UpdateAlertRuleHTTP | STOP")
+}
+
+// SilenceAlertRuleHTTP suppress the notifications of the rule for the given duration (0 remove the silence)
+func SilenceAlertRuleHTTP(ctx *fasthttp.RequestCtx, logCfg *datastructure.Configuration) {
+	log.Trace("SilenceAlertRuleHTTP | START")
+	ctx.Response.Header.SetContentType("application/json; charset=utf-8")
+	name := string(ctx.FormValue("name"))
+	duration, err := time.ParseDuration(string(ctx.FormValue("duration")))
+	if err != nil || duration < 0 {
+		WriteError(ctx, datastructure.ErrInvalidParameter, "duration: use a positive duration (30m, 2h) or 0 for remove the silence")
+		log.Trace("SilenceAlertRuleHTTP | STOP")
+		return
+	}
+	var until time.Time
+	if duration > 0 {
+		until = time.Now().Add(duration)
+	}
+	rule, err := logCfg.AlertEngine.SilenceRule(name, until)
+	writeAlertResponse(ctx, "Rule "+name+" silenced until "+until.Format(time.RFC3339), rule, err)
+	log.Trace("SilenceAlertRuleHTTP | STOP")
+}
+
+// DeleteAlertRuleHTTP delete the rule related to the "name" parameter
+func DeleteAlertRuleHTTP(ctx *fasthttp.RequestCtx, logCfg *datastructure.Configuration) {
+	log.Trace("DeleteAlertRuleHTTP | START")
+	ctx.Response.Header.SetContentType("application/json; charset=utf-8")
+	name := string(ctx.FormValue("name"))
+	err := logCfg.AlertEngine.DeleteRule(name)
+	writeAlertResponse(ctx, "Rule "+name+" deleted", nil, err)
+	log.Trace("DeleteAlertRuleHTTP | STOP")
+}
+
+// GetAlertRuleHistoryHTTP return the evaluation history of the rule related to the "name" parameter
+func GetAlertRuleHistoryHTTP(ctx *fasthttp.RequestCtx, logCfg *datastructure.Configuration) {
+	log.Trace("GetAlertRuleHistoryHTTP | START")
+	ctx.Response.Header.SetContentType("application/json; charset=utf-8")
+	history, err := logCfg.AlertEngine.History(string(ctx.FormValue("name")))
+	writeAlertResponse(ctx, "", history, err)
+	log.Trace("GetAlertRuleHistoryHTTP | STOP")
+}
+
+// writeAlertResponse encode the result of a management operation. The errors that are not related to
+// a missing/duplicated rule or to the persistence are caused by an invalid rule
+func writeAlertResponse(ctx *fasthttp.RequestCtx, description string, data interface{}, err error) {
+	if err != nil {
+		errorCode := datastructure.ErrInvalidBody
+		switch err.(type) {
+		case *alert.PersistError:
+			errorCode = datastructure.ErrUnavailable
+		}
+		switch err {
+		case alert.ErrRuleNotFound:
+			errorCode = datastructure.ErrRuleNotFound
+		case alert.ErrRuleExists:
+			errorCode = datastructure.ErrRuleAlreadyExists
+		case alert.ErrNoFile:
+			errorCode = datastructure.ErrUnavailable
+		}
+		WriteError(ctx, errorCode, err.Error())
+		return
+	}
+	err = json.NewEncoder(ctx).Encode(datastructure.Status{Status: true, Description: description, ErrorCode: "", Data: data})
+	check(err)
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/alessiosavi/GoLog-Viewer/datastructure"
+	log "github.com/sirupsen/logrus"
+	"github.com/valyala/fasthttp"
+)
+
+/* ------------- API V1 ------------- */
+
+// APIPrefix is the path of the versioned API. The legacy paths (/getFile, /filterFromFile ...) are kept as aliases
+const APIPrefix = "/api/v1"
+
+// APIParameter is a query parameter accepted by a resource of the API
+type APIParameter struct {
+	Name        string
+	Type        string // string, integer, number, boolean
+	Description string
+	Required    bool
+}
+
+// APIRoute bind a method and a path of the versioned API to the handler
+type APIRoute struct {
+	Method      string
+	Path        string // Path relative to the APIPrefix
+	Summary     string
+	Legacy      string // Legacy path that expose the same handler
+	Parameters  []APIParameter
+	Body        string // Schema of the json body required by the request (empty if the request has no body)
+	PlainOutput bool   // The response is plain text, unless json is enabled
+	Handler     func(ctx *fasthttp.RequestCtx, fileList []datastructure.LogFileStruct, logCfg *datastructure.Configuration)
+}
+
+var filterParameters = []APIParameter{
+	{Name: "filter", Type: "string", Description: "Text that have to be contained in the line"},
+	{Name: "ignoreCase", Type: "boolean", Description: "Search the text ignoring the case"},
+	{Name: "reverse", Type: "boolean", Description: "Select the lines that does not contain the text"},
+	{Name: "level", Type: "string", Description: "Comma separated list of levels, + select the more severe levels too (warn+)"},
+	{Name: "q", Type: "string", Description: "Query on the fields of the line (user_id=42 AND latency_ms>500)"},
+}
+
+var outputParameters = []APIParameter{
+	{Name: "json", Type: "boolean", Description: "Return the lines inside the json status instead of plain text"},
+	{Name: "ansi", Type: "string", Description: "How the ANSI escape sequences are returned: keep, strip, html"},
+}
+
+var followParameter = APIParameter{Name: "follow", Type: "boolean", Description: "The request is part of a live tail, the client is counted as subscriber of the file (metrics)"}
+
+// params concatenate the lists of parameters
+func params(lists ...[]APIParameter) []APIParameter {
+	var parameters []APIParameter
+	for _, list := range lists {
+		parameters = append(parameters, list...)
+	}
+	return parameters
+}
+
+// APIRoutes contains every resource of the versioned API
+var APIRoutes = []APIRoute{
+	{Method: "GET", Path: "/files", Summary: "List the managed files (virtual files included) with the number of lines for every level", Legacy: "/listAllFile",
+		Handler: ListAllFilesHTTP},
+	{Method: "GET", Path: "/files/content", Summary: "Return the lines in memory of the file", Legacy: "/getFile", PlainOutput: true,
+		Parameters: params([]APIParameter{{Name: "file", Type: "string", Description: "Path of the file or name of the virtual file", Required: true}}, outputParameters,
+			[]APIParameter{{Name: "format", Type: "string", Description: "Download the lines as attachment: text, ndjson, csv optionally followed by .gz or .zst"}, followParameter}),
+		Handler: FastGetFileHTTP},
+	{Method: "GET", Path: "/search", Summary: "Return the lines of the file that satisfy the filter (streamed)", Legacy: "/filterFromFile", PlainOutput: true,
+		Parameters: params([]APIParameter{{Name: "file", Type: "string", Description: "Path of the file or name of the virtual file", Required: true}}, filterParameters, outputParameters,
+			[]APIParameter{{Name: "format", Type: "string", Description: "Download the lines as attachment: text, ndjson, csv optionally followed by .gz or .zst"},
+				{Name: "limit", Type: "integer", Description: "Stop after the given number of lines"}, followParameter}),
+		Handler: FastFilterFileHTTP},
+	{Method: "GET", Path: "/aggregate", Summary: "Count the lines in time buckets", Legacy: "/aggregate",
+		Parameters: params([]APIParameter{
+			{Name: "files", Type: "string", Description: "Comma separated list of path/glob (every file if missing)"},
+			{Name: "bucket", Type: "string", Description: "Size of the buckets (1m)"},
+			{Name: "since", Type: "string", Description: "Start of the range: duration (1h), RFC3339 or unix time"},
+			{Name: "until", Type: "string", Description: "End of the range: duration, RFC3339 or unix time"},
+			{Name: "groupBy", Type: "string", Description: "file, level or the name of a field"},
+			{Name: "field", Type: "string", Description: "Numeric field used for compute the statistics"},
+			{Name: "percentiles", Type: "string", Description: "Comma separated list of percentiles of the field (50,95,99)"},
+		}, filterParameters),
+		Handler: AggregateHTTP},
+	{Method: "GET", Path: "/patterns", Summary: "Return the most frequent message templates", Legacy: "/patterns",
+		Parameters: params([]APIParameter{
+			{Name: "file", Type: "string", Description: "Comma separated list of path/glob", Required: true},
+			{Name: "top", Type: "integer", Description: "Number of templates returned (20)"},
+		}, filterParameters),
+		Handler: PatternsHTTP},
+	{Method: "GET", Path: "/patterns/new", Summary: "Return the templates observed for the first time", Legacy: "/newPatterns",
+		Parameters: []APIParameter{
+			{Name: "file", Type: "string", Description: "Comma separated list of path/glob (every file if missing)"},
+			{Name: "since", Type: "string", Description: "Start of the range (10m)"},
+		},
+		Handler: NewPatternsHTTP},
+	{Method: "GET", Path: "/correlate", Summary: "Return the lines of every file related to the request id, ordered by time", Legacy: "/correlate", PlainOutput: true,
+		Parameters: params([]APIParameter{
+			{Name: "id", Type: "string", Description: "Correlation id to search", Required: true},
+			{Name: "field", Type: "string", Description: "Field that contains the correlation id"},
+			{Name: "files", Type: "string", Description: "Comma separated list of path/glob (every file if missing)"},
+		}, outputParameters),
+		Handler: CorrelateHTTP},
+	{Method: "GET", Path: "/diff", Summary: "Compare the message templates of two files, or of the same file in two time windows", Legacy: "/diff",
+		Parameters: params([]APIParameter{
+			{Name: "left", Type: "string", Description: "Files of the left side"},
+			{Name: "right", Type: "string", Description: "Files of the right side"},
+			{Name: "file", Type: "string", Description: "Files of both the sides"},
+			{Name: "leftSince", Type: "string", Description: "Start of the left window"},
+			{Name: "leftUntil", Type: "string", Description: "End of the left window"},
+			{Name: "rightSince", Type: "string", Description: "Start of the right window"},
+			{Name: "rightUntil", Type: "string", Description: "End of the right window"},
+			{Name: "factor", Type: "number", Description: "Minimum ratio among the frequencies of a changed template (2)"},
+			{Name: "minCount", Type: "integer", Description: "Minimum number of lines of a changed template (5)"},
+		}, filterParameters),
+		Handler: DiffHTTP},
+	{Method: "GET", Path: "/alerts", Summary: "Return the status of the alerts", Legacy: "/alerts",
+		Handler: func(ctx *fasthttp.RequestCtx, _ []datastructure.LogFileStruct, logCfg *datastructure.Configuration) {
+			AlertsHTTP(ctx, logCfg)
+		}},
+	{Method: "GET", Path: "/alerts/rules", Summary: "Return the alert rules with the last evaluation", Legacy: "/listAlertRules",
+		Parameters: []APIParameter{{Name: "name", Type: "string", Description: "Return only the given rule"}},
+		Handler: func(ctx *fasthttp.RequestCtx, _ []datastructure.LogFileStruct, logCfg *datastructure.Configuration) {
+			ListAlertRulesHTTP(ctx, logCfg)
+		}},
+	{Method: "POST", Path: "/alerts/rules", Summary: "Create the alert rule received in the body", Legacy: "/createAlertRule", Body: "AlertRule",
+		Handler: func(ctx *fasthttp.RequestCtx, _ []datastructure.LogFileStruct, logCfg *datastructure.Configuration) {
+			CreateAlertRuleHTTP(ctx, logCfg)
+		}},
+	{Method: "PUT", Path: "/alerts/rules", Summary: "Replace the alert rule with the one received in the body", Legacy: "/updateAlertRule", Body: "AlertRule",
+		Parameters: []APIParameter{{Name: "name", Type: "string", Description: "Name of the rule", Required: true}},
+		Handler: func(ctx *fasthttp.RequestCtx, _ []datastructure.LogFileStruct, logCfg *datastructure.Configuration) {
+			UpdateAlertRuleHTTP(ctx, logCfg)
+		}},
+	{Method: "DELETE", Path: "/alerts/rules", Summary: "Delete the alert rule", Legacy: "/deleteAlertRule",
+		Parameters: []APIParameter{{Name: "name", Type: "string", Description: "Name of the rule", Required: true}},
+		Handler: func(ctx *fasthttp.RequestCtx, _ []datastructure.LogFileStruct, logCfg *datastructure.Configuration) {
+			DeleteAlertRuleHTTP(ctx, logCfg)
+		}},
+	{Method: "POST", Path: "/alerts/rules/silence", Summary: "Suppress the notifications of the rule (duration=0 remove the silence)", Legacy: "/silenceAlertRule",
+		Parameters: []APIParameter{
+			{Name: "name", Type: "string", Description: "Name of the rule", Required: true},
+			{Name: "duration", Type: "string", Description: "Duration of the silence (1h)", Required: true},
+		},
+		Handler: func(ctx *fasthttp.RequestCtx, _ []datastructure.LogFileStruct, logCfg *datastructure.Configuration) {
+			SilenceAlertRuleHTTP(ctx, logCfg)
+		}},
+	{Method: "GET", Path: "/alerts/rules/history", Summary: "Return the evaluation history of the rule", Legacy: "/getAlertRuleHistory",
+		Parameters: []APIParameter{{Name: "name", Type: "string", Description: "Name of the rule", Required: true}},
+		Handler: func(ctx *fasthttp.RequestCtx, _ []datastructure.LogFileStruct, logCfg *datastructure.Configuration) {
+			GetAlertRuleHistoryHTTP(ctx, logCfg)
+		}},
+	{Method: "GET", Path: "/config", Summary: "Return the active configuration", Legacy: "/getLinePrinted",
+		Handler: func(ctx *fasthttp.RequestCtx, _ []datastructure.LogFileStruct, logCfg *datastructure.Configuration) {
+			FastGetLinePrintedHTTP(ctx, logCfg)
+		}},
+	{Method: "PUT", Path: "/config", Summary: "Validate and apply the settings received in the body (MinLinesToPrint, MaxLinesToSearch, Sleep, GCSleep, Include, Exclude, Files), returning the changes", Legacy: "/updateConfig", Body: "Settings",
+		Parameters: []APIParameter{{Name: "dryRun", Type: "boolean", Description: "Only validate the settings and return the changes"}},
+		Handler: func(ctx *fasthttp.RequestCtx, _ []datastructure.LogFileStruct, logCfg *datastructure.Configuration) {
+			UpdateConfigHTTP(ctx, logCfg)
+		}},
+	{Method: "GET", Path: "/stats", Summary: "Return the statistics of the files in memory",
+		Handler: StatsHTTP},
+	{Method: "GET", Path: "/status", Summary: "Return the state of the service (load, core engine rounds) with the errors of every file", Legacy: "/debug/status",
+		Handler: DebugStatusHTTP},
+	{Method: "GET", Path: "/metrics", Summary: "Return the metrics of the viewer in the Prometheus text format", Legacy: "/metrics", PlainOutput: true,
+		Handler: MetricsHTTP},
+}
+
+func init() { // The specification is built from the routes, so it can't be part of their initialization
+	APIRoutes = append(APIRoutes, APIRoute{Method: "GET", Path: "/openapi.json", Summary: "Return the OpenAPI specification of the API", Handler: OpenAPIHTTP})
+}
+
+// LegacyRoute return the route of the versioned API exposed by the given legacy path. The legacy paths accept every method
+func LegacyRoute(path string) (APIRoute, bool) {
+	for _, route := range APIRoutes {
+		if route.Legacy != "" && route.Legacy == path {
+			return route, true
+		}
+	}
+	return APIRoute{}, false
+}
+
+// APIHTTP dispatch the request to the resource of the versioned API related to the path and the method
+func APIHTTP(ctx *fasthttp.RequestCtx, fileList []datastructure.LogFileStruct, logCfg *datastructure.Configuration) {
+	log.Trace("APIHTTP | START")
+	path := strings.TrimSuffix(strings.TrimPrefix(string(ctx.Path()), APIPrefix), "/")
+	method := string(ctx.Method())
+	var allowed []string
+	for _, route := range APIRoutes {
+		if route.Path != path {
+			continue
+		}
+		if route.Method == method || route.Method == "GET" && method == "HEAD" {
+			route.Handler(ctx, fileList, logCfg)
+			log.Trace("APIHTTP | STOP")
+			return
+		}
+		allowed = append(allowed, route.Method)
+	}
+	if len(allowed) == 0 {
+		WriteError(ctx, datastructure.ErrResourceNotFound, "The resource "+string(ctx.Path())+" does not exist, see "+APIPrefix+"/openapi.json")
+	} else {
+		ctx.Response.Header.Set("Allow", strings.Join(allowed, ", "))
+		WriteError(ctx, datastructure.ErrMethodNotAllowed, method+" is not supported, use "+strings.Join(allowed, ", "))
+	}
+	log.Trace("APIHTTP | STOP")
+}
+
+/* ------------- STATS ------------- */
+
+// FileStats contains the statistics of a file in memory
+type FileStats struct {
+	Path            string         `json:"Path"`
+	Lines           int            `json:"Lines"`           // Number of lines in memory
+	Levels          map[string]int `json:"Levels"`          // Number of lines for every level
+	CompressedBytes int            `json:"CompressedBytes"` // Size of the (compressed) lines in memory
+	Bytes           int            `json:"Bytes"`           // Size of the (uncompressed) lines in memory
+	IngestedBytes   int64          `json:"IngestedBytes"`   // Bytes of the file already ingested
+	Templates       int            `json:"Templates"`       // Number of templates observed since the start
+}
+
+// Stats contains the statistics of every file and the totals
+type Stats struct {
+	Files           []FileStats    `json:"Files"`
+	Lines           int            `json:"Lines"`
+	Levels          map[string]int `json:"Levels"`
+	CompressedBytes int            `json:"CompressedBytes"`
+	Bytes           int            `json:"Bytes"`
+}
+
+// StatsHTTP return the statistics of the files in memory
+func StatsHTTP(ctx *fasthttp.RequestCtx, fileList []datastructure.LogFileStruct, logCfg *datastructure.Configuration) {
+	log.Trace("StatsHTTP | START")
+	stats := Stats{Files: make([]FileStats, 0, len(fileList)), Levels: make(map[string]int)}
+	for _, i := range ManagedFiles(fileList) {
+		state := fileList[i].State()
+		info := state.Info
+		fileStats := FileStats{Path: info.Path, Levels: info.Levels, CompressedBytes: len(state.Data), Bytes: info.Bytes, IngestedBytes: info.Offset}
+		for level, n := range info.Levels {
+			fileStats.Lines += n
+			stats.Levels[level] += n
+		}
+		if state.Templates != nil {
+			fileStats.Templates = state.Templates.Len()
+		}
+		stats.Lines += fileStats.Lines
+		stats.CompressedBytes += fileStats.CompressedBytes
+		stats.Bytes += fileStats.Bytes
+		stats.Files = append(stats.Files, fileStats)
+	}
+	ctx.Response.Header.SetContentType("application/json; charset=utf-8")
+	err := json.NewEncoder(ctx).Encode(datastructure.Status{Status: true, Description: "", ErrorCode: "", Data: stats})
+	check(err)
+	log.Trace("StatsHTTP | STOP")
+}
+
+/* ------------- OPENAPI ------------- */
+
+// OpenAPIDocument build the OpenAPI (3.0) specification of the versioned API from the routes
+func OpenAPIDocument(hostname, port string) map[string]interface{} {
+	statusSchema := map[string]interface{}{"$ref": "#/components/schemas/Status"}
+	paths := make(map[string]interface{})
+	for _, route := range APIRoutes {
+		operations, ok := paths[APIPrefix+route.Path].(map[string]interface{})
+		if !ok {
+			operations = make(map[string]interface{})
+			paths[APIPrefix+route.Path] = operations
+		}
+		parameters := make([]interface{}, 0, len(route.Parameters))
+		for _, parameter := range route.Parameters {
+			parameters = append(parameters, map[string]interface{}{
+				"name": parameter.Name, "in": "query", "required": parameter.Required, "description": parameter.Description,
+				"schema": map[string]interface{}{"type": parameter.Type},
+			})
+		}
+		content := map[string]interface{}{"application/json": map[string]interface{}{"schema": statusSchema}}
+		if route.PlainOutput {
+			content["text/plain"] = map[string]interface{}{"schema": map[string]interface{}{"type": "string"}}
+		}
+		operation := map[string]interface{}{
+			"summary":     route.Summary,
+			"operationId": operationID(route),
+			"tags":        []string{strings.Split(strings.TrimPrefix(route.Path, "/"), "/")[0]},
+			"parameters":  parameters,
+			"responses": map[string]interface{}{
+				"200":     map[string]interface{}{"description": "Result of the request", "content": content},
+				"default": map[string]interface{}{"description": "Error, the ErrorCode identify the cause", "content": map[string]interface{}{"application/json": map[string]interface{}{"schema": statusSchema}}},
+			},
+		}
+		if route.Body != "" {
+			operation["requestBody"] = map[string]interface{}{"required": true, "content": map[string]interface{}{
+				"application/json": map[string]interface{}{"schema": map[string]interface{}{"$ref": "#/components/schemas/" + route.Body}}}}
+		}
+		if route.Legacy != "" {
+			operation["x-legacy-path"] = route.Legacy
+		}
+		operations[strings.ToLower(route.Method)] = operation
+	}
+	var legacy []string
+	for _, route := range APIRoutes {
+		if route.Legacy != "" {
+			legacy = append(legacy, route.Legacy)
+		}
+	}
+	sort.Strings(legacy)
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "GoLog Viewer",
+			"version":     "1",
+			"description": "Expose the log files over HTTP. The legacy paths (" + strings.Join(legacy, ", ") + ") are kept as aliases of the related resources",
+		},
+		"servers": []interface{}{map[string]interface{}{"url": "http://" + hostname + ":" + port}},
+		"paths":   paths,
+		"components": map[string]interface{}{"schemas": map[string]interface{}{
+			"Status": map[string]interface{}{"type": "object", "properties": map[string]interface{}{
+				"Status":      map[string]interface{}{"type": "boolean"},
+				"ErrorCode":   map[string]interface{}{"type": "string", "enum": errorCodes()},
+				"Description": map[string]interface{}{"type": "string"},
+				"Data":        map[string]interface{}{},
+				"RequestID":   map[string]interface{}{"type": "string", "description": "Identifier of the request (X-Request-ID), populated in case of error"},
+			}},
+			"AlertRule": map[string]interface{}{"type": "object", "required": []string{"Name"}, "properties": map[string]interface{}{
+				"Name":           map[string]interface{}{"type": "string"},
+				"Files":          map[string]interface{}{"type": "string"},
+				"Filter":         map[string]interface{}{"type": "string"},
+				"IgnoreCase":     map[string]interface{}{"type": "boolean"},
+				"Level":          map[string]interface{}{"type": "string"},
+				"Query":          map[string]interface{}{"type": "string"},
+				"Threshold":      map[string]interface{}{"type": "integer"},
+				"Window":         map[string]interface{}{"type": "string"},
+				"For":            map[string]interface{}{"type": "string"},
+				"RepeatInterval": map[string]interface{}{"type": "string"},
+				"Webhooks":       map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+				"SilencedUntil":  map[string]interface{}{"type": "string", "format": "date-time"},
+			}},
+			"Settings": map[string]interface{}{"type": "object", "description": "The missing settings are kept, the lists are replaced", "properties": map[string]interface{}{
+				"MinLinesToPrint":  map[string]interface{}{"type": "integer", "minimum": 1},
+				"MaxLinesToSearch": map[string]interface{}{"type": "integer", "minimum": 1},
+				"Sleep":            map[string]interface{}{"type": "integer", "minimum": 1},
+				"GCSleep":          map[string]interface{}{"type": "integer", "minimum": 1},
+				"MaxBytes":         map[string]interface{}{"type": "integer", "minimum": 0, "description": "0 for no limit"},
+				"Include":          map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+				"Exclude":          map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+				"Files":            map[string]interface{}{"type": "array", "items": map[string]interface{}{"$ref": "#/components/schemas/FileSettings"}},
+			}},
+			"FileSettings": map[string]interface{}{"type": "object", "required": []string{"Files"}, "description": "The missing settings inherit the global ones", "properties": map[string]interface{}{
+				"Files":            map[string]interface{}{"type": "string", "description": "Comma separated list of path/glob"},
+				"MinLinesToPrint":  map[string]interface{}{"type": "integer"},
+				"MaxLinesToSearch": map[string]interface{}{"type": "integer"},
+				"Sleep":            map[string]interface{}{"type": "integer"},
+				"MaxBytes":         map[string]interface{}{"type": "integer"},
+			}},
+		}},
+	}
+}
+
+// errorCodes return the catalogue of the error codes (the empty code is used for the successful requests)
+func errorCodes() []string {
+	codes := []string{""}
+	for code := range datastructure.ErrorStatusCodes {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// operationID return the identifier of the operation (GET /alerts/rules -> getAlertsRules)
+func operationID(route APIRoute) string {
+	id := strings.ToLower(route.Method)
+	for _, token := range strings.FieldsFunc(route.Path, func(r rune) bool { return r == '/' || r == '.' }) {
+		id += strings.ToUpper(token[:1]) + token[1:]
+	}
+	return id
+}
+
+// OpenAPIHTTP return the OpenAPI specification of the versioned API
+func OpenAPIHTTP(ctx *fasthttp.RequestCtx, _ []datastructure.LogFileStruct, logCfg *datastructure.Configuration) {
+	log.Trace("OpenAPIHTTP | START")
+	ctx.Response.Header.SetContentType("application/json; charset=utf-8")
+	err := json.NewEncoder(ctx).Encode(OpenAPIDocument(*logCfg.Hostname, strconv.Itoa(*logCfg.Port)))
+	check(err)
+	log.Trace("OpenAPIHTTP | STOP")
+}
@@ -0,0 +1,65 @@
+package main
+
+import "testing"
+
+func TestLegacyRoute(t *testing.T) {
+	tests := []struct {
+		legacy string
+		method string
+		path   string // Path of the route in the versioned API, empty if the legacy path does not exist
+	}{
+		{legacy: "/listAllFile", method: "GET", path: "/files"},
+		{legacy: "/getFile", method: "GET", path: "/files/content"},
+		{legacy: "/filterFromFile", method: "GET", path: "/search"},
+		{legacy: "/newPatterns", method: "GET", path: "/patterns/new"},
+		{legacy: "/createAlertRule", method: "POST", path: "/alerts/rules"},
+		{legacy: "/updateAlertRule", method: "PUT", path: "/alerts/rules"},
+		{legacy: "/deleteAlertRule", method: "DELETE", path: "/alerts/rules"},
+		{legacy: "/getLinePrinted", method: "GET", path: "/config"},
+		{legacy: "/updateConfig", method: "PUT", path: "/config"},
+		{legacy: "/debug/status", method: "GET", path: "/status"},
+		{legacy: "/files"},
+		{legacy: "/changeLine"}, // Not part of the versioned API
+		{legacy: ""},
+	}
+	for _, test := range tests {
+		route, ok := LegacyRoute(test.legacy)
+		if ok != (test.path != "") || route.Path != test.path || route.Method != test.method {
+			t.Errorf("LegacyRoute(%q) = %s %s (%v), expected %s %s", test.legacy, route.Method, route.Path, ok, test.method, test.path)
+		}
+	}
+	legacy := make(map[string]string)
+	for _, route := range APIRoutes {
+		if route.Legacy == "" {
+			continue
+		}
+		if other, ok := legacy[route.Legacy]; ok {
+			t.Errorf("%s is the legacy path of %s and %s %s", route.Legacy, other, route.Method, route.Path)
+		}
+		legacy[route.Legacy] = route.Method + " " + route.Path
+		if route.Handler == nil {
+			t.Errorf("%s %s without handler", route.Method, route.Path)
+		}
+	}
+}
+
+func TestMetricPath(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{path: "/filterFromFile", expected: "/filterFromFile"},
+		{path: "/api/v1/search", expected: "/api/v1/search"},
+		{path: "/api/v1/openapi.json", expected: "/api/v1/openapi.json"},
+		{path: "/changeLine", expected: "/changeLine"},
+		{path: "/healthz", expected: "/healthz"},
+		{path: "/ui/app.js", expected: "/ui/"},
+		{path: "/api/v1/unknown", expected: "other"},
+		{path: "/wp-login.php", expected: "other"},
+	}
+	for _, test := range tests {
+		if path := metricPath(test.path); path != test.expected {
+			t.Errorf("metricPath(%s) = %s, expected %s", test.path, path, test.expected)
+		}
+	}
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"github.com/alessiosavi/GoLog-Viewer/datastructure"
+	"github.com/valyala/fasthttp"
+)
+
+/* ------------- AUTHENTICATION ------------- */
+
+// AuthorizeHTTP verify the credentials of the request (Authorization header) when the authentication is enabled.
+// The unauthorized requests receive the error, the browsers are asked for the basic credentials
+func AuthorizeHTTP(ctx *fasthttp.RequestCtx, logCfg *datastructure.Configuration) bool {
+	if logCfg.Auth.Authorize(string(ctx.Request.Header.Peek("Authorization"))) {
+		return true
+	}
+	ctx.Response.Header.Set("WWW-Authenticate", `Basic realm="GoLog-Viewer", charset="UTF-8"`)
+	WriteError(ctx, datastructure.ErrUnauthorized, "missing or invalid credentials (Authorization: Bearer <token> or Basic)")
+	return false
+}
+
+// redactedHeaders return the headers of the request without the credentials, in order to be logged
+func redactedHeaders(ctx *fasthttp.RequestCtx) string {
+	if len(ctx.Request.Header.Peek("Authorization")) == 0 {
+		return ctx.Request.Header.String()
+	}
+	var header fasthttp.RequestHeader
+	ctx.Request.Header.CopyTo(&header)
+	header.Set("Authorization", "[REDACTED]")
+	return header.String()
+}
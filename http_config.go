@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strconv"
+
+	"github.com/alessiosavi/GoLog-Viewer/config"
+	"github.com/alessiosavi/GoLog-Viewer/datastructure"
+	log "github.com/sirupsen/logrus"
+	"github.com/valyala/fasthttp"
+)
+
+/* ------------- CONFIGURATION API ------------- */
+
+// Active is the configuration in use: the settings defined at startup followed by the ones that can be changed at runtime
+type Active struct {
+	*datastructure.Configuration
+	config.Settings
+}
+
+// ActiveConfiguration return the configuration in use
+func ActiveConfiguration(logCfg *datastructure.Configuration) Active {
+	return Active{Configuration: logCfg, Settings: logCfg.Settings.Get()}
+}
+
+// UpdateConfigHTTP change the runtime settings with the ones received as json in the body of the request. The settings not present
+// in the body are kept, the lists (Include, Exclude, Files) are replaced. The new settings are validated and applied atomically,
+// with dryRun are only validated. The response contains the settings before and after the change with the list of changes
+func UpdateConfigHTTP(ctx *fasthttp.RequestCtx, logCfg *datastructure.Configuration) {
+	log.Trace("UpdateConfigHTTP | START")
+	ctx.Response.Header.SetContentType("application/json; charset=utf-8")
+	dryRun, errorCode, err := ParseBoolParameter(ctx, "dryRun")
+	if err != nil {
+		WriteError(ctx, errorCode, err.Error())
+		log.Trace("UpdateConfigHTTP | STOP")
+		return
+	}
+	body := ctx.PostBody()
+	if len(bytes.TrimSpace(body)) == 0 {
+		WriteError(ctx, datastructure.ErrInvalidBody, `missing json body ({"MaxLinesToSearch": 50000, "Exclude": ["*.gz"]})`)
+		log.Trace("UpdateConfigHTTP | STOP")
+		return
+	}
+	var invalidBody bool
+	diff, err := logCfg.Settings.Update(func(settings *config.Settings) error {
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(settings); err != nil {
+			invalidBody = true
+			return errors.New("invalid json body: " + err.Error())
+		}
+		return nil
+	}, dryRun)
+	if err != nil {
+		errorCode = datastructure.ErrInvalidParameter
+		if invalidBody {
+			errorCode = datastructure.ErrInvalidBody
+		}
+		WriteError(ctx, errorCode, err.Error())
+		log.Trace("UpdateConfigHTTP | STOP")
+		return
+	}
+	description := strconv.Itoa(len(diff.Changes)) + " settings changed"
+	if dryRun {
+		description = "Settings valid, " + strconv.Itoa(len(diff.Changes)) + " settings would change"
+	}
+	err = json.NewEncoder(ctx).Encode(datastructure.Status{Status: true, Description: description, ErrorCode: "", Data: diff})
+	check(err)
+	if diff.Applied {
+		for _, change := range diff.Changes {
+			log.Warn("UpdateConfigHTTP | ", RequestID(ctx), " | ", change.Field, " changed from ", change.Before, " to ", change.After)
+		}
+	}
+	log.Trace("UpdateConfigHTTP | STOP")
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alessiosavi/GoLog-Viewer/datastructure"
+	"github.com/alessiosavi/GoLog-Viewer/parser"
+	log "github.com/sirupsen/logrus"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/gozstd"
+)
+
+/* ------------- CORRELATION API ------------- */
+
+// TimelineEntry is a line of the timeline, labelled with the source file
+type TimelineEntry struct {
+	File      string     `json:"File"`
+	Timestamp *time.Time `json:"Timestamp"` // Time parsed from the line, null if the line does not contains a recognizable time
+	Line      string     `json:"Line"`
+}
+
+// CorrelateHTTP search the correlation id in every managed file and return the matching lines merged in a single timeline,
+// ordered by the parsed timestamp (the lines without timestamp are appended at the end).
+// A line match if the correlation field (configured or "field" parameter) is equal to the id, or if the line contains the id.
+// The "ansi" parameter (keep, strip, html) define how the ANSI escape sequences are returned
+func CorrelateHTTP(ctx *fasthttp.RequestCtx, fileList []datastructure.LogFileStruct, logCfg *datastructure.Configuration) {
+	log.Trace("CorrelateHTTP | START")
+	id := string(ctx.FormValue("id"))       // Correlation id to search
+	field := string(ctx.FormValue("field")) // Field that contains the correlation id
+	files := string(ctx.FormValue("files")) // Optional, restrict the search to the given files
+	if field == "" {
+		field = *logCfg.CorrelationField
+	}
+	if strings.Compare(id, "") == 0 {
+		WriteError(ctx, datastructure.ErrMissingParameter, missingParameterError("id", "/correlate?id=4bf92f3577b34da6&field=trace_id").Error())
+		log.Trace("CorrelateHTTP | STOP")
+		return
+	}
+	mode, err := parser.ParseANSIMode(string(ctx.FormValue("ansi")))
+	if err != nil {
+		WriteError(ctx, datastructure.ErrInvalidParameter, parameterError("ansi", err).Error())
+		log.Trace("CorrelateHTTP | STOP")
+		return
+	}
+	jsonOutput, errorCode, err := ParseBoolParameter(ctx, "json")
+	if err != nil {
+		WriteError(ctx, errorCode, err.Error())
+		log.Trace("CorrelateHTTP | STOP")
+		return
+	}
+	timeline, err := CorrelateHTTPEngine(fileList, logCfg, id, field, files)
+	if err != nil {
+		WriteError(ctx, datastructure.ErrFileNotFound, err.Error())
+		log.Trace("CorrelateHTTP | STOP")
+		return
+	}
+	if jsonOutput {
+		ctx.Response.Header.SetContentType("application/json; charset=utf-8")
+		for i := range timeline {
+			timeline[i].Line = string(mode.Apply([]byte(timeline[i].Line)))
+		}
+		err := json.NewEncoder(ctx).Encode(datastructure.Status{Status: true, Description: "", ErrorCode: "", Data: timeline})
+		check(err)
+	} else {
+		ctx.Response.Header.SetContentType("text/plain; charset=utf-8")
+		var buffer bytes.Buffer
+		for _, entry := range timeline {
+			buffer.WriteString("[" + entry.File + "] " + entry.Line + "\n")
+		}
+		_, err := ctx.Write(mode.Apply(buffer.Bytes()))
+		check(err)
+	}
+	log.Info("CorrelateHTTP | ", len(timeline), " lines for ", field, "=", id)
+	log.Trace("CorrelateHTTP | STOP")
+}
+
+// CorrelateHTTPEngine collect the lines related to the correlation id from the selected files (every file if empty)
+func CorrelateHTTPEngine(fileList []datastructure.LogFileStruct, logCfg *datastructure.Configuration, id, field, files string) ([]TimelineEntry, error) {
+	var selected []int
+	if files == "" {
+		selected = ManagedFiles(fileList)
+	} else if selected = SelectFiles(fileList, logCfg.VirtualFiles, files); len(selected) == 0 {
+		return nil, errors.New(files)
+	}
+	timeline := []TimelineEntry{}
+	toFind := []byte(id)
+	for _, i := range selected {
+		path := fileList[i].LogFileInfoStruct.Path
+		data, err := gozstd.Decompress(nil, fileList[i].State().Data)
+		if err != nil {
+			log.Error("CorrelateHTTPEngine | Unable to decompress data of ", path, " | Err: ", err)
+			continue
+		}
+		for _, line := range parser.SplitLines(data) {
+			if !bytes.Contains(parser.StripANSI(line), toFind) { // The id have to be contained in the line in any case
+				continue
+			}
+			fields := logCfg.Parser.Fields(path, line)
+			if fields != nil && field != "" {
+				if value, ok := parser.Lookup(fields, field); ok && fmt.Sprint(value) != id {
+					continue // The id is contained in another field
+				}
+			}
+			entry := TimelineEntry{File: path, Line: string(line)}
+			if t, ok := parser.ParseTimestamp(line, fields); ok {
+				entry.Timestamp = &t
+			}
+			timeline = append(timeline, entry)
+		}
+	}
+	sort.SliceStable(timeline, func(i, j int) bool {
+		if timeline[i].Timestamp == nil || timeline[j].Timestamp == nil {
+			return timeline[i].Timestamp != nil && timeline[j].Timestamp == nil
+		}
+		return timeline[i].Timestamp.Before(*timeline[j].Timestamp)
+	})
+	return timeline, nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alessiosavi/GoLog-Viewer/datastructure"
+)
+
+func TestCorrelateHTTPEngine(t *testing.T) {
+	fileList := []datastructure.LogFileStruct{
+		logFile("/var/log/api.log",
+			"2024-01-02T10:00:03Z INFO request abc123 completed",
+			`{"time": "2024-01-02T10:00:01Z", "trace_id": "abc123", "msg": "request received"}`,
+			`{"time": "2024-01-02T10:00:02Z", "trace_id": "zzz", "parent": "abc123", "msg": "child span"}`, // The id is in another field
+			"retry of abc123 scheduled",
+		),
+		logFile("/var/log/db.log",
+			`{"time": "2024-01-02T10:00:02.5Z", "trace_id": "abc123", "msg": "query"}`,
+			"2024-01-02T09:59:59Z DEBUG cache warmup for abc123",
+			"2024-01-02T10:00:04Z INFO unrelated",
+			"\x1b[31mabc\x1b[0m123 coloured, without time",
+		),
+		logFile("/var/log/old.log", "2024-01-02T10:00:00Z abc123 excluded"),
+	}
+	fileList[2].Excluded = true
+	logCfg := &datastructure.Configuration{VirtualFiles: []datastructure.VirtualFile{{Name: "backend", Files: "db.log"}}}
+	tests := []struct {
+		name     string
+		field    string
+		files    string
+		expected []string // File and line, in order
+	}{
+		{name: "every file", field: "trace_id", expected: []string{
+			"db.log 2024-01-02T09:59:59Z DEBUG cache warmup for abc123",
+			`api.log {"time": "2024-01-02T10:00:01Z", "trace_id": "abc123", "msg": "request received"}`,
+			`db.log {"time": "2024-01-02T10:00:02.5Z", "trace_id": "abc123", "msg": "query"}`,
+			"api.log 2024-01-02T10:00:03Z INFO request abc123 completed",
+			"api.log retry of abc123 scheduled", // Without timestamp, at the end in the order of the files
+			"db.log \x1b[31mabc\x1b[0m123 coloured, without time",
+		}},
+		{name: "without field", expected: []string{
+			"db.log 2024-01-02T09:59:59Z DEBUG cache warmup for abc123",
+			`api.log {"time": "2024-01-02T10:00:01Z", "trace_id": "abc123", "msg": "request received"}`,
+			`api.log {"time": "2024-01-02T10:00:02Z", "trace_id": "zzz", "parent": "abc123", "msg": "child span"}`,
+			`db.log {"time": "2024-01-02T10:00:02.5Z", "trace_id": "abc123", "msg": "query"}`,
+			"api.log 2024-01-02T10:00:03Z INFO request abc123 completed",
+			"api.log retry of abc123 scheduled",
+			"db.log \x1b[31mabc\x1b[0m123 coloured, without time",
+		}},
+		{name: "virtual file", field: "trace_id", files: "backend", expected: []string{
+			"db.log 2024-01-02T09:59:59Z DEBUG cache warmup for abc123",
+			`db.log {"time": "2024-01-02T10:00:02.5Z", "trace_id": "abc123", "msg": "query"}`,
+			"db.log \x1b[31mabc\x1b[0m123 coloured, without time",
+		}},
+	}
+	for _, test := range tests {
+		timeline, err := CorrelateHTTPEngine(fileList, logCfg, "abc123", test.field, test.files)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+			continue
+		}
+		var lines []string
+		for _, entry := range timeline {
+			lines = append(lines, strings.TrimPrefix(entry.File, "/var/log/")+" "+entry.Line)
+		}
+		if strings.Join(lines, "\n") != strings.Join(test.expected, "\n") {
+			t.Errorf("%s: timeline\n%s\nexpected\n%s", test.name, strings.Join(lines, "\n"), strings.Join(test.expected, "\n"))
+		}
+	}
+	timeline, _ := CorrelateHTTPEngine(fileList, logCfg, "abc123", "trace_id", "")
+	if timeline[0].Timestamp == nil || timeline[len(timeline)-1].Timestamp != nil {
+		t.Errorf("unexpected timestamps %+v", timeline)
+	}
+	if timeline, err := CorrelateHTTPEngine(fileList, logCfg, "abc123", "", "missing.log"); err == nil {
+		t.Errorf("CorrelateHTTPEngine() = %v, expected an error for the unknown files", timeline)
+	}
+	if timeline, err := CorrelateHTTPEngine(fileList, logCfg, "nothing", "", ""); err != nil || timeline == nil || len(timeline) != 0 {
+		t.Errorf("CorrelateHTTPEngine() = %v (%v), expected an empty timeline", timeline, err)
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/alessiosavi/GoLog-Viewer/datastructure"
+	"github.com/alessiosavi/GoLog-Viewer/drain"
+	"github.com/alessiosavi/GoLog-Viewer/parser"
+	"github.com/alessiosavi/GoLog-Viewer/query"
+	log "github.com/sirupsen/logrus"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/gozstd"
+)
+
+/* ------------- DIFF API ------------- */
+
+// DiffSide is one of the two sides compared: a set of files and an optional time window
+type DiffSide struct {
+	Files string    `json:"Files"`
+	Since time.Time `json:"Since"`
+	Until time.Time `json:"Until"`
+	Lines int       `json:"Lines"` // Number of lines of the side
+}
+
+// TemplateDiff compare the number of lines of a template on the two sides
+type TemplateDiff struct {
+	Template   string   `json:"Template"`
+	Left       int      `json:"Left"`             // Number of lines on the left side
+	Right      int      `json:"Right"`            // Number of lines on the right side
+	LeftRatio  float64  `json:"LeftRatio"`        // Frequency of the template on the left side (lines of the template / lines of the side)
+	RightRatio float64  `json:"RightRatio"`       // Frequency of the template on the right side
+	Factor     float64  `json:"Factor,omitempty"` // How many times the template is more frequent on one side than on the other one, omitted if the template is only on one side
+	Examples   []string `json:"Examples"`
+}
+
+// DiffResult is the response of the diff API
+type DiffResult struct {
+	Left      DiffSide       `json:"Left"`
+	Right     DiffSide       `json:"Right"`
+	OnlyLeft  []TemplateDiff `json:"OnlyLeft"`  // Templates present only on the left side
+	OnlyRight []TemplateDiff `json:"OnlyRight"` // Templates present only on the right side
+	Changed   []TemplateDiff `json:"Changed"`   // Templates present on both sides with a large difference of frequency
+}
+
+// DiffHTTP compare two files (left, right), or the same file (file) in two time windows (leftSince, leftUntil, rightSince, rightUntil),
+// at template level. It return the templates present only on one side and the ones with a frequency that differ at least
+// "factor" times (default 2) with at least "minCount" lines (default 5)
+func DiffHTTP(ctx *fasthttp.RequestCtx, fileList []datastructure.LogFileStruct, logCfg *datastructure.Configuration) {
+	log.Trace("DiffHTTP | START")
+	ctx.Response.Header.SetContentType("application/json; charset=utf-8")
+	result, errorCode, err := DiffHTTPEngine(ctx, fileList, logCfg)
+	if err != nil {
+		WriteError(ctx, errorCode, err.Error())
+		log.Warn("DiffHTTP | ", errorCode, " | Params -> ", string(ctx.QueryArgs().QueryString()))
+		log.Trace("DiffHTTP | STOP")
+		return
+	}
+	err = json.NewEncoder(ctx).Encode(datastructure.Status{Status: true, Description: "", ErrorCode: "", Data: result})
+	check(err)
+	log.Trace("DiffHTTP | STOP")
+}
+
+// DiffHTTPEngine validate the INPUT parameters and compare the templates of the two sides
+func DiffHTTPEngine(ctx *fasthttp.RequestCtx, fileList []datastructure.LogFileStruct, logCfg *datastructure.Configuration) (DiffResult, string, error) {
+	var result DiffResult
+	file := string(ctx.FormValue("file"))
+	result.Left.Files, result.Right.Files = string(ctx.FormValue("left")), string(ctx.FormValue("right"))
+	if result.Left.Files == "" {
+		result.Left.Files = file
+	}
+	if result.Right.Files == "" {
+		result.Right.Files = file
+	}
+	if result.Left.Files == "" || result.Right.Files == "" {
+		return result, datastructure.ErrMissingParameter, missingParameterError("left,right", "/diff?left=healthy.log&right=failing.log or /diff?file=file_name&leftSince=2h&leftUntil=1h&rightSince=1h")
+	}
+	now := time.Now()
+	var err error
+	for _, param := range []struct {
+		name  string
+		value *time.Time
+	}{{"leftSince", &result.Left.Since}, {"leftUntil", &result.Left.Until}, {"rightSince", &result.Right.Since}, {"rightUntil", &result.Right.Until}} {
+		if *param.value, err = ParseTimeParameter(string(ctx.FormValue(param.name)), now); err != nil {
+			return result, datastructure.ErrInvalidParameter, parameterError(param.name, err)
+		}
+	}
+	if result.Left.Files == result.Right.Files && result.Left.Since.IsZero() && result.Left.Until.IsZero() && result.Right.Since.IsZero() && result.Right.Until.IsZero() {
+		return result, datastructure.ErrMissingParameter, missingParameterError("leftSince,rightSince", "comparing the same file require at least a time window")
+	}
+	factor, minCount := 2.0, 5
+	if value := string(ctx.FormValue("factor")); value != "" {
+		if factor, err = strconv.ParseFloat(value, 64); err != nil || factor < 1 {
+			return result, datastructure.ErrInvalidParameter, errors.New("factor have to be a number greater or equal than 1")
+		}
+	}
+	var errorCode string
+	if minCount, errorCode, err = ParseIntParameter(ctx, "minCount", minCount, 0); err != nil {
+		return result, errorCode, err
+	}
+	filter, errorCode, err := ParseFilterParameters(ctx, logCfg)
+	if err != nil {
+		return result, errorCode, err
+	}
+
+	miner := drain.New()
+	counts := make(map[*drain.Cluster]*[2]int)
+	for i, side := range []*DiffSide{&result.Left, &result.Right} {
+		selected := SelectFiles(fileList, logCfg.VirtualFiles, side.Files)
+		if len(selected) == 0 {
+			return result, datastructure.ErrFileNotFound, errors.New("file not found: " + side.Files)
+		}
+		for _, j := range selected {
+			side.Lines += diffMine(miner, counts, i, &fileList[j], side, filter, logCfg.Parser)
+		}
+	}
+
+	for cluster, count := range counts {
+		d := TemplateDiff{Template: cluster.Template, Left: count[0], Right: count[1], Examples: cluster.Examples}
+		if result.Left.Lines > 0 {
+			d.LeftRatio = float64(d.Left) / float64(result.Left.Lines)
+		}
+		if result.Right.Lines > 0 {
+			d.RightRatio = float64(d.Right) / float64(result.Right.Lines)
+		}
+		switch {
+		case d.Right == 0:
+			result.OnlyLeft = append(result.OnlyLeft, d)
+		case d.Left == 0:
+			result.OnlyRight = append(result.OnlyRight, d)
+		default:
+			d.Factor = math.Max(d.LeftRatio/d.RightRatio, d.RightRatio/d.LeftRatio)
+			if d.Factor >= factor && (d.Left >= minCount || d.Right >= minCount) {
+				result.Changed = append(result.Changed, d)
+			}
+		}
+	}
+	sortDiff(result.OnlyLeft, func(d TemplateDiff) int { return d.Left })
+	sortDiff(result.OnlyRight, func(d TemplateDiff) int { return d.Right })
+	sort.Slice(result.Changed, func(i, j int) bool {
+		if result.Changed[i].Factor != result.Changed[j].Factor {
+			return result.Changed[i].Factor > result.Changed[j].Factor
+		}
+		return result.Changed[i].Template < result.Changed[j].Template
+	})
+	for _, templates := range []*[]TemplateDiff{&result.OnlyLeft, &result.OnlyRight, &result.Changed} {
+		if *templates == nil {
+			*templates = []TemplateDiff{}
+		}
+	}
+	return result, "", nil
+}
+
+// diffMine add to the miner the lines of the file that are inside the time window of the side, counting them for the side
+func diffMine(miner *drain.Drain, counts map[*drain.Cluster]*[2]int, side int, logFile *datastructure.LogFileStruct, window *DiffSide, filter query.Filter, lineParser *parser.Parser) int {
+	path := logFile.LogFileInfoStruct.Path
+	data, err := gozstd.Decompress(nil, logFile.State().Data)
+	if err != nil {
+		log.Error("diffMine | Unable to decompress data of ", path, " | Err: ", err)
+		return 0
+	}
+	var n int
+	var last time.Time // The lines without timestamp inherit the time of the previous line
+	for _, line := range parser.SplitLines(data) {
+		fields := lineParser.Fields(path, line)
+		if t, ok := parser.ParseTimestamp(line, fields); ok {
+			last = t
+		}
+		if !window.Since.IsZero() && (last.IsZero() || last.Before(window.Since)) || !window.Until.IsZero() && (last.IsZero() || !last.Before(window.Until)) {
+			continue
+		}
+		if !filter.MatchParsed(line, fields) {
+			continue
+		}
+		cluster := miner.Add(string(parser.StripANSI(line)), last)
+		if counts[cluster] == nil {
+			counts[cluster] = &[2]int{}
+		}
+		counts[cluster][side]++
+		n++
+	}
+	return n
+}
+
+// sortDiff order the templates by count, then by template (the order of the clusters is random)
+func sortDiff(templates []TemplateDiff, count func(TemplateDiff) int) {
+	sort.Slice(templates, func(i, j int) bool {
+		if count(templates[i]) != count(templates[j]) {
+			return count(templates[i]) > count(templates[j])
+		}
+		return templates[i].Template < templates[j].Template
+	})
+}
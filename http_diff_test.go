@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/alessiosavi/GoLog-Viewer/datastructure"
+	"github.com/valyala/fasthttp"
+)
+
+// repeat return the line n times, %d is replaced by the index
+func repeat(line string, n int) []string {
+	var lines []string
+	for i := 0; i < n; i++ {
+		lines = append(lines, strings.Replace(line, "%d", strconv.Itoa(i), -1))
+	}
+	return lines
+}
+
+// summary return the template and the counts of the templates
+func summary(templates []TemplateDiff) string {
+	var s []string
+	for _, d := range templates {
+		s = append(s, d.Template+" "+strconv.Itoa(d.Left)+"/"+strconv.Itoa(d.Right))
+	}
+	return strings.Join(s, ", ")
+}
+
+func TestDiffHTTPEngine(t *testing.T) {
+	var healthy, failing, app []string
+	healthy = append(append(append(healthy, repeat("user %d logged in", 10)...), repeat("cache miss for key", 2)...), repeat("heartbeat ok", 4)...)
+	failing = append(append(append(failing, repeat("user %d logged in", 10)...), repeat("cache miss for key", 8)...), repeat("disk full on sda", 3)...)
+	app = append(append(app, repeat("2024-01-02T10:00:0%dZ request served", 10)...), repeat("2024-01-02T10:00:1%dZ request served", 5)...)
+	for i := 5; i < 10; i++ {
+		app = append(app, "2024-01-02T10:00:1"+strconv.Itoa(i)+"Z timeout talking to db", "  at db.connect") // The stack trace inherit the time
+	}
+	fileList := []datastructure.LogFileStruct{logFile("/var/log/healthy.log", healthy...), logFile("/var/log/failing.log", failing...), logFile("/var/log/app.log", app...)}
+	logCfg := testConfiguration(t)
+	tests := []struct {
+		name                       string
+		params                     string
+		onlyLeft, onlyRight, chang string
+		left, right                int
+	}{
+		{name: "files", params: "left=healthy.log&right=failing.log", left: 16, right: 21,
+			onlyLeft: "heartbeat ok 4/0", onlyRight: "disk full on sda 0/3", chang: "cache miss for key 2/8"},
+		{name: "factor", params: "left=healthy.log&right=failing.log&factor=4", left: 16, right: 21,
+			onlyLeft: "heartbeat ok 4/0", onlyRight: "disk full on sda 0/3"},
+		{name: "factor of every template", params: "left=healthy.log&right=failing.log&factor=1", left: 16, right: 21,
+			onlyLeft: "heartbeat ok 4/0", onlyRight: "disk full on sda 0/3", chang: "cache miss for key 2/8, user <NUM> logged in 10/10"},
+		{name: "min count", params: "left=healthy.log&right=failing.log&minCount=9", left: 16, right: 21,
+			onlyLeft: "heartbeat ok 4/0", onlyRight: "disk full on sda 0/3"},
+		{name: "filter", params: "left=healthy.log&right=failing.log&filter=user", left: 10, right: 10},
+		{name: "time windows", params: "file=app.log&leftUntil=2024-01-02T10:00:10Z&rightSince=2024-01-02T10:00:10Z", left: 10, right: 15,
+			onlyRight: "<TIME> timeout talking to db 0/5, at db.connect 0/5", chang: "<TIME> request served 10/5"},
+		{name: "empty window", params: "file=app.log&leftSince=2024-01-02T11:00:00Z&rightSince=2024-01-02T10:00:10Z", left: 0, right: 15,
+			onlyRight: "<TIME> request served 0/5, <TIME> timeout talking to db 0/5, at db.connect 0/5"},
+	}
+	for _, test := range tests {
+		var ctx fasthttp.RequestCtx
+		ctx.Request.SetRequestURI("/diff?" + test.params)
+		result, errorCode, err := DiffHTTPEngine(&ctx, fileList, logCfg)
+		if err != nil {
+			t.Errorf("%s: unexpected error %s %v", test.name, errorCode, err)
+			continue
+		}
+		if result.Left.Lines != test.left || result.Right.Lines != test.right {
+			t.Errorf("%s: %d/%d lines, expected %d/%d", test.name, result.Left.Lines, result.Right.Lines, test.left, test.right)
+		}
+		for _, check := range []struct {
+			kind      string
+			templates []TemplateDiff
+			expected  string
+		}{{"only left", result.OnlyLeft, test.onlyLeft}, {"only right", result.OnlyRight, test.onlyRight}, {"changed", result.Changed, test.chang}} {
+			if s := summary(check.templates); s != check.expected {
+				t.Errorf("%s: %s %q, expected %q", test.name, check.kind, s, check.expected)
+			}
+		}
+	}
+}
+
+func TestDiffHTTPEngineInvalid(t *testing.T) {
+	fileList := []datastructure.LogFileStruct{logFile("/var/log/app.log", "2024-01-02T10:00:00Z started")}
+	logCfg := testConfiguration(t)
+	tests := []struct {
+		params    string
+		errorCode string
+	}{
+		{params: "left=app.log", errorCode: datastructure.ErrMissingParameter},
+		{params: "file=app.log", errorCode: datastructure.ErrMissingParameter}, // The same file without a time window
+		{params: "left=app.log&right=missing.log", errorCode: datastructure.ErrFileNotFound},
+		{params: "file=app.log&leftSince=yesterday", errorCode: datastructure.ErrInvalidParameter},
+		{params: "left=app.log&right=app.log&rightSince=1h&factor=0.5", errorCode: datastructure.ErrInvalidParameter},
+		{params: "left=app.log&right=app.log&rightSince=1h&minCount=x", errorCode: datastructure.ErrInvalidParameter},
+	}
+	for _, test := range tests {
+		var ctx fasthttp.RequestCtx
+		ctx.Request.SetRequestURI("/diff?" + test.params)
+		if _, errorCode, err := DiffHTTPEngine(&ctx, fileList, logCfg); err == nil || errorCode != test.errorCode {
+			t.Errorf("%s: error %s %v, expected %s", test.params, errorCode, err, test.errorCode)
+		}
+	}
+}
+
+func TestDiffHTTPFactor(t *testing.T) {
+	fileList := []datastructure.LogFileStruct{logFile("/var/log/healthy.log", repeat("heartbeat ok", 4)...), logFile("/var/log/failing.log", repeat("disk full on sda", 3)...)}
+	var ctx fasthttp.RequestCtx
+	ctx.Request.SetRequestURI("/diff?left=healthy.log&right=failing.log")
+	DiffHTTP(&ctx, fileList, testConfiguration(t))
+	var status struct {
+		Status bool
+		Data   struct{ OnlyLeft, OnlyRight []map[string]interface{} }
+	}
+	if err := json.Unmarshal(ctx.Response.Body(), &status); err != nil {
+		t.Fatalf("invalid json %q: %v", ctx.Response.Body(), err)
+	}
+	if len(status.Data.OnlyLeft) != 1 || len(status.Data.OnlyRight) != 1 {
+		t.Fatalf("unexpected response %s", ctx.Response.Body())
+	}
+	for _, d := range append(status.Data.OnlyLeft, status.Data.OnlyRight...) {
+		if _, ok := d["Factor"]; ok {
+			t.Errorf("the factor of the templates only on one side have to be omitted: %v", d)
+		}
+	}
+}
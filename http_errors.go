@@ -0,0 +1,67 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/alessiosavi/GoLog-Viewer/datastructure"
+	log "github.com/sirupsen/logrus"
+	"github.com/valyala/fasthttp"
+)
+
+/* ------------- ERRORS ------------- */
+
+const requestIDKey = "requestID"
+
+// SetRequestID assign an identifier to the request: the X-Request-ID header of the client is used when valid, otherwise a new one is generated.
+// The identifier is returned in the X-Request-ID header of the response
+func SetRequestID(ctx *fasthttp.RequestCtx) string {
+	requestID := string(ctx.Request.Header.Peek("X-Request-ID"))
+	if !validRequestID(requestID) {
+		buffer := make([]byte, 8)
+		if _, err := rand.Read(buffer); err != nil {
+			requestID = strconv.FormatInt(time.Now().UnixNano(), 16)
+		} else {
+			requestID = hex.EncodeToString(buffer)
+		}
+	}
+	ctx.SetUserValue(requestIDKey, requestID)
+	ctx.Response.Header.Set("X-Request-ID", requestID)
+	return requestID
+}
+
+// validRequestID accept the identifiers up to 64 characters, composed by letters, digits, '-', '_' and '.'
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > 64 {
+		return false
+	}
+	for _, c := range requestID {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+	return true
+}
+
+// RequestID return the identifier assigned to the request
+func RequestID(ctx *fasthttp.RequestCtx) string {
+	requestID, _ := ctx.UserValue(requestIDKey).(string)
+	return requestID
+}
+
+// WriteError write the json status related to the error, with the HTTP status code related to the error code (see datastructure.ErrorStatusCodes)
+func WriteError(ctx *fasthttp.RequestCtx, errorCode, description string) {
+	statusCode, ok := datastructure.ErrorStatusCodes[errorCode]
+	if !ok {
+		log.Error("WriteError | Error code not in the catalogue: ", errorCode)
+		statusCode = fasthttp.StatusInternalServerError
+	}
+	ctx.SetStatusCode(statusCode)
+	ctx.Response.Header.SetContentType("application/json; charset=utf-8")
+	err := json.NewEncoder(ctx).Encode(datastructure.Status{Status: false, Description: description, ErrorCode: errorCode, Data: nil, RequestID: RequestID(ctx)})
+	check(err)
+	log.Warn("WriteError | ", RequestID(ctx), " | ", statusCode, " ", errorCode, " | ", description, " | Params -> ", string(ctx.QueryArgs().QueryString()))
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/alessiosavi/GoLog-Viewer/datastructure"
+	"github.com/valyala/fasthttp"
+)
+
+// generatedID is the format of the identifiers generated by the service
+var generatedID = regexp.MustCompile(`^[0-9a-f]{16}$`)
+
+func TestWriteError(t *testing.T) {
+	tests := []struct {
+		errorCode string
+		status    int
+	}{
+		{datastructure.ErrMissingParameter, fasthttp.StatusBadRequest},
+		{datastructure.ErrInvalidParameter, fasthttp.StatusBadRequest},
+		{datastructure.ErrInvalidBody, fasthttp.StatusBadRequest},
+		{datastructure.ErrUnauthorized, fasthttp.StatusUnauthorized},
+		{datastructure.ErrFileNotFound, fasthttp.StatusNotFound},
+		{datastructure.ErrRuleNotFound, fasthttp.StatusNotFound},
+		{datastructure.ErrResourceNotFound, fasthttp.StatusNotFound},
+		{datastructure.ErrMethodNotAllowed, fasthttp.StatusMethodNotAllowed},
+		{datastructure.ErrRuleAlreadyExists, fasthttp.StatusConflict},
+		{datastructure.ErrInternal, fasthttp.StatusInternalServerError},
+		{datastructure.ErrUnavailable, fasthttp.StatusServiceUnavailable},
+		{"NOT_IN_CATALOGUE", fasthttp.StatusInternalServerError},
+	}
+	if len(tests)-1 != len(datastructure.ErrorStatusCodes) {
+		t.Errorf("%d error codes tested, the catalogue contains %d", len(tests)-1, len(datastructure.ErrorStatusCodes))
+	}
+	for _, test := range tests {
+		var ctx fasthttp.RequestCtx
+		ctx.Request.Header.Set("X-Request-ID", "req-1")
+		SetRequestID(&ctx)
+		WriteError(&ctx, test.errorCode, "description of "+test.errorCode)
+		if ctx.Response.StatusCode() != test.status {
+			t.Errorf("%s: status %d, expected %d", test.errorCode, ctx.Response.StatusCode(), test.status)
+		}
+		if contentType := string(ctx.Response.Header.ContentType()); contentType != "application/json; charset=utf-8" {
+			t.Errorf("%s: content type %q", test.errorCode, contentType)
+		}
+		var status datastructure.Status
+		if err := json.Unmarshal(ctx.Response.Body(), &status); err != nil {
+			t.Fatalf("%s: invalid json %q: %v", test.errorCode, ctx.Response.Body(), err)
+		}
+		if status.Status || status.ErrorCode != test.errorCode || status.Description != "description of "+test.errorCode || status.RequestID != "req-1" {
+			t.Errorf("%s: status %+v", test.errorCode, status)
+		}
+	}
+}
+
+func TestSetRequestID(t *testing.T) {
+	tests := []struct {
+		header   string
+		expected string // Empty if a new identifier have to be generated
+	}{
+		{header: "req-1", expected: "req-1"},
+		{header: "A.b_C-9", expected: "A.b_C-9"},
+		{header: strings.Repeat("x", 64), expected: strings.Repeat("x", 64)},
+		{header: ""},
+		{header: strings.Repeat("x", 65)},
+		{header: "req 1"},
+		{header: "req/1"},
+		{header: "<script>"},
+		{header: "réq"},
+	}
+	handler := testHandler(t, nil)
+	for _, test := range tests {
+		ctx := serve(handler, APIPrefix+"/missing", "X-Request-ID", test.header)
+		requestID := string(ctx.Response.Header.Peek("X-Request-ID"))
+		if test.expected != "" && requestID != test.expected {
+			t.Errorf("%q: X-Request-ID %q, expected %q", test.header, requestID, test.expected)
+		}
+		if test.expected == "" && !generatedID.MatchString(requestID) {
+			t.Errorf("%q: X-Request-ID %q, expected a generated identifier", test.header, requestID)
+		}
+		if ctx.Response.StatusCode() != fasthttp.StatusNotFound {
+			t.Errorf("%q: status %d, expected %d", test.header, ctx.Response.StatusCode(), fasthttp.StatusNotFound)
+		}
+		var status datastructure.Status
+		if err := json.Unmarshal(ctx.Response.Body(), &status); err != nil {
+			t.Fatalf("%q: invalid json %q: %v", test.header, ctx.Response.Body(), err)
+		}
+		if status.ErrorCode != datastructure.ErrResourceNotFound || status.RequestID != requestID {
+			t.Errorf("%q: status %+v, expected %s with request id %q", test.header, status, datastructure.ErrResourceNotFound, requestID)
+		}
+	}
+	first := serve(handler, APIPrefix+"/missing").Response.Header.Peek("X-Request-ID")
+	second := serve(handler, APIPrefix+"/missing").Response.Header.Peek("X-Request-ID")
+	if string(first) == string(second) {
+		t.Errorf("the generated identifiers are equal: %s", first)
+	}
+}
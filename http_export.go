@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alessiosavi/GoLog-Viewer/datastructure"
+	"github.com/alessiosavi/GoLog-Viewer/parser"
+	"github.com/alessiosavi/GoLog-Viewer/query"
+	log "github.com/sirupsen/logrus"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/gozstd"
+)
+
+/* ------------- EXPORT ------------- */
+
+// ExportFormat is the format requested for download the lines: the encoding (text, ndjson, csv) optionally followed by the compression (gz, zst)
+type ExportFormat struct {
+	Encoding    string
+	Compression string
+}
+
+// ExportRecord is a line exported in the NDJSON format
+type ExportRecord struct {
+	File      string                 `json:"File"`
+	Line      int                    `json:"Line"` // Number of the line among the lines in memory of the file
+	Timestamp *time.Time             `json:"Timestamp"`
+	Fields    map[string]interface{} `json:"Fields"`
+	Text      string                 `json:"Text"`
+}
+
+var exportContentTypes = map[string]string{
+	"text":   "text/plain; charset=utf-8",
+	"ndjson": "application/x-ndjson; charset=utf-8",
+	"csv":    "text/csv; charset=utf-8",
+	"gz":     "application/gzip",
+	"zst":    "application/zstd",
+}
+
+// ParseExportFormat parse the format parameter (ndjson, csv.gz, text.zst, gz). The compression alone is related to the text encoding
+func ParseExportFormat(format string) (ExportFormat, error) {
+	var exportFormat ExportFormat
+	tokens := strings.SplitN(strings.ToLower(strings.TrimSpace(format)), ".", 2)
+	switch tokens[0] {
+	case "gz", "zst":
+		if len(tokens) > 1 {
+			return exportFormat, errors.New("invalid format: " + format)
+		}
+		exportFormat.Encoding, exportFormat.Compression = "text", tokens[0]
+	case "text", "ndjson", "csv":
+		exportFormat.Encoding = tokens[0]
+		if len(tokens) > 1 {
+			if tokens[1] != "gz" && tokens[1] != "zst" {
+				return exportFormat, errors.New("invalid compression: " + tokens[1] + " (gz, zst)")
+			}
+			exportFormat.Compression = tokens[1]
+		}
+	default:
+		return exportFormat, errors.New("invalid format: " + format + " (text, ndjson, csv optionally followed by .gz or .zst)")
+	}
+	return exportFormat, nil
+}
+
+// Extension return the extension of the file downloaded (ndjson.gz)
+func (f ExportFormat) Extension() string {
+	extension := f.Encoding
+	if extension == "text" {
+		extension = "log"
+	}
+	if f.Compression != "" {
+		extension += "." + f.Compression
+	}
+	return extension
+}
+
+// ExportHTTP write the lines of the file that satisfy the filter in the requested format, as an attachment.
+// Only the last maxLines lines are evaluated (0 for evaluate every line in memory) and at most limit lines are exported (0 for no limit)
+func ExportHTTP(ctx *fasthttp.RequestCtx, fileList []datastructure.LogFileStruct, logCfg *datastructure.Configuration, file string, filter query.Filter, maxLines, limit int, mode parser.ANSIMode) {
+	log.Trace("ExportHTTP | START")
+	format, err := ParseExportFormat(string(ctx.FormValue("format")))
+	if err != nil {
+		WriteError(ctx, datastructure.ErrInvalidParameter, err.Error())
+		log.Trace("ExportHTTP | STOP")
+		return
+	}
+	lines, ok, err := ReadLines(fileList, logCfg.VirtualFiles, file, logCfg.Parser)
+	if !ok {
+		WriteError(ctx, datastructure.ErrFileNotFound, "file not found: "+file)
+		log.Trace("ExportHTTP | STOP")
+		return
+	}
+	if err != nil {
+		WriteError(ctx, datastructure.ErrInternal, err.Error())
+		log.Trace("ExportHTTP | STOP")
+		return
+	}
+	if maxLines > 0 && len(lines) > maxLines {
+		lines = lines[len(lines)-maxLines:]
+	}
+	if !filter.IsEmpty() {
+		var filtered []LogLine
+		for _, line := range lines {
+			if filter.Match(line.File, line.Text) {
+				filtered = append(filtered, line)
+			}
+		}
+		lines = filtered
+	}
+	if limit > 0 && len(lines) > limit {
+		lines = lines[:limit]
+	}
+	_, virtual := FindVirtualFile(logCfg.VirtualFiles, file)
+	contentType := exportContentTypes[format.Encoding]
+	if format.Compression != "" {
+		contentType = exportContentTypes[format.Compression]
+	}
+	name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file)) + "-" + time.Now().Format("20060102T150405") + "." + format.Extension()
+	ctx.Response.Header.SetContentType(contentType)
+	ctx.Response.Header.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	// The lines are encoded while they are sent (chunked transfer), the request context can't be used inside the writer
+	ctx.SetBodyStreamWriter(func(w *bufio.Writer) {
+		activeStreams.Add(1)
+		defer activeStreams.Add(-1)
+		err := ExportLines(w, lines, format, virtual, logCfg.Parser, mode)
+		if err == nil {
+			err = w.Flush()
+		}
+		if err != nil {
+			log.Warn("ExportHTTP | Export of ", file, " interrupted | Err: ", err)
+			return
+		}
+		log.Info("ExportHTTP | ", len(lines), " lines of ", file, " exported as ", name)
+	})
+	log.Trace("ExportHTTP | STOP")
+}
+
+// ExportLines write the lines in the given format (ndjson.gz), the compression is applied while the lines are encoded.
+// The ANSI mode is applied to the text of the lines
+func ExportLines(w io.Writer, lines []LogLine, format ExportFormat, virtual bool, lineParser *parser.Parser, mode parser.ANSIMode) error {
+	switch format.Compression {
+	case "gz":
+		writer := gzip.NewWriter(w)
+		if err := encodeLines(writer, lines, format.Encoding, virtual, lineParser, mode); err != nil {
+			writer.Close()
+			return err
+		}
+		return writer.Close()
+	case "zst":
+		writer := gozstd.NewWriter(w)
+		defer writer.Release()
+		if err := encodeLines(writer, lines, format.Encoding, virtual, lineParser, mode); err != nil {
+			return err
+		}
+		return writer.Close()
+	}
+	return encodeLines(w, lines, format.Encoding, virtual, lineParser, mode)
+}
+
+// encodeLines write the lines in the given encoding (text, ndjson, csv)
+func encodeLines(w io.Writer, lines []LogLine, encoding string, virtual bool, lineParser *parser.Parser, mode parser.ANSIMode) error {
+	switch encoding {
+	case "ndjson":
+		encoder := json.NewEncoder(w)
+		for _, line := range lines {
+			if err := encoder.Encode(newExportRecord(line, lineParser, mode)); err != nil {
+				return err
+			}
+		}
+	case "csv":
+		records := make([]ExportRecord, len(lines)) // The columns are the fields of every line, known only after have parsed all of them
+		keys := make(map[string]struct{})
+		for i, line := range lines {
+			records[i] = newExportRecord(line, lineParser, mode)
+			for key := range records[i].Fields {
+				keys[key] = struct{}{}
+			}
+		}
+		columns := make([]string, 0, len(keys))
+		for key := range keys {
+			columns = append(columns, key)
+		}
+		sort.Strings(columns)
+		writer := csv.NewWriter(w)
+		if err := writer.Write(append(append([]string{"File", "Line", "Timestamp"}, columns...), "Text")); err != nil {
+			return err
+		}
+		for _, record := range records {
+			row := []string{record.File, strconv.Itoa(record.Line), ""}
+			if record.Timestamp != nil {
+				row[2] = record.Timestamp.Format(time.RFC3339Nano)
+			}
+			for _, column := range columns {
+				row = append(row, csvValue(record.Fields[column]))
+			}
+			if err := writer.Write(append(row, record.Text)); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	default:
+		for _, line := range lines {
+			text := line.Text
+			if virtual {
+				text = []byte(FormatVirtualLine(line))
+			}
+			if _, err := w.Write(mode.Apply(text)); err != nil {
+				return err
+			}
+			if _, err := w.Write([]byte{'\n'}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func newExportRecord(line LogLine, lineParser *parser.Parser, mode parser.ANSIMode) ExportRecord {
+	record := ExportRecord{File: line.File, Line: line.Number, Text: string(mode.Apply(line.Text))}
+	record.Fields = lineParser.Fields(line.File, line.Text)
+	if t, ok := parser.ParseTimestamp(line.Text, record.Fields); ok {
+		record.Timestamp = &t
+	}
+	return record
+}
+
+// csvValue convert the value of a field to a CSV cell. The objects and the arrays are encoded in json
+func csvValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case map[string]interface{}, []interface{}:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(data)
+	}
+	return fmt.Sprint(value)
+}
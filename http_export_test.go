@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/alessiosavi/GoLog-Viewer/parser"
+	"github.com/valyala/gozstd"
+)
+
+func TestParseExportFormat(t *testing.T) {
+	tests := []struct {
+		format    string
+		expected  ExportFormat
+		extension string
+		invalid   bool
+	}{
+		{format: "text", expected: ExportFormat{Encoding: "text"}, extension: "log"},
+		{format: "ndjson", expected: ExportFormat{Encoding: "ndjson"}, extension: "ndjson"},
+		{format: " CSV ", expected: ExportFormat{Encoding: "csv"}, extension: "csv"},
+		{format: "ndjson.gz", expected: ExportFormat{Encoding: "ndjson", Compression: "gz"}, extension: "ndjson.gz"},
+		{format: "csv.zst", expected: ExportFormat{Encoding: "csv", Compression: "zst"}, extension: "csv.zst"},
+		{format: "gz", expected: ExportFormat{Encoding: "text", Compression: "gz"}, extension: "log.gz"},
+		{format: "zst", expected: ExportFormat{Encoding: "text", Compression: "zst"}, extension: "log.zst"},
+		{format: "", invalid: true},
+		{format: "xml", invalid: true},
+		{format: "csv.bz2", invalid: true},
+		{format: "gz.zst", invalid: true},
+		{format: "ndjson.gz.gz", invalid: true},
+	}
+	for _, test := range tests {
+		format, err := ParseExportFormat(test.format)
+		if test.invalid {
+			if err == nil {
+				t.Errorf("ParseExportFormat(%q) = %+v, expected an error", test.format, format)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseExportFormat(%q): unexpected error %v", test.format, err)
+			continue
+		}
+		if format != test.expected || format.Extension() != test.extension {
+			t.Errorf("ParseExportFormat(%q) = %+v (%s), expected %+v (%s)", test.format, format, format.Extension(), test.expected, test.extension)
+		}
+	}
+}
+
+func TestExportLines(t *testing.T) {
+	lines := []LogLine{
+		{File: "/var/log/app.log", Number: 1, Text: []byte("2024-01-02T10:00:00Z \x1b[31mERROR\x1b[0m failed")},
+		{File: "/var/log/app.log", Number: 2, Text: []byte(`{"level": "info", "user": {"id": 42}, "msg": "a, \"quoted\" line"}`)},
+	}
+	tests := []struct {
+		format   string
+		virtual  bool
+		mode     parser.ANSIMode
+		expected string
+	}{
+		{format: "text", mode: parser.ANSIKeep, expected: string(lines[0].Text) + "\n" + string(lines[1].Text) + "\n"},
+		{format: "text.gz", mode: parser.ANSIStrip, expected: "2024-01-02T10:00:00Z ERROR failed\n" + string(lines[1].Text) + "\n"},
+		{format: "zst", virtual: true, mode: parser.ANSIStrip, expected: "[app.log] 2024-01-02T10:00:00Z ERROR failed\n[app.log] " + string(lines[1].Text) + "\n"},
+	}
+	for _, test := range tests {
+		format, err := ParseExportFormat(test.format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buffer bytes.Buffer
+		if err = ExportLines(&buffer, lines, format, test.virtual, nil, test.mode); err != nil {
+			t.Errorf("%s: unexpected error %v", test.format, err)
+			continue
+		}
+		if data := decompress(t, buffer.Bytes(), format.Compression); string(data) != test.expected {
+			t.Errorf("%s: exported %q, expected %q", test.format, data, test.expected)
+		}
+	}
+}
+
+func TestExportLinesNDJSON(t *testing.T) {
+	lines := []LogLine{
+		{File: "app.log", Number: 7, Text: []byte(`2024-01-02T10:00:00Z {"user": "bob"}`)},
+		{File: "app.log", Number: 8, Text: []byte("plain line")},
+	}
+	var buffer bytes.Buffer
+	if err := ExportLines(&buffer, lines, ExportFormat{Encoding: "ndjson", Compression: "gz"}, false, nil, parser.ANSIKeep); err != nil {
+		t.Fatal(err)
+	}
+	records := strings.Split(strings.TrimSuffix(string(decompress(t, buffer.Bytes(), "gz")), "\n"), "\n")
+	if len(records) != 2 {
+		t.Fatalf("%d records, expected 2", len(records))
+	}
+	var first, second ExportRecord
+	if err := json.Unmarshal([]byte(records[0]), &first); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(records[1]), &second); err != nil {
+		t.Fatal(err)
+	}
+	if first.Line != 7 || first.Fields["user"] != "bob" || first.Timestamp == nil || first.Timestamp.Year() != 2024 || first.Text != string(lines[0].Text) {
+		t.Errorf("unexpected record %+v", first)
+	}
+	if second.Line != 8 || second.Fields != nil || second.Timestamp != nil || second.Text != "plain line" {
+		t.Errorf("unexpected record %+v", second)
+	}
+}
+
+func TestExportLinesCSV(t *testing.T) {
+	lines := []LogLine{
+		{File: "app.log", Number: 1, Text: []byte(`{"user": {"id": 42}, "msg": "a, \"quoted\" line"}`)},
+		{File: "app.log", Number: 2, Text: []byte(`{"latency": 12.5}`)},
+	}
+	var buffer bytes.Buffer
+	if err := ExportLines(&buffer, lines, ExportFormat{Encoding: "csv"}, false, nil, parser.ANSIKeep); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&buffer).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]string{
+		{"File", "Line", "Timestamp", "latency", "msg", "user", "Text"},
+		{"app.log", "1", "", "", `a, "quoted" line`, `{"id":42}`, string(lines[0].Text)},
+		{"app.log", "2", "", "12.5", "", "", string(lines[1].Text)},
+	}
+	if len(rows) != len(expected) {
+		t.Fatalf("rows %q, expected %q", rows, expected)
+	}
+	for i := range rows {
+		if strings.Join(rows[i], "|") != strings.Join(expected[i], "|") {
+			t.Errorf("row %d = %q, expected %q", i, rows[i], expected[i])
+		}
+	}
+}
+
+func decompress(t *testing.T, data []byte, compression string) []byte {
+	t.Helper()
+	switch compression {
+	case "gz":
+		reader, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if data, err = ioutil.ReadAll(reader); err != nil {
+			t.Fatal(err)
+		}
+	case "zst":
+		var err error
+		if data, err = gozstd.Decompress(nil, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return data
+}
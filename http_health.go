@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alessiosavi/GoLog-Viewer/datastructure"
+	"github.com/alessiosavi/GoLog-Viewer/health"
+	log "github.com/sirupsen/logrus"
+	"github.com/valyala/fasthttp"
+)
+
+/* ------------- HEALTH ------------- */
+
+// minEngineTimeout is the minimum time without rounds of the core engine after that the engine is considered stalled
+const minEngineTimeout = time.Minute
+
+// engineTimeout return the time without rounds after that the core engine is considered stalled: three times the interval among the rounds
+func engineTimeout(sleep int) time.Duration {
+	if timeout := 3 * time.Duration(sleep) * time.Second; timeout > minEngineTimeout {
+		return timeout
+	}
+	return minEngineTimeout
+}
+
+// errorKind classify the error occurred reading a file
+func errorKind(err error) string {
+	switch {
+	case os.IsPermission(err):
+		return datastructure.ErrorPermission
+	case os.IsNotExist(err):
+		return datastructure.ErrorNotFound
+	}
+	return datastructure.ErrorRead
+}
+
+// FileFailed register the error occurred reading the file. The caller have to hold the lock of the file
+func FileFailed(logFile *datastructure.LogFileStruct, kind string, err error) {
+	now := time.Now()
+	if !logFile.Error.Failing {
+		logFile.Error.Since = now
+	}
+	logFile.Error = datastructure.FileError{Kind: kind, Error: err.Error(), Count: logFile.Error.Count + 1, Since: logFile.Error.Since, Last: now, Failing: true}
+}
+
+// FileRead register the successful read of the file, the failure (if any) is over. The caller have to hold the lock of the file
+func FileRead(logFile *datastructure.LogFileStruct) {
+	if logFile.Error.Failing {
+		log.Info("FileRead | File ", logFile.LogFileInfoStruct.Path, " readable again after ", time.Since(logFile.Error.Since))
+	}
+	logFile.Error.Failing = false
+}
+
+// FileStatus contains the errors of a file
+type FileStatus struct {
+	Path string `json:"Path"`
+	datastructure.FileError
+}
+
+// ServiceStatus is the summary of the state of the service returned by /debug/status
+type ServiceStatus struct {
+	health.State
+	Live       bool         `json:"Live"`
+	Ready      bool         `json:"Ready"`
+	Problems   []string     `json:"Problems"` // Reasons of the failure of the checks
+	Managed    int          `json:"Managed"`  // Number of managed files
+	Excluded   int          `json:"Excluded"` // Number of files excluded by the include/exclude rules
+	Failing    int          `json:"Failing"`  // Number of managed files that can't be read
+	Errors     []FileStatus `json:"Errors"`   // Files with at least one error since the start
+	Goroutines int          `json:"Goroutines"`
+	HeapBytes  uint64       `json:"HeapBytes"`
+}
+
+// Status verify the state of the service. It's live if the core engine is progressing, ready if it's live, the files are loaded
+// and at least one managed file can be read
+func Status(fileList []datastructure.LogFileStruct, logCfg *datastructure.Configuration, now time.Time) ServiceStatus {
+	status := ServiceStatus{State: logCfg.Health.State(), Problems: []string{}, Errors: []FileStatus{}}
+	managed := ManagedFiles(fileList)
+	status.Managed, status.Excluded = len(managed), len(fileList)-len(managed)
+	for i := range fileList {
+		state := fileList[i].State()
+		if !state.Excluded && state.Error.Failing {
+			status.Failing++
+		}
+		if state.Error.Count > 0 {
+			status.Errors = append(status.Errors, FileStatus{Path: state.Info.Path, FileError: state.Error})
+		}
+	}
+	sort.Slice(status.Errors, func(i, j int) bool { return status.Errors[i].Path < status.Errors[j].Path })
+	if timeout := engineTimeout(logCfg.Settings.Get().Sleep); status.Stalled(now, timeout) {
+		status.Problems = append(status.Problems, "core engine stalled: no round completed in the last "+timeout.String())
+	}
+	status.Live = len(status.Problems) == 0
+	if status.Loaded.IsZero() {
+		status.Problems = append(status.Problems, "loading the files ("+strconv.Itoa(status.Read)+"/"+strconv.Itoa(status.Files)+")")
+	} else if status.Managed > 0 && status.Failing == status.Managed {
+		status.Problems = append(status.Problems, "every managed file is failing to read")
+	}
+	status.Ready = len(status.Problems) == 0
+	var memory runtime.MemStats
+	runtime.ReadMemStats(&memory)
+	status.Goroutines, status.HeapBytes = runtime.NumGoroutine(), memory.HeapAlloc
+	return status
+}
+
+// HealthzHTTP is the liveness check: it fail when the core engine is stalled
+func HealthzHTTP(ctx *fasthttp.RequestCtx, fileList []datastructure.LogFileStruct, logCfg *datastructure.Configuration) {
+	log.Trace("HealthzHTTP | START")
+	status := Status(fileList, logCfg, time.Now())
+	if !status.Live {
+		WriteError(ctx, datastructure.ErrUnavailable, "not live: "+strings.Join(status.Problems, "; "))
+		log.Trace("HealthzHTTP | STOP")
+		return
+	}
+	ctx.Response.Header.SetContentType("application/json; charset=utf-8")
+	err := json.NewEncoder(ctx).Encode(datastructure.Status{Status: true, Description: "live", ErrorCode: "", Data: nil})
+	check(err)
+	log.Trace("HealthzHTTP | STOP")
+}
+
+// ReadyzHTTP is the readiness check: it fail while the files are loading, when the core engine is stalled and when every file is failing.
+// The files that are failing while the others are readable are reported in the description
+func ReadyzHTTP(ctx *fasthttp.RequestCtx, fileList []datastructure.LogFileStruct, logCfg *datastructure.Configuration) {
+	log.Trace("ReadyzHTTP | START")
+	status := Status(fileList, logCfg, time.Now())
+	if !status.Ready {
+		WriteError(ctx, datastructure.ErrUnavailable, "not ready: "+strings.Join(status.Problems, "; "))
+		log.Trace("ReadyzHTTP | STOP")
+		return
+	}
+	description := "ready"
+	if status.Failing > 0 {
+		description = "ready, " + strconv.Itoa(status.Failing) + " files failing to read (see /debug/status)"
+	}
+	ctx.Response.Header.SetContentType("application/json; charset=utf-8")
+	err := json.NewEncoder(ctx).Encode(datastructure.Status{Status: true, Description: description, ErrorCode: "", Data: nil})
+	check(err)
+	log.Trace("ReadyzHTTP | STOP")
+}
+
+// DebugStatusHTTP return the summary of the state of the service, with the errors of every file
+func DebugStatusHTTP(ctx *fasthttp.RequestCtx, fileList []datastructure.LogFileStruct, logCfg *datastructure.Configuration) {
+	log.Trace("DebugStatusHTTP | START")
+	ctx.Response.Header.SetContentType("application/json; charset=utf-8")
+	err := json.NewEncoder(ctx).Encode(datastructure.Status{Status: true, Description: "", ErrorCode: "", Data: Status(fileList, logCfg, time.Now())})
+	check(err)
+	log.Trace("DebugStatusHTTP | STOP")
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/alessiosavi/GoLog-Viewer/datastructure"
+	"github.com/alessiosavi/GoLog-Viewer/health"
+	"github.com/valyala/fasthttp"
+)
+
+func TestStatus(t *testing.T) {
+	start := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+	fileList := []datastructure.LogFileStruct{logFile("/var/log/app.log", "started"), logFile("/var/log/db.log", "started")}
+	logCfg := testConfiguration(t) // Sleep of 1 second, the engine is stalled after one minute
+	logCfg.Health = health.NewMonitor(start)
+	logCfg.Health.Loading(2)
+	logCfg.Health.FileLoaded()
+
+	tests := []struct {
+		name        string
+		update      func()
+		now         time.Time
+		live, ready bool
+		problem     string
+	}{
+		{name: "loading", now: start.Add(time.Hour), live: true, problem: "loading the files (1/2)"},
+		{name: "loaded", update: func() { logCfg.Health.FileLoaded(); logCfg.Health.Loaded(start.Add(time.Second)) }, now: start.Add(2 * time.Second), live: true, ready: true},
+		{name: "first round late", now: start.Add(2 * time.Minute), problem: "core engine stalled"},
+		{name: "round", update: func() { logCfg.Health.Round(start.Add(2 * time.Minute)) }, now: start.Add(2 * time.Minute), live: true, ready: true},
+		{name: "engine stalled", now: start.Add(4 * time.Minute), problem: "core engine stalled: no round completed in the last 1m0s"},
+		{name: "engine progressing again", update: func() { logCfg.Health.Round(start.Add(4 * time.Minute)) }, now: start.Add(4 * time.Minute), live: true, ready: true},
+		{name: "one file failing", update: func() { FileFailed(&fileList[0], datastructure.ErrorNotFound, os.ErrNotExist) }, now: start.Add(4 * time.Minute), live: true, ready: true},
+		{name: "every file failing", update: func() { FileFailed(&fileList[1], datastructure.ErrorRead, errors.New("i/o error")) }, now: start.Add(4 * time.Minute),
+			live: true, problem: "every managed file is failing to read"},
+		{name: "failing file excluded", update: func() { ExcludeLogFile(&fileList[1]) }, now: start.Add(4 * time.Minute), live: true, problem: "every managed file is failing to read"},
+		{name: "readable again", update: func() { FileRead(&fileList[0]) }, now: start.Add(4 * time.Minute), live: true, ready: true},
+	}
+	for _, test := range tests {
+		if test.update != nil {
+			test.update()
+		}
+		status := Status(fileList, logCfg, test.now)
+		problems := strings.Join(status.Problems, "; ")
+		if status.Live != test.live || status.Ready != test.ready || !strings.HasPrefix(problems, test.problem) || test.problem == "" && problems != "" {
+			t.Errorf("%s: live %v, ready %v, problems %q, expected %v, %v, %q", test.name, status.Live, status.Ready, problems, test.live, test.ready, test.problem)
+		}
+	}
+}
+
+func TestDebugStatusHTTP(t *testing.T) {
+	dir := t.TempDir()
+	logCfg := testConfiguration(t)
+	logCfg.Health = health.NewMonitor(time.Now())
+	logCfg.Health.Loaded(time.Now())
+	fileList := []datastructure.LogFileStruct{
+		logFile(filepath.Join(dir, "app.log"), "started"),
+		logFile(filepath.Join(dir, "broken.log")),
+		logFile(filepath.Join(dir, "secret.log")),
+		logFile(filepath.Join(dir, "missing.log")),
+	}
+	fileList[1].Data = []byte("not zstd")
+	InitIngestion(&fileList[1]) // The lines in memory can't be decompressed
+	FileFailed(&fileList[2], errorKind(&os.PathError{Op: "open", Path: fileList[2].LogFileInfoStruct.Path, Err: syscall.EACCES}), errors.New("permission denied"))
+	FileFailed(&fileList[2], errorKind(&os.PathError{Op: "open", Path: fileList[2].LogFileInfoStruct.Path, Err: syscall.EACCES}), errors.New("permission denied"))
+	fileList[3].Data = nil
+	ReadLogFile(&fileList[3], logCfg.Settings.Get().Limits(fileList[3].LogFileInfoStruct.Path)) // Removed
+
+	var ctx fasthttp.RequestCtx
+	DebugStatusHTTP(&ctx, fileList, logCfg)
+	var response struct {
+		Data ServiceStatus
+	}
+	if err := json.Unmarshal(ctx.Response.Body(), &response); err != nil {
+		t.Fatalf("invalid json %q: %v", ctx.Response.Body(), err)
+	}
+	status := response.Data
+	if !status.Live || !status.Ready || status.Managed != 4 || status.Failing != 3 || len(status.Errors) != 3 {
+		t.Fatalf("unexpected status %+v", status)
+	}
+	expected := []struct {
+		name, kind string
+		count      int
+	}{{"broken.log", datastructure.ErrorDecompress, 1}, {"missing.log", datastructure.ErrorNotFound, 1}, {"secret.log", datastructure.ErrorPermission, 2}}
+	for i, e := range expected {
+		if err := status.Errors[i]; filepath.Base(err.Path) != e.name || err.Kind != e.kind || err.Count != e.count || !err.Failing || err.Error == "" {
+			t.Errorf("error %d: %+v, expected %s %s (%d)", i, err, e.name, e.kind, e.count)
+		}
+	}
+
+	ctx = fasthttp.RequestCtx{}
+	ReadyzHTTP(&ctx, fileList, logCfg)
+	if ctx.Response.StatusCode() != fasthttp.StatusOK || !strings.Contains(string(ctx.Response.Body()), "3 files failing to read") {
+		t.Errorf("readyz: %d %s", ctx.Response.StatusCode(), ctx.Response.Body())
+	}
+	FileFailed(&fileList[0], datastructure.ErrorRead, errors.New("i/o error"))
+	ctx = fasthttp.RequestCtx{}
+	ReadyzHTTP(&ctx, fileList, logCfg)
+	if ctx.Response.StatusCode() != fasthttp.StatusServiceUnavailable {
+		t.Errorf("readyz: %d %s, expected not ready when every file is failing", ctx.Response.StatusCode(), ctx.Response.Body())
+	}
+	ctx = fasthttp.RequestCtx{}
+	HealthzHTTP(&ctx, fileList, logCfg)
+	if ctx.Response.StatusCode() != fasthttp.StatusOK {
+		t.Errorf("healthz: %d %s, expected live when the files are failing", ctx.Response.StatusCode(), ctx.Response.Body())
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alessiosavi/GoLog-Viewer/datastructure"
+	"github.com/alessiosavi/GoLog-Viewer/metrics"
+	"github.com/alessiosavi/GoLog-Viewer/ui"
+	log "github.com/sirupsen/logrus"
+	"github.com/valyala/fasthttp"
+)
+
+/* ------------- METRICS ------------- */
+
+// tailTimeout is the time after that a client of the live tail that does not request the lines anymore is no more a subscriber
+const tailTimeout = 10 * time.Second
+
+// Metrics of the viewer, exposed by /metrics in the Prometheus text format
+var (
+	registry = metrics.NewRegistry()
+
+	filesGauge          = metrics.NewGauge("golog_files", "Number of files found in the log folder by state (managed, excluded)", "state")
+	memoryBytesGauge    = metrics.NewGauge("golog_memory_bytes", "Size of the lines in memory of every file by encoding (compressed, raw)", "encoding")
+	fileBytesGauge      = metrics.NewGauge("golog_file_bytes", "Size of the lines in memory of the file by encoding (compressed, raw)", "file", "encoding")
+	fileLinesGauge      = metrics.NewGauge("golog_file_lines", "Number of lines in memory of the file by level", "file", "level")
+	scanDuration        = metrics.NewHistogram("golog_engine_scan_duration_seconds", "Duration of the rounds of the core engine", nil)
+	changedFiles        = metrics.NewCounter("golog_engine_changed_files_total", "Number of files found changed by the core engine")
+	roundChangedFiles   = metrics.NewGauge("golog_engine_round_changed_files", "Number of files found changed in the last round of the core engine")
+	httpRequests        = metrics.NewCounter("golog_http_requests_total", "Number of HTTP requests by path, method and status code", "path", "method", "code")
+	httpDuration        = metrics.NewHistogram("golog_http_request_duration_seconds", "Latency of the HTTP requests by path (the streamed body is excluded)", nil, "path", "method")
+	activeStreams       = metrics.NewGauge("golog_http_active_streams", "Number of streamed responses (search, export) in progress")
+	tailSubscriberGauge = metrics.NewGauge("golog_tail_subscribers", "Number of clients that follow the file with the live tail", "file")
+
+	tailSubscribers = TailSubscribers{seen: make(map[string]time.Time)}
+)
+
+func init() {
+	if err := registry.Register(filesGauge, memoryBytesGauge, fileBytesGauge, fileLinesGauge, scanDuration, changedFiles, roundChangedFiles,
+		httpRequests, httpDuration, activeStreams, tailSubscriberGauge); err != nil {
+		panic(err)
+	}
+}
+
+// TailSubscribers track the clients of the live tail. The web interface poll the lines of the file (follow=on), a client is
+// a subscriber until it stop to poll. It's safe for concurrent use
+type TailSubscribers struct {
+	mutex sync.Mutex
+	seen  map[string]time.Time // Last request of the client for the file (client + "|" + file)
+}
+
+// Touch register the request of the lines of the file from the client
+func (t *TailSubscribers) Touch(client, file string, now time.Time) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.seen[client+"|"+file] = now
+}
+
+// Active return the number of subscribers for every file, the expired ones are removed
+func (t *TailSubscribers) Active(now time.Time) map[string]int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	active := make(map[string]int)
+	for key, seen := range t.seen {
+		if now.Sub(seen) > tailTimeout {
+			delete(t.seen, key)
+			continue
+		}
+		active[key[strings.Index(key, "|")+1:]]++
+	}
+	return active
+}
+
+// ObserveRequest update the metrics of the HTTP request served, started at the given time
+func ObserveRequest(ctx *fasthttp.RequestCtx, start time.Time) {
+	path, method := metricPath(string(ctx.Path())), string(ctx.Method())
+	httpRequests.Inc(path, method, strconv.Itoa(ctx.Response.StatusCode()))
+	httpDuration.Observe(time.Since(start).Seconds(), path, method)
+	// The invalid flags are rejected by the handlers, a successful request always have a valid follow parameter
+	if follow, _, _ := ParseBoolParameter(ctx, "follow"); follow && ctx.Response.StatusCode() == fasthttp.StatusOK {
+		tailSubscribers.Touch(ctx.RemoteIP().String(), string(ctx.FormValue("file")), start)
+	}
+}
+
+// metricPath return the path used as label of the HTTP metrics: the unknown paths are grouped, in order to limit the number of series
+func metricPath(path string) string {
+	if strings.HasPrefix(path, ui.Prefix) {
+		return ui.Prefix
+	}
+	if _, ok := LegacyRoute(path); ok {
+		return path
+	}
+	for _, route := range APIRoutes {
+		if path == APIPrefix+route.Path {
+			return path
+		}
+	}
+	switch path {
+	case "/", "/benchmark", "/changeLine", "/healthz", "/readyz":
+		return path
+	}
+	return "other"
+}
+
+// MetricsHTTP return the metrics of the viewer in the Prometheus text format. The metrics of the files are computed at every request
+// and swapped at once, so the concurrent scrapes never expose a partial set of series
+func MetricsHTTP(ctx *fasthttp.RequestCtx, fileList []datastructure.LogFileStruct, logCfg *datastructure.Configuration) {
+	log.Trace("MetricsHTTP | START")
+	files, memoryBytes, fileBytes, fileLines, subscribers := filesGauge.Values(), memoryBytesGauge.Values(), fileBytesGauge.Values(), fileLinesGauge.Values(), tailSubscriberGauge.Values()
+	var compressed, raw, managed int
+	for i := range fileList {
+		state := fileList[i].State()
+		if state.Excluded {
+			continue
+		}
+		managed++
+		info := state.Info
+		compressed += len(state.Data)
+		raw += info.Bytes
+		fileBytes.Set(float64(len(state.Data)), info.Path, "compressed")
+		fileBytes.Set(float64(info.Bytes), info.Path, "raw")
+		for level, n := range info.Levels {
+			fileLines.Set(float64(n), info.Path, level)
+		}
+	}
+	files.Set(float64(managed), "managed")
+	files.Set(float64(len(fileList)-managed), "excluded")
+	memoryBytes.Set(float64(compressed), "compressed")
+	memoryBytes.Set(float64(raw), "raw")
+	for file, n := range tailSubscribers.Active(time.Now()) {
+		subscribers.Set(float64(n), file)
+	}
+	for _, swap := range []struct {
+		gauge  *metrics.Gauge
+		values *metrics.Values
+	}{{filesGauge, files}, {memoryBytesGauge, memoryBytes}, {fileBytesGauge, fileBytes}, {fileLinesGauge, fileLines}, {tailSubscriberGauge, subscribers}} {
+		swap.gauge.Replace(swap.values)
+	}
+	ctx.Response.Header.SetContentType("text/plain; version=0.0.4; charset=utf-8")
+	err := registry.WriteText(ctx)
+	check(err)
+	log.Trace("MetricsHTTP | STOP")
+}
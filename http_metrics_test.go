@@ -0,0 +1,39 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTailSubscribersActive(t *testing.T) {
+	start := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+	subscribers := TailSubscribers{seen: make(map[string]time.Time)}
+	subscribers.Touch("10.0.0.1", "/var/log/app.log", start)
+	subscribers.Touch("10.0.0.2", "/var/log/app.log", start.Add(5*time.Second))
+	subscribers.Touch("10.0.0.1", "/var/log/db.log", start.Add(5*time.Second))
+	subscribers.Touch("10.0.0.1", "/var/log/a|b.log", start.Add(5*time.Second)) // The file can contains the separator
+
+	tests := []struct {
+		now      time.Time
+		expected map[string]int
+	}{
+		{now: start.Add(tailTimeout), expected: map[string]int{"/var/log/app.log": 2, "/var/log/db.log": 1, "/var/log/a|b.log": 1}},
+		{now: start.Add(tailTimeout + time.Second), expected: map[string]int{"/var/log/app.log": 1, "/var/log/db.log": 1, "/var/log/a|b.log": 1}},
+		{now: start, expected: map[string]int{"/var/log/app.log": 1, "/var/log/db.log": 1, "/var/log/a|b.log": 1}}, // The expired ones are removed
+		{now: start.Add(time.Minute), expected: map[string]int{}},
+	}
+	for _, test := range tests {
+		if active := subscribers.Active(test.now); !reflect.DeepEqual(active, test.expected) {
+			t.Errorf("Active(%s) = %v, expected %v", test.now, active, test.expected)
+		}
+	}
+	if len(subscribers.seen) != 0 {
+		t.Errorf("%d subscribers left, expected the expired ones removed", len(subscribers.seen))
+	}
+	// A client that poll again is a subscriber again
+	subscribers.Touch("10.0.0.1", "/var/log/app.log", start.Add(time.Minute))
+	if active := subscribers.Active(start.Add(time.Minute)); active["/var/log/app.log"] != 1 {
+		t.Errorf("Active() = %v, expected the client subscribed again", active)
+	}
+}
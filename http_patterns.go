@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alessiosavi/GoLog-Viewer/datastructure"
+	"github.com/alessiosavi/GoLog-Viewer/drain"
+	"github.com/alessiosavi/GoLog-Viewer/parser"
+	"github.com/alessiosavi/GoLog-Viewer/query"
+	log "github.com/sirupsen/logrus"
+	"github.com/valyala/fasthttp"
+	"github.com/valyala/gozstd"
+)
+
+/* ------------- PATTERNS API ------------- */
+
+// PatternsResult is the response of the patterns API
+type PatternsResult struct {
+	File      string           `json:"File"`      // File (or glob) analyzed
+	Lines     int              `json:"Lines"`     // Number of lines clustered
+	Templates int              `json:"Templates"` // Number of templates found
+	Top       []*drain.Cluster `json:"Top"`       // Most frequent templates
+}
+
+// PatternsHTTP cluster the lines in memory of the file into templates and return the most frequent ones.
+// The lines can be selected using the same criteria of the filter API (filter, level, q ...)
+func PatternsHTTP(ctx *fasthttp.RequestCtx, fileList []datastructure.LogFileStruct, logCfg *datastructure.Configuration) {
+	log.Trace("PatternsHTTP | START")
+	ctx.Response.Header.SetContentType("application/json; charset=utf-8")
+	result, errorCode, err := PatternsHTTPEngine(ctx, fileList, logCfg)
+	if err != nil {
+		WriteError(ctx, errorCode, err.Error())
+		log.Warn("PatternsHTTP | ", errorCode, " | Params -> ", string(ctx.QueryArgs().QueryString()))
+		log.Trace("PatternsHTTP | STOP")
+		return
+	}
+	err = json.NewEncoder(ctx).Encode(datastructure.Status{Status: true, Description: "", ErrorCode: "", Data: result})
+	check(err)
+	log.Trace("PatternsHTTP | STOP")
+}
+
+// PatternsHTTPEngine validate the INPUT parameters and mine the templates of the selected file
+func PatternsHTTPEngine(ctx *fasthttp.RequestCtx, fileList []datastructure.LogFileStruct, logCfg *datastructure.Configuration) (PatternsResult, string, error) {
+	file := string(ctx.FormValue("file"))
+	if strings.Compare(file, "") == 0 {
+		return PatternsResult{}, datastructure.ErrMissingParameter, missingParameterError("file", "/patterns?file=file_name&top=20")
+	}
+	top, errorCode, err := ParseIntParameter(ctx, "top", 20, 1)
+	if err != nil {
+		return PatternsResult{}, errorCode, err
+	}
+	filter, errorCode, err := ParseFilterParameters(ctx, logCfg)
+	if err != nil {
+		return PatternsResult{}, errorCode, err
+	}
+	selected := SelectFiles(fileList, logCfg.VirtualFiles, file)
+	if len(selected) == 0 {
+		return PatternsResult{}, datastructure.ErrFileNotFound, errors.New("file not found: " + file)
+	}
+	miner := drain.New()
+	result := PatternsResult{File: file}
+	for _, i := range selected {
+		result.Lines += MineTemplates(miner, &fileList[i], filter, logCfg.Parser)
+	}
+	clusters := miner.Clusters()
+	result.Templates = len(clusters)
+	if len(clusters) > top {
+		clusters = clusters[:top]
+	}
+	result.Top = clusters
+	return result, "", nil
+}
+
+// MineTemplates add the lines in memory of the file that satisfy the filter to the miner. Return the number of lines added
+func MineTemplates(miner *drain.Drain, logFile *datastructure.LogFileStruct, filter query.Filter, lineParser *parser.Parser) int {
+	path := logFile.LogFileInfoStruct.Path
+	data, err := gozstd.Decompress(nil, logFile.State().Data)
+	if err != nil {
+		log.Error("MineTemplates | Unable to decompress data of ", path, " | Err: ", err)
+		return 0
+	}
+	var n int
+	for _, line := range parser.SplitLines(data) {
+		fields := lineParser.Fields(path, line)
+		if !filter.MatchParsed(line, fields) {
+			continue
+		}
+		t, _ := parser.ParseTimestamp(line, fields)
+		miner.Add(string(parser.StripANSI(line)), t)
+		n++
+	}
+	return n
+}
+
+// NovelPattern is a template observed for the first time, with the related file
+type NovelPattern struct {
+	File string `json:"File"`
+	drain.Seen
+}
+
+// NewPatternsHTTP return the templates observed for the first time after the "since" parameter (default: 10 minutes ago).
+// The templates already present during the initial load are never reported
+func NewPatternsHTTP(ctx *fasthttp.RequestCtx, fileList []datastructure.LogFileStruct, logCfg *datastructure.Configuration) {
+	log.Trace("NewPatternsHTTP | START")
+	ctx.Response.Header.SetContentType("application/json; charset=utf-8")
+	file := string(ctx.FormValue("file")) // Optional, every file if not provided
+	since := string(ctx.FormValue("since"))
+	if since == "" {
+		since = "10m"
+	}
+	t, err := ParseTimeParameter(since, time.Now())
+	if err != nil {
+		WriteError(ctx, datastructure.ErrInvalidParameter, parameterError("since", err).Error())
+		log.Trace("NewPatternsHTTP | STOP")
+		return
+	}
+	var selected []int
+	if file == "" {
+		selected = ManagedFiles(fileList)
+	} else if selected = SelectFiles(fileList, logCfg.VirtualFiles, file); len(selected) == 0 {
+		WriteError(ctx, datastructure.ErrFileNotFound, "file not found: "+file)
+		log.Trace("NewPatternsHTTP | STOP")
+		return
+	}
+	novel := []NovelPattern{}
+	for _, i := range selected {
+		templates := fileList[i].State().Templates
+		if templates == nil {
+			continue
+		}
+		for _, seen := range templates.Novel(t) {
+			novel = append(novel, NovelPattern{File: fileList[i].LogFileInfoStruct.Path, Seen: seen})
+		}
+	}
+	sort.SliceStable(novel, func(i, j int) bool { return novel[i].FirstSeen.After(novel[j].FirstSeen) })
+	err = json.NewEncoder(ctx).Encode(datastructure.Status{Status: true, Description: "Templates observed since " + t.Format(time.RFC3339), ErrorCode: "", Data: novel})
+	check(err)
+	log.Trace("NewPatternsHTTP | STOP")
+}
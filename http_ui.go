@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+
+	"github.com/alessiosavi/GoLog-Viewer/ui"
+	log "github.com/sirupsen/logrus"
+	"github.com/valyala/fasthttp"
+)
+
+/* ------------- WEB UI ------------- */
+
+// UIHTTP expose the assets of the web interface compiled into the binary. It return false if the path is not related to an asset
+func UIHTTP(ctx *fasthttp.RequestCtx) bool {
+	log.Trace("UIHTTP | START")
+	path := string(ctx.Path())
+	asset, ok := ui.Find(path)
+	if !ok {
+		log.Trace("UIHTTP | STOP")
+		return false
+	}
+	if path+"/" == ui.Prefix { // The relative path of the assets require the trailing slash
+		ctx.Redirect(ui.Prefix, fasthttp.StatusMovedPermanently)
+		log.Trace("UIHTTP | STOP")
+		return true
+	}
+	ctx.Response.Header.SetContentType(asset.ContentType)
+	_, err := ctx.WriteString(asset.Content)
+	check(err)
+	log.Trace("UIHTTP | STOP")
+	return true
+}
+
+// acceptHTML verify if the client is a browser (Accept: text/html)
+func acceptHTML(ctx *fasthttp.RequestCtx) bool {
+	return bytes.Contains(ctx.Request.Header.Peek("Accept"), []byte("text/html"))
+}
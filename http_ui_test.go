@@ -0,0 +1,91 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alessiosavi/GoLog-Viewer/config"
+	"github.com/alessiosavi/GoLog-Viewer/datastructure"
+	"github.com/alessiosavi/GoLog-Viewer/ui"
+	"github.com/valyala/fasthttp"
+)
+
+// testHandler return the handler of the service, without credentials
+func testHandler(t *testing.T, fileList []datastructure.LogFileStruct) fasthttp.RequestHandler {
+	logCfg := testConfiguration(t)
+	hostname, port := "localhost", 8080
+	logCfg.Hostname, logCfg.Port, logCfg.Auth = &hostname, &port, &config.Auth{}
+	return Handler(fileList, logCfg)
+}
+
+// serve execute the request against the handler
+func serve(handler fasthttp.RequestHandler, uri string, headers ...string) *fasthttp.RequestCtx {
+	var ctx fasthttp.RequestCtx
+	ctx.Request.SetRequestURI(uri)
+	for i := 0; i+1 < len(headers); i += 2 {
+		ctx.Request.Header.Set(headers[i], headers[i+1])
+	}
+	handler(&ctx)
+	return &ctx
+}
+
+func TestUIHTTP(t *testing.T) {
+	handler := testHandler(t, nil)
+	tests := []struct {
+		path        string
+		status      int
+		contentType string
+		contains    string
+	}{
+		{path: ui.Prefix, status: fasthttp.StatusOK, contentType: "text/html; charset=utf-8", contains: "<title>GoLog Viewer</title>"},
+		{path: ui.Prefix + "index.html", status: fasthttp.StatusOK, contentType: "text/html; charset=utf-8", contains: `<script src="app.js"></script>`},
+		{path: ui.Prefix + "app.css", status: fasthttp.StatusOK, contentType: "text/css; charset=utf-8", contains: "box-sizing"},
+		{path: ui.Prefix + "app.js", status: fasthttp.StatusOK, contentType: "application/javascript; charset=utf-8", contains: `"use strict"`},
+		{path: ui.Prefix + "missing.js", status: fasthttp.StatusNotFound, contains: "does not exist"},
+		{path: ui.Prefix + "app.js/", status: fasthttp.StatusNotFound, contains: "does not exist"},
+		{path: ui.Prefix + "../app.js", status: fasthttp.StatusNotFound, contains: "does not exist"},
+		{path: "/app.js", status: fasthttp.StatusNotFound, contains: "does not exist"},
+	}
+	for _, test := range tests {
+		ctx := serve(handler, test.path)
+		if ctx.Response.StatusCode() != test.status {
+			t.Errorf("%s: status %d, expected %d", test.path, ctx.Response.StatusCode(), test.status)
+		}
+		if test.contentType != "" && string(ctx.Response.Header.ContentType()) != test.contentType {
+			t.Errorf("%s: content type %q, expected %q", test.path, ctx.Response.Header.ContentType(), test.contentType)
+		}
+		if !strings.Contains(string(ctx.Response.Body()), test.contains) {
+			t.Errorf("%s: body does not contain %q", test.path, test.contains)
+		}
+	}
+	for _, path := range []string{ui.Prefix, ui.Prefix + "app.js"} { // The assets are served as they are compiled into the binary
+		asset, _ := ui.Find(path)
+		if body := string(serve(handler, path).Response.Body()); body != asset.Content {
+			t.Errorf("%s: body differ from the embedded asset", path)
+		}
+	}
+}
+
+func TestUIHTTPRedirect(t *testing.T) {
+	handler := testHandler(t, nil)
+	tests := []struct {
+		path     string
+		accept   string
+		status   int
+		location string
+	}{
+		{path: "/ui", status: fasthttp.StatusMovedPermanently, location: ui.Prefix}, // The relative paths of the assets require the trailing slash
+		{path: "/", accept: "text/html,application/xhtml+xml", status: fasthttp.StatusFound, location: ui.Prefix},
+		{path: "/?plain=on", accept: "text/html", status: fasthttp.StatusOK},
+		{path: "/", status: fasthttp.StatusOK},
+	}
+	for _, test := range tests {
+		ctx := serve(handler, test.path, "Accept", test.accept)
+		if ctx.Response.StatusCode() != test.status {
+			t.Errorf("%s (%s): status %d, expected %d", test.path, test.accept, ctx.Response.StatusCode(), test.status)
+		}
+		if location := string(ctx.Response.Header.Peek("Location")); !strings.HasSuffix(location, test.location) || test.location == "" && location != "" {
+			t.Errorf("%s (%s): location %q, expected %q", test.path, test.accept, location, test.location)
+		}
+	}
+}
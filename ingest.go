@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"time"
+
+	"github.com/alessiosavi/GoLog-Viewer/datastructure"
+	"github.com/alessiosavi/GoLog-Viewer/drain"
+	"github.com/alessiosavi/GoLog-Viewer/parser"
+	log "github.com/sirupsen/logrus"
+	"github.com/valyala/gozstd"
+)
+
+/* ------------- INGESTION ------------- */
+
+// maxIngestBytes is the max number of new bytes read from a file in a single round, the older bytes are skipped
+const maxIngestBytes = 16 << 20
+
+// InitIngestion save the current size of the file as starting point for the new lines and use the lines in memory as baseline of the templates
+func InitIngestion(logFile *datastructure.LogFileStruct) {
+	logFile.Templates = drain.NewHistory()
+	SeekEnd(logFile)
+	if logFile.Data == nil {
+		return
+	}
+	data, err := gozstd.Decompress(nil, logFile.Data)
+	if err != nil {
+		log.Error("InitIngestion | Unable to decompress data of ", logFile.LogFileInfoStruct.Path, " | Err: ", err)
+		FileFailed(logFile, datastructure.ErrorDecompress, err)
+		return
+	}
+	now := time.Now()
+	for _, line := range parser.SplitLines(data) {
+		logFile.Templates.Observe(string(parser.StripANSI(line)), now, true)
+	}
+}
+
+// SeekEnd move the starting point for the new lines to the end of the file, the current lines are not ingested
+func SeekEnd(logFile *datastructure.LogFileStruct) {
+	if info, err := os.Stat(logFile.LogFileInfoStruct.Path); err == nil {
+		logFile.LogFileInfoStruct.Offset = info.Size()
+	}
+}
+
+// ReadNewLines read the complete lines appended to the file since the last call, updating the offset.
+// If the file is shrunk (truncated or rotated) the lines are read from the start of the file
+func ReadNewLines(logFile *datastructure.LogFileStruct) [][]byte {
+	path := logFile.LogFileInfoStruct.Path
+	f, err := os.Open(path)
+	if err != nil {
+		log.Warn("ReadNewLines | Unable to open ", path, " | Err: ", err)
+		FileFailed(logFile, errorKind(err), err)
+		return nil
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		log.Warn("ReadNewLines | Unable to stat ", path, " | Err: ", err)
+		FileFailed(logFile, errorKind(err), err)
+		return nil
+	}
+	offset := logFile.LogFileInfoStruct.Offset
+	if info.Size() < offset { // File truncated/rotated
+		log.Info("ReadNewLines | File ", path, " shrunk from ", offset, " to ", info.Size(), " bytes, reading from the start")
+		offset = 0
+	}
+	if info.Size()-offset > maxIngestBytes {
+		log.Warn("ReadNewLines | Skipping ", info.Size()-offset-maxIngestBytes, " bytes of ", path)
+		offset = info.Size() - maxIngestBytes
+	}
+	if info.Size() == offset {
+		logFile.LogFileInfoStruct.Offset = offset
+		return nil
+	}
+	data := make([]byte, info.Size()-offset)
+	n, err := f.ReadAt(data, offset)
+	if err != nil && err != io.EOF {
+		log.Warn("ReadNewLines | Unable to read ", path, " | Err: ", err)
+		FileFailed(logFile, errorKind(err), err)
+		return nil
+	}
+	data = data[:n]
+	last := bytes.LastIndexByte(data, '\n')
+	if last < 0 { // The line is not yet complete, wait the next round
+		logFile.LogFileInfoStruct.Offset = offset
+		return nil
+	}
+	logFile.LogFileInfoStruct.Offset = offset + int64(last) + 1
+	return parser.SplitLines(data[:last+1])
+}
+
+// CatchUp read the lines appended to the file while the service was stopped (after the offset restored from the snapshot).
+// The lines are a baseline like the ones loaded at startup: they are not evaluated by the alert and metrics rules and their templates
+// are never reported as new. The number of lines read is returned
+func CatchUp(logFile *datastructure.LogFileStruct, now time.Time) int {
+	lines := ReadNewLines(logFile)
+	for _, line := range lines {
+		logFile.Templates.Observe(string(parser.StripANSI(line)), now, true)
+	}
+	return len(lines)
+}
+
+// IngestNewLines read the lines appended to the file and dispatch them to the consumers (templates history, alert rules, metrics rules)
+func IngestNewLines(logFile *datastructure.LogFileStruct, logCfg *datastructure.Configuration, now time.Time) {
+	logFile.Templates.Expire(now) // The templates not observed for a long time are forgotten, in order to bound the memory
+	lines := ReadNewLines(logFile)
+	if len(lines) == 0 {
+		return
+	}
+	log.Debug("IngestNewLines | ", len(lines), " new lines for ", logFile.LogFileInfoStruct.Path)
+	for _, line := range lines {
+		logFile.Templates.Observe(string(parser.StripANSI(line)), now, false)
+	}
+	logCfg.AlertEngine.Observe(logFile.LogFileInfoStruct.Path, lines, now)
+	logCfg.LogMetrics.Observe(logFile.LogFileInfoStruct.Path, lines)
+}
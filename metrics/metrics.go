@@ -0,0 +1,342 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are the upper bounds of the buckets of the histograms (seconds), the same of the Prometheus client
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+var (
+	metricName = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+	labelName  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// ValidName verify the name of a metric
+func ValidName(name string) bool {
+	return metricName.MatchString(name)
+}
+
+// ValidLabel verify the name of a label
+func ValidLabel(name string) bool {
+	return labelName.MatchString(name) && !strings.HasPrefix(name, "__") && name != "le"
+}
+
+// Metric is a family of series exposed by the registry
+type Metric interface {
+	Name() string
+	write(buffer *bytes.Buffer)
+}
+
+// Registry contains the metrics exposed in the Prometheus text format. It's safe for concurrent use
+type Registry struct {
+	mutex   sync.Mutex
+	metrics []Metric
+}
+
+// NewRegistry initialize an empty registry
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register add the metrics to the registry, the names have to be unique. On error none of the metrics is added
+func (r *Registry) Register(metrics ...Metric) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	names := make(map[string]bool)
+	for _, registered := range r.metrics {
+		names[registered.Name()] = true
+	}
+	for _, metric := range metrics {
+		if names[metric.Name()] {
+			return errors.New("metric " + metric.Name() + " already registered")
+		}
+		names[metric.Name()] = true
+	}
+	r.metrics = append(r.metrics, metrics...)
+	return nil
+}
+
+// Unregister remove the metric related to the name
+func (r *Registry) Unregister(name string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for i, metric := range r.metrics {
+		if metric.Name() == name {
+			r.metrics = append(r.metrics[:i], r.metrics[i+1:]...)
+			return
+		}
+	}
+}
+
+// WriteText write every metric in the Prometheus text format (version 0.0.4)
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mutex.Lock()
+	metrics := append([]Metric(nil), r.metrics...)
+	r.mutex.Unlock()
+	var buffer bytes.Buffer
+	for _, metric := range metrics {
+		metric.write(&buffer)
+	}
+	_, err := w.Write(buffer.Bytes())
+	return err
+}
+
+type series struct {
+	labels  []string
+	value   float64
+	buckets []uint64 // Histogram only: number of observations for every bucket (not cumulative)
+	count   uint64
+}
+
+// vector contains the series of a metric, one for every combination of the label values
+type vector struct {
+	mutex  sync.Mutex
+	name   string
+	help   string
+	kind   string
+	labels []string
+	series map[string]*series
+}
+
+func newVector(name, help, kind string, labels []string) vector {
+	if !ValidName(name) {
+		panic("metrics: invalid metric name " + name)
+	}
+	for _, label := range labels {
+		if !ValidLabel(label) {
+			panic("metrics: invalid label name " + label + " for " + name)
+		}
+	}
+	return vector{name: name, help: help, kind: kind, labels: labels, series: make(map[string]*series)}
+}
+
+// Name return the name of the metric
+func (v *vector) Name() string {
+	return v.name
+}
+
+// get return the series related to the label values, it have to be called with the lock held
+func (v *vector) get(values []string) *series {
+	if len(values) != len(v.labels) {
+		panic("metrics: " + v.name + " require " + strconv.Itoa(len(v.labels)) + " label values")
+	}
+	key := strings.Join(values, "\xff")
+	s, ok := v.series[key]
+	if !ok {
+		s = &series{labels: append([]string(nil), values...)}
+		v.series[key] = s
+	}
+	return s
+}
+
+// Reset remove every series
+func (v *vector) Reset() {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+	v.series = make(map[string]*series)
+}
+
+// sorted return the series ordered by label values, it have to be called with the lock held
+func (v *vector) sorted() []*series {
+	list := make([]*series, 0, len(v.series))
+	for _, s := range v.series {
+		list = append(list, s)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return strings.Join(list[i].labels, "\xff") < strings.Join(list[j].labels, "\xff")
+	})
+	return list
+}
+
+func (v *vector) header(buffer *bytes.Buffer) {
+	if v.help != "" {
+		buffer.WriteString("# HELP " + v.name + " " + strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(v.help) + "\n")
+	}
+	buffer.WriteString("# TYPE " + v.name + " " + v.kind + "\n")
+}
+
+// sample write a line of the series with the given suffix (_bucket, _sum) and the extra label (le)
+func (v *vector) sample(buffer *bytes.Buffer, suffix string, names, values []string, value float64) {
+	buffer.WriteString(v.name + suffix)
+	if len(names) > 0 {
+		buffer.WriteByte('{')
+		for i, name := range names {
+			if i > 0 {
+				buffer.WriteByte(',')
+			}
+			buffer.WriteString(name + `="` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(values[i]) + `"`)
+		}
+		buffer.WriteByte('}')
+	}
+	buffer.WriteString(" " + formatFloat(value) + "\n")
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// Counter is a value that only increase
+type Counter struct {
+	vector
+}
+
+// NewCounter initialize a counter with the given labels. It panics if the names are not valid
+func NewCounter(name, help string, labels ...string) *Counter {
+	return &Counter{newVector(name, help, "counter", labels)}
+}
+
+// Add increase the series related to the label values, the negative values are ignored
+func (c *Counter) Add(value float64, labels ...string) {
+	if value < 0 {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.get(labels).value += value
+}
+
+// Inc increase the series related to the label values by one
+func (c *Counter) Inc(labels ...string) {
+	c.Add(1, labels...)
+}
+
+func (c *Counter) write(buffer *bytes.Buffer) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.header(buffer)
+	for _, s := range c.sorted() {
+		c.sample(buffer, "", c.labels, s.labels, s.value)
+	}
+}
+
+// Gauge is a value that can increase and decrease
+type Gauge struct {
+	vector
+}
+
+// NewGauge initialize a gauge with the given labels. It panics if the names are not valid
+func NewGauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{newVector(name, help, "gauge", labels)}
+}
+
+// Set replace the value of the series related to the label values
+func (g *Gauge) Set(value float64, labels ...string) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.get(labels).value = value
+}
+
+// Add change the value of the series related to the label values
+func (g *Gauge) Add(value float64, labels ...string) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.get(labels).value += value
+}
+
+// Values return an empty set of series with the labels of the gauge, populated apart and then swapped with Replace
+func (g *Gauge) Values() *Values {
+	return &Values{vector: vector{name: g.name, labels: g.labels, series: make(map[string]*series)}}
+}
+
+// Replace swap every series of the gauge with the given ones at once, the scrapes never see a partial update
+func (g *Gauge) Replace(values *Values) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.series = values.series
+}
+
+// Values is a set of series of a gauge built apart from it (see Gauge.Values). It's not safe for concurrent use
+type Values struct {
+	vector
+}
+
+// Set replace the value of the series related to the label values
+func (v *Values) Set(value float64, labels ...string) {
+	v.get(labels).value = value
+}
+
+func (g *Gauge) write(buffer *bytes.Buffer) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.header(buffer)
+	for _, s := range g.sorted() {
+		g.sample(buffer, "", g.labels, s.labels, s.value)
+	}
+}
+
+// Histogram count the observations in buckets
+type Histogram struct {
+	vector
+	bounds []float64 // Upper bound of the buckets, sorted
+}
+
+// NewHistogram initialize an histogram with the given buckets (DefaultBuckets if empty) and labels. It panics if the names are not valid.
+// The +Inf bucket is always exposed
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	var bounds []float64
+	for _, bound := range buckets {
+		if !math.IsInf(bound, 1) {
+			bounds = append(bounds, bound)
+		}
+	}
+	sort.Float64s(bounds)
+	return &Histogram{vector: newVector(name, help, "histogram", labels), bounds: bounds}
+}
+
+// Observe add the value to the series related to the label values
+func (h *Histogram) Observe(value float64, labels ...string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	s := h.get(labels)
+	if s.buckets == nil {
+		s.buckets = make([]uint64, len(h.bounds))
+	}
+	for i, bound := range h.bounds {
+		if value <= bound {
+			s.buckets[i]++
+			break
+		}
+	}
+	s.value += value
+	s.count++
+}
+
+func (h *Histogram) write(buffer *bytes.Buffer) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.header(buffer)
+	names := append(append([]string(nil), h.labels...), "le")
+	for _, s := range h.sorted() {
+		var cumulative uint64
+		values := append(append([]string(nil), s.labels...), "")
+		for i, bound := range h.bounds {
+			cumulative += s.buckets[i]
+			values[len(values)-1] = formatFloat(bound)
+			h.sample(buffer, "_bucket", names, values, float64(cumulative))
+		}
+		values[len(values)-1] = "+Inf"
+		h.sample(buffer, "_bucket", names, values, float64(s.count))
+		h.sample(buffer, "_sum", h.labels, s.labels, s.value)
+		h.sample(buffer, "_count", h.labels, s.labels, float64(s.count))
+	}
+}
@@ -0,0 +1,173 @@
+package metrics
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+)
+
+// text return the metrics in the Prometheus text format
+func text(t *testing.T, metrics ...Metric) string {
+	registry := NewRegistry()
+	if err := registry.Register(metrics...); err != nil {
+		t.Fatal(err)
+	}
+	var buffer bytes.Buffer
+	if err := registry.WriteText(&buffer); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.String()
+}
+
+func TestCounter(t *testing.T) {
+	counter := NewCounter("requests_total", "Number of requests\nby code, path \\ method", "code", "path")
+	counter.Inc("200", "/")
+	counter.Add(2.5, "200", "/")
+	counter.Add(-1, "200", "/") // Ignored
+	counter.Inc("500", `/a "quoted" \ path`+"\nnext")
+	counter.Inc("404", "/missing")
+	expected := `# HELP requests_total Number of requests\nby code, path \\ method
+# TYPE requests_total counter
+requests_total{code="200",path="/"} 3.5
+requests_total{code="404",path="/missing"} 1
+requests_total{code="500",path="/a \"quoted\" \\ path\nnext"} 1
+`
+	if output := text(t, counter); output != expected {
+		t.Errorf("unexpected output:\n%s\nexpected:\n%s", output, expected)
+	}
+	counter.Reset()
+	if output := text(t, counter); output != "# HELP requests_total Number of requests\\nby code, path \\\\ method\n# TYPE requests_total counter\n" {
+		t.Errorf("unexpected output after the reset:\n%s", output)
+	}
+}
+
+func TestGauge(t *testing.T) {
+	gauge := NewGauge("files", "", "state")
+	gauge.Set(3, "managed")
+	gauge.Add(-1, "managed")
+	gauge.Set(math.Inf(1), "excluded")
+	unlabelled := NewGauge("ratio", "Ratio")
+	unlabelled.Set(math.NaN())
+	negative := NewGauge("offset", "")
+	negative.Set(math.Inf(-1))
+	expected := `# TYPE files gauge
+files{state="excluded"} +Inf
+files{state="managed"} 2
+# HELP ratio Ratio
+# TYPE ratio gauge
+ratio NaN
+# TYPE offset gauge
+offset -Inf
+`
+	if output := text(t, gauge, unlabelled, negative); output != expected {
+		t.Errorf("unexpected output:\n%s\nexpected:\n%s", output, expected)
+	}
+}
+
+func TestGaugeReplace(t *testing.T) {
+	gauge := NewGauge("file_lines", "", "file", "level")
+	gauge.Set(10, "/var/log/old.log", "info")
+	values := gauge.Values()
+	values.Set(1, "/var/log/app.log", "error")
+	values.Set(5, "/var/log/app.log", "info")
+	values.Set(7, "/var/log/app.log", "info")
+	if output := text(t, gauge); !strings.Contains(output, "old.log") || strings.Contains(output, "app.log") {
+		t.Errorf("the values have to be exposed only after the replace:\n%s", output)
+	}
+	gauge.Replace(values)
+	expected := `# TYPE file_lines gauge
+file_lines{file="/var/log/app.log",level="error"} 1
+file_lines{file="/var/log/app.log",level="info"} 7
+`
+	if output := text(t, gauge); output != expected {
+		t.Errorf("unexpected output:\n%s\nexpected:\n%s", output, expected)
+	}
+	gauge.Replace(gauge.Values())
+	if output := text(t, gauge); output != "# TYPE file_lines gauge\n" {
+		t.Errorf("unexpected output after an empty replace:\n%s", output)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	histogram := NewHistogram("latency_seconds", "Latency", []float64{1, 0.1, math.Inf(1), 0.5}, "path")
+	for _, value := range []float64{0.05, 0.1, 0.3, 0.7, 2, 30} {
+		histogram.Observe(value, "/a")
+	}
+	histogram.Observe(0.2, "/b")
+	expected := `# HELP latency_seconds Latency
+# TYPE latency_seconds histogram
+latency_seconds_bucket{path="/a",le="0.1"} 2
+latency_seconds_bucket{path="/a",le="0.5"} 3
+latency_seconds_bucket{path="/a",le="1"} 4
+latency_seconds_bucket{path="/a",le="+Inf"} 6
+latency_seconds_sum{path="/a"} 33.15
+latency_seconds_count{path="/a"} 6
+latency_seconds_bucket{path="/b",le="0.1"} 0
+latency_seconds_bucket{path="/b",le="0.5"} 1
+latency_seconds_bucket{path="/b",le="1"} 1
+latency_seconds_bucket{path="/b",le="+Inf"} 1
+latency_seconds_sum{path="/b"} 0.2
+latency_seconds_count{path="/b"} 1
+`
+	if output := text(t, histogram); output != expected {
+		t.Errorf("unexpected output:\n%s\nexpected:\n%s", output, expected)
+	}
+
+	defaults := NewHistogram("scan_seconds", "", nil)
+	defaults.Observe(0.003)
+	defaults.Observe(12)
+	output := text(t, defaults)
+	for _, line := range []string{`scan_seconds_bucket{le="0.005"} 1`, `scan_seconds_bucket{le="10"} 1`, `scan_seconds_bucket{le="+Inf"} 2`, "scan_seconds_sum 12.003", "scan_seconds_count 2"} {
+		if !strings.Contains(output, line+"\n") {
+			t.Errorf("line %q not found in:\n%s", line, output)
+		}
+	}
+	if n := strings.Count(output, "_bucket"); n != len(DefaultBuckets)+1 {
+		t.Errorf("%d buckets, expected %d", n, len(DefaultBuckets)+1)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	registry := NewRegistry()
+	first, second := NewCounter("a_total", ""), NewGauge("b", "")
+	if err := registry.Register(first, second); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.Register(NewGauge("c", ""), NewCounter("a_total", "")); err == nil {
+		t.Error("expected an error for the duplicate name")
+	}
+	if err := registry.Register(NewGauge("d", ""), NewGauge("d", "")); err == nil {
+		t.Error("expected an error for the duplicate name in the same call")
+	}
+	var buffer bytes.Buffer
+	if err := registry.WriteText(&buffer); err != nil || buffer.String() != "# TYPE a_total counter\n# TYPE b gauge\n" {
+		t.Errorf("WriteText() = %q (%v), none of the metrics of the failed registrations have to be added", buffer.String(), err)
+	}
+	registry.Unregister("a_total")
+	registry.Unregister("missing")
+	if err := registry.Register(NewGauge("a_total", "")); err != nil {
+		t.Errorf("unexpected error %v after the unregister", err)
+	}
+}
+
+func TestValidName(t *testing.T) {
+	tests := []struct {
+		name          string
+		metric, label bool
+	}{
+		{name: "http_requests_total", metric: true, label: true},
+		{name: "job:rate5m", metric: true},
+		{name: "_private", metric: true, label: true},
+		{name: "__reserved", metric: true},
+		{name: "le", metric: true},
+		{name: "5xx"},
+		{name: "a-b"},
+		{name: ""},
+	}
+	for _, test := range tests {
+		if ValidName(test.name) != test.metric || ValidLabel(test.name) != test.label {
+			t.Errorf("%q: ValidName %v, ValidLabel %v, expected %v, %v", test.name, ValidName(test.name), ValidLabel(test.name), test.metric, test.label)
+		}
+	}
+}
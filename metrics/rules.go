@@ -0,0 +1,163 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/alessiosavi/GoLog-Viewer/parser"
+	"github.com/alessiosavi/GoLog-Viewer/query"
+)
+
+// ReservedPrefix is the prefix of the metrics of the viewer, it can't be used by the rules
+const ReservedPrefix = "golog_"
+
+// Types of the metrics derived from the lines
+const (
+	TypeCounter   = "counter"   // Count the matching lines (or sum the value of the field)
+	TypeHistogram = "histogram" // Observe the value of the field of the matching lines
+)
+
+// Rule define a metric derived from the content of the new lines of the files. The series are labelled by file
+type Rule struct {
+	Name       string    `json:"Name"`       // Name of the metric (lines_errors_total)
+	Help       string    `json:"Help"`       // Description of the metric
+	Type       string    `json:"Type"`       // counter or histogram
+	Files      string    `json:"Files"`      // Comma separated list of glob of the files (every file if empty)
+	Filter     string    `json:"Filter"`     // Text that the line have to contains
+	IgnoreCase bool      `json:"IgnoreCase"` // Search the text ignoring the case
+	Level      string    `json:"Level"`      // Level filter (error, warn+)
+	Query      string    `json:"Query"`      // Query on the fields of the line
+	Field      string    `json:"Field"`      // Numeric field observed by the histogram, summed by the counter (optional for the counter)
+	Buckets    []float64 `json:"Buckets"`    // Upper bounds of the buckets of the histogram (DefaultBuckets if empty)
+}
+
+// Validate verify the rule
+func (r Rule) Validate() error {
+	if !ValidName(r.Name) {
+		return fmt.Errorf("metric %q: invalid name", r.Name)
+	}
+	if strings.HasPrefix(r.Name, ReservedPrefix) {
+		return fmt.Errorf("metric %s: the prefix %s is reserved", r.Name, ReservedPrefix)
+	}
+	switch r.Type {
+	case TypeCounter:
+	case TypeHistogram:
+		if r.Field == "" {
+			return fmt.Errorf("metric %s: Field is mandatory for the histogram", r.Name)
+		}
+	default:
+		return fmt.Errorf("metric %s: Type have to be %s or %s", r.Name, TypeCounter, TypeHistogram)
+	}
+	for _, glob := range strings.Split(r.Files, ",") {
+		if _, err := filepath.Match(strings.TrimSpace(glob), ""); err != nil {
+			return fmt.Errorf("metric %s: invalid glob %q", r.Name, glob)
+		}
+	}
+	if _, err := parser.ParseLevelFilter(r.Level); err != nil {
+		return fmt.Errorf("metric %s: %s", r.Name, err)
+	}
+	if _, err := query.Parse(r.Query); err != nil {
+		return fmt.Errorf("metric %s: invalid query: %s", r.Name, err)
+	}
+	return nil
+}
+
+// matchFile verify if the file is watched by the rule
+func (r Rule) matchFile(path string) bool {
+	if strings.TrimSpace(r.Files) == "" {
+		return true
+	}
+	name := filepath.Base(path)
+	for _, glob := range strings.Split(r.Files, ",") {
+		glob = strings.TrimSpace(glob)
+		if glob == path || glob == name {
+			return true
+		}
+		if ok, _ := filepath.Match(glob, path); ok {
+			return true
+		}
+		if ok, _ := filepath.Match(glob, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+type compiledRule struct {
+	rule      Rule
+	filter    query.Filter
+	counter   *Counter
+	histogram *Histogram
+}
+
+// Engine update the metrics of the rules with the new lines of the files. It's safe for concurrent use
+type Engine struct {
+	rules  []compiledRule
+	parser *parser.Parser
+}
+
+// NewEngine validate the rules and register the related metrics in the registry
+func NewEngine(rules []Rule, lineParser *parser.Parser, registry *Registry) (*Engine, error) {
+	e := &Engine{parser: lineParser}
+	for _, rule := range rules {
+		if err := rule.Validate(); err != nil {
+			return nil, err
+		}
+		compiled := compiledRule{rule: rule, filter: query.Filter{Text: rule.Filter, IgnoreCase: rule.IgnoreCase, Parser: lineParser}}
+		compiled.filter.Level, _ = parser.ParseLevelFilter(rule.Level)
+		compiled.filter.Query, _ = query.Parse(rule.Query)
+		var metric Metric
+		if rule.Type == TypeHistogram {
+			compiled.histogram = NewHistogram(rule.Name, rule.Help, rule.Buckets, "file")
+			metric = compiled.histogram
+		} else {
+			compiled.counter = NewCounter(rule.Name, rule.Help, "file")
+			metric = compiled.counter
+		}
+		if err := registry.Register(metric); err != nil {
+			return nil, errors.New("duplicated " + err.Error())
+		}
+		e.rules = append(e.rules, compiled)
+	}
+	return e, nil
+}
+
+// Observe update the metrics of the rules watching the file with the new lines. The fields of a line are extracted only once
+func (e *Engine) Observe(path string, lines [][]byte) {
+	if e == nil {
+		return
+	}
+	var rules []compiledRule
+	needFields := false
+	for _, r := range e.rules {
+		if r.rule.matchFile(path) {
+			rules = append(rules, r)
+			needFields = needFields || r.rule.Field != "" || !r.filter.Query.IsEmpty()
+		}
+	}
+	if len(rules) == 0 {
+		return
+	}
+	for _, line := range lines {
+		var fields map[string]interface{}
+		if needFields {
+			fields = e.parser.Fields(path, line)
+		}
+		for _, r := range rules {
+			if !r.filter.MatchParsed(line, fields) {
+				continue
+			}
+			value, ok := parser.LookupNumber(fields, r.rule.Field)
+			switch {
+			case r.histogram != nil && ok:
+				r.histogram.Observe(value, path)
+			case r.counter != nil && r.rule.Field == "":
+				r.counter.Inc(path)
+			case r.counter != nil && ok:
+				r.counter.Add(value, path)
+			}
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEngine(t *testing.T) {
+	registry := NewRegistry()
+	e, err := NewEngine([]Rule{
+		{Name: "lines_errors_total", Type: TypeCounter, Level: "error"},
+		{Name: "response_bytes_total", Type: TypeCounter, Field: "bytes", Files: "*.log"},
+		{Name: "post_latency_ms", Type: TypeHistogram, Field: "latency_ms", Query: "method=POST", Buckets: []float64{500, 100}, Files: "/var/log/nginx/*"},
+		{Name: "timeouts_total", Type: TypeCounter, Filter: "TIMEOUT", IgnoreCase: true, Files: "db.log, other.log"},
+	}, nil, registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.Observe("/var/log/app.log", [][]byte{
+		[]byte(`{"level": "error", "msg": "failed", "bytes": 10}`),
+		[]byte(`{"level": "info", "bytes": "32.5"}`),
+		[]byte(`{"level": "info", "bytes": "n/a"}`), // Not numeric, not counted
+		[]byte(`{"level": "info", "bytes": true}`),
+		[]byte(`ERROR plain text line`),
+	})
+	e.Observe("/var/log/nginx/access", [][]byte{
+		[]byte(`{"method": "POST", "latency_ms": 80, "bytes": 1000}`), // Not a .log file, not summed
+		[]byte(`{"method": "POST", "latency_ms": "250"}`),
+		[]byte(`{"method": "POST", "latency_ms": 2000}`),
+		[]byte(`{"method": "POST", "latency_ms": "slow"}`), // Not numeric, not observed
+		[]byte(`{"method": "POST"}`),
+		[]byte(`{"method": "GET", "latency_ms": 10}`), // Filtered by the query
+	})
+	e.Observe("/srv/db.log", [][]byte{[]byte("query timeout after 5s"), []byte("query done"), []byte(`{"level": "error", "bytes": 1}`)})
+	e.Observe("/srv/ignored.txt", [][]byte{[]byte("ERROR Timeout")})
+
+	expected := `# TYPE lines_errors_total counter
+lines_errors_total{file="/srv/db.log"} 1
+lines_errors_total{file="/srv/ignored.txt"} 1
+lines_errors_total{file="/var/log/app.log"} 2
+# TYPE response_bytes_total counter
+response_bytes_total{file="/srv/db.log"} 1
+response_bytes_total{file="/var/log/app.log"} 42.5
+# TYPE post_latency_ms histogram
+post_latency_ms_bucket{file="/var/log/nginx/access",le="100"} 1
+post_latency_ms_bucket{file="/var/log/nginx/access",le="500"} 2
+post_latency_ms_bucket{file="/var/log/nginx/access",le="+Inf"} 3
+post_latency_ms_sum{file="/var/log/nginx/access"} 2330
+post_latency_ms_count{file="/var/log/nginx/access"} 3
+# TYPE timeouts_total counter
+timeouts_total{file="/srv/db.log"} 1
+`
+	if output := text(t, registry.metrics...); output != expected {
+		t.Errorf("unexpected output:\n%s\nexpected:\n%s", output, expected)
+	}
+
+	var none *Engine
+	none.Observe("/var/log/app.log", [][]byte{[]byte("ERROR")}) // No rules configured
+}
+
+func TestNewEngineInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   []Rule
+		invalid string
+	}{
+		{name: "name", rules: []Rule{{Name: "5xx", Type: TypeCounter}}, invalid: "invalid name"},
+		{name: "reserved", rules: []Rule{{Name: "golog_lines", Type: TypeCounter}}, invalid: "reserved"},
+		{name: "type", rules: []Rule{{Name: "lines", Type: "gauge"}}, invalid: "Type have to be"},
+		{name: "histogram without field", rules: []Rule{{Name: "latency", Type: TypeHistogram}}, invalid: "Field is mandatory"},
+		{name: "glob", rules: []Rule{{Name: "lines", Type: TypeCounter, Files: "*.log,["}}, invalid: "invalid glob"},
+		{name: "level", rules: []Rule{{Name: "lines", Type: TypeCounter, Level: "loud"}}, invalid: "lines"},
+		{name: "query", rules: []Rule{{Name: "lines", Type: TypeCounter, Query: "(level=error"}}, invalid: "invalid query"},
+		{name: "duplicated", rules: []Rule{{Name: "lines", Type: TypeCounter}, {Name: "lines", Type: TypeHistogram, Field: "x"}}, invalid: "duplicated metric lines"},
+	}
+	for _, test := range tests {
+		_, err := NewEngine(test.rules, nil, NewRegistry())
+		if err == nil || !strings.Contains(err.Error(), test.invalid) {
+			t.Errorf("%s: NewEngine() error = %v, expected %q", test.name, err, test.invalid)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/alessiosavi/GoLog-Viewer/datastructure"
+	"github.com/valyala/fasthttp"
+)
+
+/* ------------- INPUT PARAMETERS ------------- */
+
+// ParseBoolParameter parse a flag received as INPUT parameter: on, true, yes, 1 enable it, off, false, no, 0 (or missing) disable it.
+// In case of error, the error code related to the invalid parameter is returned
+func ParseBoolParameter(ctx *fasthttp.RequestCtx, name string) (bool, string, error) {
+	switch strings.ToLower(strings.TrimSpace(string(ctx.FormValue(name)))) {
+	case "on", "true", "yes", "1":
+		return true, "", nil
+	case "", "off", "false", "no", "0":
+		return false, "", nil
+	}
+	return false, datastructure.ErrInvalidParameter, errors.New(name + " have to be a flag (on, off, true, false)")
+}
+
+// ParseIntParameter parse an integer received as INPUT parameter, defaultValue is returned when the parameter is missing.
+// The values lower than min are rejected. In case of error, the error code related to the invalid parameter is returned
+func ParseIntParameter(ctx *fasthttp.RequestCtx, name string, defaultValue, min int) (int, string, error) {
+	value := strings.TrimSpace(string(ctx.FormValue(name)))
+	if value == "" {
+		return defaultValue, "", nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < min {
+		return 0, datastructure.ErrInvalidParameter, errors.New(name + " have to be an integer greater or equal than " + strconv.Itoa(min))
+	}
+	return n, "", nil
+}
+
+// parameterError prefix the error with the name of the invalid parameter
+func parameterError(name string, err error) error {
+	return errors.New(name + ": " + err.Error())
+}
+
+// missingParameterError describe the missing mandatory parameters with an example of the request
+func missingParameterError(names, example string) error {
+	return errors.New("missing parameter " + names + " (" + example + ")")
+}
@@ -0,0 +1,268 @@
+package parser
+
+import (
+	"bytes"
+	"errors"
+	"html"
+	"strconv"
+	"strings"
+)
+
+/* ------------- ANSI ------------- */
+
+const escape = 0x1b
+
+// ANSIMode is the way used for expose the ANSI escape sequences contained in the lines
+type ANSIMode int
+
+// ANSIKeep return the lines as they are, ANSIStrip remove the escape sequences, ANSIHTML convert the colours to HTML spans
+const (
+	ANSIKeep ANSIMode = iota
+	ANSIStrip
+	ANSIHTML
+)
+
+// ParseANSIMode return the mode related to the given name (keep, strip, html). The empty string is ANSIKeep
+func ParseANSIMode(name string) (ANSIMode, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "keep":
+		return ANSIKeep, nil
+	case "strip":
+		return ANSIStrip, nil
+	case "html":
+		return ANSIHTML, nil
+	}
+	return ANSIKeep, errors.New("unknown ansi mode: " + name + " (keep, strip, html)")
+}
+
+// Apply convert the data using the mode
+func (m ANSIMode) Apply(data []byte) []byte {
+	switch m {
+	case ANSIStrip:
+		return StripANSI(data)
+	case ANSIHTML:
+		return ANSIToHTML(data)
+	}
+	return data
+}
+
+// escapeLength return the length of the escape sequence that start at the given position (0 if it is not a complete sequence).
+// The CSI sequences (ESC [ params final) are returned with their final byte, so the SGR ones (colours) can be recognized
+func escapeLength(data []byte, start int) (int, byte) {
+	if start+1 >= len(data) {
+		return 0, 0
+	}
+	switch data[start+1] {
+	case '[': // CSI: parameters 0x30-0x3F, intermediates 0x20-0x2F, final 0x40-0x7E
+		i := start + 2
+		for i < len(data) && data[i] >= 0x20 && data[i] <= 0x3f {
+			i++
+		}
+		if i < len(data) && data[i] >= 0x40 && data[i] <= 0x7e {
+			return i - start + 1, data[i]
+		}
+		return 0, 0
+	case ']': // OSC: terminated by BEL or ESC \
+		for i := start + 2; i < len(data) && data[i] != '\n'; i++ {
+			if data[i] == 0x07 {
+				return i - start + 1, ']'
+			}
+			if data[i] == escape && i+1 < len(data) && data[i+1] == '\\' {
+				return i - start + 2, ']'
+			}
+		}
+		return 0, 0
+	}
+	if data[start+1] >= 0x40 && data[start+1] <= 0x5f { // Two bytes sequences
+		return 2, data[start+1]
+	}
+	return 0, 0
+}
+
+// StripANSI remove the ANSI escape sequences from the data. The data is returned as is when it does not contain escapes
+func StripANSI(data []byte) []byte {
+	if bytes.IndexByte(data, escape) < 0 {
+		return data
+	}
+	stripped := make([]byte, 0, len(data))
+	for i := 0; i < len(data); i++ {
+		if data[i] == escape {
+			if n, _ := escapeLength(data, i); n > 0 {
+				i += n - 1
+				continue
+			}
+		}
+		stripped = append(stripped, data[i])
+	}
+	return stripped
+}
+
+// ansiPalette contains the colours of the 16 base ANSI colours (normal and bright)
+var ansiPalette = [16]string{
+	"#000000", "#cd3131", "#0dbc79", "#e5e510", "#2472c8", "#bc3fbc", "#11a8cd", "#e5e5e5",
+	"#666666", "#f14c4c", "#23d18b", "#f5f543", "#3b8eea", "#d670d6", "#29b8db", "#ffffff",
+}
+
+// ansiStyle is the graphic state defined by the SGR sequences
+type ansiStyle struct {
+	fg, bg                       string
+	bold, dim, italic, underline bool
+}
+
+func (s ansiStyle) css() string {
+	var css []string
+	if s.fg != "" {
+		css = append(css, "color:"+s.fg)
+	}
+	if s.bg != "" {
+		css = append(css, "background-color:"+s.bg)
+	}
+	if s.bold {
+		css = append(css, "font-weight:bold")
+	}
+	if s.dim {
+		css = append(css, "opacity:0.7")
+	}
+	if s.italic {
+		css = append(css, "font-style:italic")
+	}
+	if s.underline {
+		css = append(css, "text-decoration:underline")
+	}
+	return strings.Join(css, ";")
+}
+
+// color256 return the colour related to the index of the 256 colours palette
+func color256(n int) string {
+	switch {
+	case n < 16:
+		return ansiPalette[n]
+	case n < 232:
+		n -= 16
+		levels := [6]int{0, 95, 135, 175, 215, 255}
+		return rgb(levels[n/36], levels[n/6%6], levels[n%6])
+	}
+	gray := 8 + (n-232)*10
+	return rgb(gray, gray, gray)
+}
+
+func rgb(r, g, b int) string {
+	return "rgb(" + strconv.Itoa(r) + "," + strconv.Itoa(g) + "," + strconv.Itoa(b) + ")"
+}
+
+// apply update the style using the parameters of a SGR sequence (1;31)
+func (s *ansiStyle) apply(params string) {
+	codes := strings.Split(params, ";")
+	for i := 0; i < len(codes); i++ {
+		code, err := strconv.Atoi(codes[i])
+		if err != nil {
+			if codes[i] != "" {
+				continue
+			}
+			code = 0 // ESC[m is a reset
+		}
+		switch {
+		case code == 0:
+			*s = ansiStyle{}
+		case code == 1:
+			s.bold = true
+		case code == 2:
+			s.dim = true
+		case code == 3:
+			s.italic = true
+		case code == 4:
+			s.underline = true
+		case code == 22:
+			s.bold, s.dim = false, false
+		case code == 23:
+			s.italic = false
+		case code == 24:
+			s.underline = false
+		case code >= 30 && code <= 37:
+			s.fg = ansiPalette[code-30]
+		case code >= 90 && code <= 97:
+			s.fg = ansiPalette[code-90+8]
+		case code == 39:
+			s.fg = ""
+		case code >= 40 && code <= 47:
+			s.bg = ansiPalette[code-40]
+		case code >= 100 && code <= 107:
+			s.bg = ansiPalette[code-100+8]
+		case code == 49:
+			s.bg = ""
+		case code == 38 || code == 48: // Extended colours: 38;5;n or 38;2;r;g;b
+			var color string
+			if i+2 < len(codes) && codes[i+1] == "5" {
+				if n, err := strconv.Atoi(codes[i+2]); err == nil && n >= 0 && n < 256 {
+					color = color256(n)
+				}
+				i += 2
+			} else if i+4 < len(codes) && codes[i+1] == "2" {
+				r, _ := strconv.Atoi(codes[i+2])
+				g, _ := strconv.Atoi(codes[i+3])
+				b, _ := strconv.Atoi(codes[i+4])
+				color = rgb(r, g, b)
+				i += 4
+			}
+			if code == 38 {
+				s.fg = color
+			} else {
+				s.bg = color
+			}
+		}
+	}
+}
+
+// ANSIToHTML escape the data for HTML and convert the colours (SGR sequences) to <span style="..."> elements.
+// The other escape sequences are removed. The spans are closed at the end of every line, so every line is a valid HTML fragment
+func ANSIToHTML(data []byte) []byte {
+	var buffer bytes.Buffer
+	var style ansiStyle
+	open := false
+	closeSpan := func() {
+		if open {
+			buffer.WriteString("</span>")
+			open = false
+		}
+	}
+	openSpan := func() {
+		if css := style.css(); css != "" {
+			buffer.WriteString(`<span style="` + css + `">`)
+			open = true
+		}
+	}
+	start := 0 // Start of the text not yet written
+	flush := func(end int) {
+		if end > start {
+			buffer.WriteString(html.EscapeString(string(data[start:end])))
+		}
+	}
+	for i := 0; i < len(data); i++ {
+		switch data[i] {
+		case '\n':
+			flush(i)
+			closeSpan()
+			buffer.WriteByte('\n')
+			start = i + 1
+			if start < len(data) {
+				openSpan()
+			}
+		case escape:
+			n, final := escapeLength(data, i)
+			if n == 0 {
+				continue
+			}
+			flush(i)
+			if final == 'm' {
+				closeSpan()
+				style.apply(string(data[i+2 : i+n-1]))
+				openSpan()
+			}
+			i += n - 1
+			start = i + 1
+		}
+	}
+	flush(len(data))
+	closeSpan()
+	return buffer.Bytes()
+}
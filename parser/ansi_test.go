@@ -0,0 +1,88 @@
+package parser
+
+import "testing"
+
+func TestParseANSIMode(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    ANSIMode
+		invalid bool
+	}{
+		{name: "", mode: ANSIKeep},
+		{name: "keep", mode: ANSIKeep},
+		{name: " Strip ", mode: ANSIStrip},
+		{name: "HTML", mode: ANSIHTML},
+		{name: "color", invalid: true},
+	}
+	for _, test := range tests {
+		mode, err := ParseANSIMode(test.name)
+		if test.invalid != (err != nil) {
+			t.Errorf("ParseANSIMode(%q) error = %v, expected invalid %v", test.name, err, test.invalid)
+			continue
+		}
+		if mode != test.mode {
+			t.Errorf("ParseANSIMode(%q) = %d, expected %d", test.name, mode, test.mode)
+		}
+	}
+}
+
+func TestStripANSI(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected string
+	}{
+		{line: "plain line", expected: "plain line"},
+		{line: "\x1b[31mERROR\x1b[0m failed", expected: "ERROR failed"},
+		{line: "\x1b[1;38;5;208mwarn\x1b[m", expected: "warn"},
+		{line: "\x1b[2K\x1b[1Gprogress 50%", expected: "progress 50%"},          // Cursor movements
+		{line: "\x1b]0;title\x07text", expected: "text"},                        // OSC terminated by BEL
+		{line: "\x1b]8;;http://host\x1b\\link\x1b]8;;\x1b\\", expected: "link"}, // OSC terminated by ESC \
+		{line: "\x1bMup", expected: "up"},                                       // Two bytes sequence
+		{line: "broken \x1b[31", expected: "broken \x1b[31"},                    // Incomplete sequences are kept
+		{line: "end \x1b", expected: "end \x1b"},
+	}
+	for _, test := range tests {
+		if stripped := string(StripANSI([]byte(test.line))); stripped != test.expected {
+			t.Errorf("StripANSI(%q) = %q, expected %q", test.line, stripped, test.expected)
+		}
+	}
+}
+
+func TestANSIToHTML(t *testing.T) {
+	tests := []struct {
+		line     string
+		expected string
+	}{
+		{line: "a < b & c", expected: "a &lt; b &amp; c"},
+		{line: "\x1b[31mERROR\x1b[0m failed", expected: `<span style="color:#cd3131">ERROR</span> failed`},
+		{line: "\x1b[1;92m<ok>\x1b[22m done", expected: `<span style="color:#23d18b;font-weight:bold">&lt;ok&gt;</span><span style="color:#23d18b"> done</span>`},
+		{line: "\x1b[44;3;4mx\x1b[49;23;24my", expected: `<span style="background-color:#2472c8;font-style:italic;text-decoration:underline">x</span>y`},
+		{line: "\x1b[38;5;196ma\x1b[38;5;244mb\x1b[39mc", expected: `<span style="color:rgb(255,0,0)">a</span><span style="color:rgb(128,128,128)">b</span>c`},
+		{line: "\x1b[48;2;10;20;30mbg\x1b[m", expected: `<span style="background-color:rgb(10,20,30)">bg</span>`},
+		{line: "\x1b[2mdim\x1b[2K", expected: `<span style="opacity:0.7">dim</span>`}, // The other sequences are removed
+		{line: "\x1b[31mfirst\nsecond\x1b[0m\n", expected: "<span style=\"color:#cd3131\">first</span>\n<span style=\"color:#cd3131\">second</span>\n"},
+		{line: "unclosed \x1b[33mwarn", expected: `unclosed <span style="color:#e5e510">warn</span>`},
+	}
+	for _, test := range tests {
+		if converted := string(ANSIToHTML([]byte(test.line))); converted != test.expected {
+			t.Errorf("ANSIToHTML(%q) = %q, expected %q", test.line, converted, test.expected)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	line := []byte("\x1b[31m<x>\x1b[0m")
+	tests := []struct {
+		mode     ANSIMode
+		expected string
+	}{
+		{mode: ANSIKeep, expected: string(line)},
+		{mode: ANSIStrip, expected: "<x>"},
+		{mode: ANSIHTML, expected: `<span style="color:#cd3131">&lt;x&gt;</span>`},
+	}
+	for _, test := range tests {
+		if applied := string(test.mode.Apply(line)); applied != test.expected {
+			t.Errorf("mode %d: Apply() = %q, expected %q", test.mode, applied, test.expected)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+/* ------------- FIELDS ------------- */
+
+// ParseFields extract the fields from a structured (json) line.
+// The json object can be preceded by a prefix (timestamp, hostname ...). Return nil if the line is not structured
+func ParseFields(line []byte) map[string]interface{} {
+	start := bytes.IndexByte(line, '{')
+	end := bytes.LastIndexByte(line, '}')
+	if start < 0 || end < start {
+		return nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(line[start : end+1]))
+	decoder.UseNumber() // Avoid to lose precision on big integer (ids)
+	var fields map[string]interface{}
+	if err := decoder.Decode(&fields); err != nil {
+		return nil
+	}
+	return fields
+}
+
+// Lookup return the value related to the given path. Nested objects are reached using the dot notation (http.request.method),
+// array elements by index (items.0.id). A key that contains dots is preferred to the nested path.
+func Lookup(fields map[string]interface{}, path string) (interface{}, bool) {
+	if fields == nil {
+		return nil, false
+	}
+	if value, ok := fields[path]; ok {
+		return value, true
+	}
+	var current interface{} = fields
+	for _, key := range strings.Split(path, ".") {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, ok := node[key]
+			if !ok {
+				return nil, false
+			}
+			current = value
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, false
+			}
+			current = node[i]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// LookupNumber return the value related to the given path as a number (json number or numeric string)
+func LookupNumber(fields map[string]interface{}, path string) (float64, bool) {
+	if path == "" {
+		return 0, false
+	}
+	value, ok := Lookup(fields, path)
+	if !ok {
+		return 0, false
+	}
+	switch v := value.(type) {
+	case json.Number:
+		n, err := v.Float64()
+		return n, err == nil
+	case float64:
+		return v, true
+	case string:
+		n, err := strconv.ParseFloat(v, 64)
+		return n, err == nil
+	}
+	return 0, false
+}
@@ -0,0 +1,233 @@
+package parser
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+/* ------------- GROK ------------- */
+
+// BuiltinPatterns is the library of the grok patterns available without any configuration
+var BuiltinPatterns = map[string]string{
+	"WORD":              `\b\w+\b`,
+	"NOTSPACE":          `\S+`,
+	"SPACE":             `\s*`,
+	"DATA":              `.*?`,
+	"GREEDYDATA":        `.*`,
+	"INT":               `[+-]?[0-9]+`,
+	"POSINT":            `\b[1-9][0-9]*\b`,
+	"NONNEGINT":         `\b[0-9]+\b`,
+	"BASE10NUM":         `[+-]?(?:[0-9]+(?:\.[0-9]+)?|\.[0-9]+)`,
+	"NUMBER":            `%{BASE10NUM}`,
+	"BASE16NUM":         `(?:0[xX])?[0-9A-Fa-f]+`,
+	"UUID":              `[A-Fa-f0-9]{8}-(?:[A-Fa-f0-9]{4}-){3}[A-Fa-f0-9]{12}`,
+	"IPV4":              `(?:(?:25[0-5]|2[0-4][0-9]|1?[0-9]?[0-9])\.){3}(?:25[0-5]|2[0-4][0-9]|1?[0-9]?[0-9])`,
+	"IPV6":              `(?:[0-9A-Fa-f]{0,4}:){2,7}[0-9A-Fa-f]{0,4}`,
+	"IP":                `(?:%{IPV6}|%{IPV4})`,
+	"HOSTNAME":          `\b[0-9A-Za-z][0-9A-Za-z-]{0,62}(?:\.[0-9A-Za-z][0-9A-Za-z-]{0,62})*\.?\b`,
+	"IPORHOST":          `(?:%{IP}|%{HOSTNAME})`,
+	"HOSTPORT":          `%{IPORHOST}:%{POSINT}`,
+	"USERNAME":          `[a-zA-Z0-9._-]+`,
+	"USER":              `%{USERNAME}`,
+	"EMAILADDRESS":      `[a-zA-Z0-9!#$%&'*+/=?^_{|}~.-]+@%{HOSTNAME}`,
+	"PATH":              `(?:/[^\s]*)+`,
+	"URIPATH":           `(?:/[A-Za-z0-9$.+!*'(){},~:;=@#%&_\-]*)+`,
+	"URIPARAM":          `\?[A-Za-z0-9$.+!*'|(){},~@#%&/=:;_?\-\[\]<>]*`,
+	"URIPATHPARAM":      `%{URIPATH}(?:%{URIPARAM})?`,
+	"QUOTEDSTRING":      `"(?:[^"\\]|\\.)*"`,
+	"MONTH":             `\b(?:Jan(?:uary)?|Feb(?:ruary)?|Mar(?:ch)?|Apr(?:il)?|May|June?|July?|Aug(?:ust)?|Sep(?:tember)?|Oct(?:ober)?|Nov(?:ember)?|Dec(?:ember)?)\b`,
+	"MONTHNUM":          `(?:0?[1-9]|1[0-2])`,
+	"MONTHDAY":          `(?:(?:0[1-9])|(?:[12][0-9])|(?:3[01])|[1-9])`,
+	"DAY":               `(?:Mon(?:day)?|Tue(?:sday)?|Wed(?:nesday)?|Thu(?:rsday)?|Fri(?:day)?|Sat(?:urday)?|Sun(?:day)?)`,
+	"YEAR":              `(?:\d\d){1,2}`,
+	"HOUR":              `(?:2[0123]|[01]?[0-9])`,
+	"MINUTE":            `(?:[0-5][0-9])`,
+	"SECOND":            `(?:(?:[0-5]?[0-9]|60)(?:[:.,][0-9]+)?)`,
+	"TIME":              `%{HOUR}:%{MINUTE}(?::%{SECOND})?`,
+	"ISO8601_TIMEZONE":  `(?:Z|[+-]%{HOUR}(?::?%{MINUTE}))`,
+	"TIMESTAMP_ISO8601": `%{YEAR}-%{MONTHNUM}-%{MONTHDAY}[T ]%{HOUR}:?%{MINUTE}(?::?%{SECOND})?%{ISO8601_TIMEZONE}?`,
+	"DATE_EU":           `%{MONTHDAY}[./-]%{MONTHNUM}[./-]%{YEAR}`,
+	"DATE_US":           `%{MONTHNUM}[/-]%{MONTHDAY}[/-]%{YEAR}`,
+	"SYSLOGTIMESTAMP":   `%{MONTH} +%{MONTHDAY} %{TIME}`,
+	"HTTPDATE":          `%{MONTHDAY}/%{MONTH}/%{YEAR}:%{TIME} %{INT}`,
+	"LOGLEVEL":          `(?i:trace|debug|info(?:rmation)?|notice|warn(?:ing)?|err(?:or)?|crit(?:ical)?|fatal|severe|panic|emerg(?:ency)?|alert)`,
+}
+
+// grokReference match the %{PATTERN}, %{PATTERN:field} and %{PATTERN:field:type} syntax
+var grokReference = regexp.MustCompile(`%\{(\w+)(?::([\w.@\-]+))?(?::(int|float|string))?\}`)
+
+// PatternBinding bind a grok expression to the files that match the glob
+type PatternBinding struct {
+	Files   string `json:"Files"`   // Glob of the files (matched against the full path and the name of the file)
+	Pattern string `json:"Pattern"` // Grok expression used for extract the fields
+}
+
+// PatternConfiguration is the configuration of the custom patterns
+type PatternConfiguration struct {
+	Patterns map[string]string `json:"Patterns"` // Named patterns, can be used in the bindings and in other patterns
+	Bindings []PatternBinding  `json:"Bindings"` // Expressions bound to the files
+}
+
+// Grok is a compiled grok expression
+type Grok struct {
+	regex    *regexp.Regexp
+	types    map[string]string // Conversion (int, float) for the captured fields
+	captures []string          // Fields captured by the expression, in order of reference
+	fields   []string          // Field of every group of the regexp
+}
+
+type grokBinding struct {
+	files string
+	grok  *Grok
+}
+
+// Parser extract the fields from the lines. The grok expressions bound to the file are tried in order, the json format is used as fallback.
+// A nil Parser use only the json format
+type Parser struct {
+	bindings []grokBinding
+}
+
+// LoadParser read the (json) pattern configuration and compile the parser
+func LoadParser(path string) (*Parser, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cfg PatternConfiguration
+	if err = json.Unmarshal(data, &cfg); err != nil {
+		return nil, errors.New("unable to parse " + path + ": " + err.Error())
+	}
+	return NewParser(cfg)
+}
+
+// NewParser compile every binding of the configuration
+func NewParser(cfg PatternConfiguration) (*Parser, error) {
+	library := make(map[string]string, len(BuiltinPatterns)+len(cfg.Patterns))
+	for name, pattern := range BuiltinPatterns {
+		library[name] = pattern
+	}
+	for name, pattern := range cfg.Patterns {
+		library[name] = pattern
+	}
+	p := &Parser{}
+	for _, binding := range cfg.Bindings {
+		if _, err := filepath.Match(binding.Files, ""); err != nil {
+			return nil, fmt.Errorf("invalid glob %q: %s", binding.Files, err)
+		}
+		grok, err := CompileGrok(binding.Pattern, library)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern for %q: %s", binding.Files, err)
+		}
+		p.bindings = append(p.bindings, grokBinding{files: binding.Files, grok: grok})
+	}
+	return p, nil
+}
+
+// CompileGrok expand the references of the expression using the given library and compile it
+func CompileGrok(expr string, library map[string]string) (*Grok, error) {
+	g := &Grok{types: make(map[string]string)}
+	expanded, err := g.expand(expr, library, 0)
+	if err != nil {
+		return nil, err
+	}
+	if g.regex, err = regexp.Compile(expanded); err != nil {
+		return nil, err
+	}
+	g.fields = make([]string, len(g.regex.SubexpNames()))
+	for i, group := range g.regex.SubexpNames() {
+		g.fields[i] = group // The groups named in the custom patterns keep their name
+		if strings.HasPrefix(group, groupPrefix) {
+			if n, err := strconv.Atoi(group[len(groupPrefix):]); err == nil && n < len(g.captures) {
+				g.fields[i] = g.captures[n]
+			}
+		}
+	}
+	return g, nil
+}
+
+func (g *Grok) expand(expr string, library map[string]string, depth int) (string, error) {
+	if depth > 32 {
+		return "", errors.New("pattern nested too deep (recursive definition?)")
+	}
+	var err error
+	expanded := grokReference.ReplaceAllStringFunc(expr, func(reference string) string {
+		groups := grokReference.FindStringSubmatch(reference)
+		pattern, ok := library[groups[1]]
+		if !ok {
+			err = errors.New("unknown pattern " + groups[1])
+			return ""
+		}
+		inner, e := g.expand(pattern, library, depth+1)
+		if e != nil {
+			err = e
+			return ""
+		}
+		if groups[2] == "" {
+			return "(?:" + inner + ")"
+		}
+		if groups[3] != "" {
+			g.types[groups[2]] = groups[3]
+		}
+		g.captures = append(g.captures, groups[2])
+		return "(?P<" + groupPrefix + strconv.Itoa(len(g.captures)-1) + ">" + inner + ")"
+	})
+	return expanded, err
+}
+
+// groupPrefix is the prefix of the regexp groups of the captures. The field names (http.method, @timestamp) are not valid group names,
+// so the groups are numbered (grok0, grok1) and mapped back to the fields after the compile
+const groupPrefix = "grok"
+
+// Fields return the named captures of the expression, nil if the line does not match
+func (g *Grok) Fields(line []byte) map[string]interface{} {
+	match := g.regex.FindSubmatch(line)
+	if match == nil {
+		return nil
+	}
+	fields := make(map[string]interface{})
+	for i, name := range g.fields {
+		if name == "" || match[i] == nil {
+			continue
+		}
+		value := string(match[i])
+		switch g.types[name] {
+		case "int", "float":
+			if _, err := strconv.ParseFloat(value, 64); err == nil {
+				fields[name] = json.Number(value)
+				continue
+			}
+		}
+		fields[name] = value
+	}
+	return fields
+}
+
+// Fields extract the fields of the line of the given file, ignoring the ANSI escape sequences
+func (p *Parser) Fields(path string, line []byte) map[string]interface{} {
+	line = StripANSI(line)
+	if p != nil {
+		name := filepath.Base(path)
+		for _, binding := range p.bindings {
+			if matchGlob(binding.files, path, name) {
+				if fields := binding.grok.Fields(line); fields != nil {
+					return fields
+				}
+			}
+		}
+	}
+	return ParseFields(line)
+}
+
+func matchGlob(glob, path, name string) bool {
+	if ok, _ := filepath.Match(glob, path); ok {
+		return true
+	}
+	ok, _ := filepath.Match(glob, name)
+	return ok
+}
@@ -0,0 +1,154 @@
+package parser
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestCompileGrok(t *testing.T) {
+	library := map[string]string{
+		"THREAD":   `[\w-]+`,
+		"REQUEST":  `%{WORD:method} %{URIPATHPARAM:path}`,
+		"ACCESS":   `%{IP:client} "%{REQUEST}" %{NUMBER:status:int}`,
+		"LOOP":     `a%{LOOP}`,
+		"PING":     `%{PONG}`,
+		"PONG":     `%{PING}`,
+		"BROKEN":   `(`,
+		"NAMED":    `(?P<thread>\d+)`,
+		"INTERNAL": `%{NOTSPACE:grok0}`,
+	}
+	for name, pattern := range BuiltinPatterns {
+		library[name] = pattern
+	}
+	tests := []struct {
+		name     string
+		expr     string
+		line     string
+		expected map[string]interface{} // nil if the line does not match
+		invalid  string                 // Part of the compile error
+	}{
+		{name: "ipv4", expr: `%{IP:client}`, line: "from 10.0.0.12 port", expected: map[string]interface{}{"client": "10.0.0.12"}},
+		{name: "ipv6", expr: `^%{IP:client} `, line: "fe80::1ff:fe23:4567:890a GET", expected: map[string]interface{}{"client": "fe80::1ff:fe23:4567:890a"}},
+		{name: "timestamp", expr: `^%{TIMESTAMP_ISO8601:time} %{LOGLEVEL:level}`, line: "2024-01-02T10:00:00.123+01:00 WARN slow",
+			expected: map[string]interface{}{"time": "2024-01-02T10:00:00.123+01:00", "level": "WARN"}},
+		{name: "timestamp with space", expr: `^%{TIMESTAMP_ISO8601:time}`, line: "2024-01-02 10:00:00,5 x", expected: map[string]interface{}{"time": "2024-01-02 10:00:00,5"}},
+		{name: "number", expr: `took %{NUMBER:latency}ms`, line: "took 12.5ms", expected: map[string]interface{}{"latency": "12.5"}},
+		{name: "typed int", expr: `took %{NUMBER:latency:int}ms`, line: "took -3ms", expected: map[string]interface{}{"latency": json.Number("-3")}},
+		{name: "typed float", expr: `took %{NUMBER:latency:float}ms`, line: "took .5ms", expected: map[string]interface{}{"latency": json.Number(".5")}},
+		{name: "typed string", expr: `id=%{INT:id:string}`, line: "id=42", expected: map[string]interface{}{"id": "42"}},
+		{name: "typed not a number", expr: `took %{NOTSPACE:latency:int}`, line: "took slow", expected: map[string]interface{}{"latency": "slow"}},
+		{name: "not captured", expr: `^%{WORD} %{WORD:second}`, line: "first second", expected: map[string]interface{}{"second": "second"}},
+		{name: "nested", expr: `^%{ACCESS}`, line: `10.0.0.1 "GET /index.html?a=1" 200`,
+			expected: map[string]interface{}{"client": "10.0.0.1", "method": "GET", "path": "/index.html?a=1", "status": json.Number("200")}},
+		{name: "nested captured", expr: `^%{REQUEST:request}$`, line: "POST /api",
+			expected: map[string]interface{}{"request": "POST /api", "method": "POST", "path": "/api"}},
+		{name: "custom", expr: `\[%{THREAD:thread}\]`, line: "[pool-1-thread-3] started", expected: map[string]interface{}{"thread": "pool-1-thread-3"}},
+		{name: "names", expr: `%{WORD:a.b} %{WORD:a__b} %{WORD:@timestamp} %{WORD:x-id}`, line: "one two three four",
+			expected: map[string]interface{}{"a.b": "one", "a__b": "two", "@timestamp": "three", "x-id": "four"}},
+		{name: "group of the custom pattern", expr: `%{NAMED} %{WORD:level}`, line: "17 INFO", expected: map[string]interface{}{"thread": "17", "level": "INFO"}},
+		{name: "field named as the groups", expr: `%{INTERNAL} %{WORD:grok1}`, line: "a b", expected: map[string]interface{}{"grok0": "a", "grok1": "b"}},
+		{name: "not matching", expr: `^%{IP:client}`, line: "localhost GET"},
+		{name: "unknown", expr: `%{WORD:a} %{MISSING:b}`, invalid: "unknown pattern MISSING"},
+		{name: "unknown nested", expr: `%{ACCESS} %{REQUESTX}`, invalid: "unknown pattern REQUESTX"},
+		{name: "recursive", expr: `%{LOOP}`, invalid: "nested too deep"},
+		{name: "mutually recursive", expr: `x %{PING}`, invalid: "nested too deep"},
+		{name: "invalid regexp", expr: `%{BROKEN}`, invalid: "missing closing )"},
+	}
+	for _, test := range tests {
+		grok, err := CompileGrok(test.expr, library)
+		if test.invalid != "" {
+			if err == nil || !strings.Contains(err.Error(), test.invalid) {
+				t.Errorf("%s: CompileGrok(%q) error = %v, expected %q", test.name, test.expr, err, test.invalid)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: CompileGrok(%q): unexpected error %v", test.name, test.expr, err)
+			continue
+		}
+		if fields := grok.Fields([]byte(test.line)); !reflect.DeepEqual(fields, test.expected) {
+			t.Errorf("%s: Fields(%q) = %v, expected %v", test.name, test.line, fields, test.expected)
+		}
+	}
+}
+
+func TestCompileGrokDepth(t *testing.T) {
+	library := map[string]string{"P0": "x"}
+	for i := 1; i <= 40; i++ {
+		library["P"+strconv.Itoa(i)] = "%{P" + strconv.Itoa(i-1) + "}"
+	}
+	if _, err := CompileGrok("%{P31}", library); err != nil { // 32 nested patterns (P31 ... P0)
+		t.Errorf("32 nested patterns: unexpected error %v", err)
+	}
+	if _, err := CompileGrok("%{P32}", library); err == nil {
+		t.Error("33 nested patterns: expected an error")
+	}
+}
+
+func TestParser(t *testing.T) {
+	p, err := NewParser(PatternConfiguration{
+		Patterns: map[string]string{"THREAD": `[\w-]+`},
+		Bindings: []PatternBinding{
+			{Files: "legacy-*.log", Pattern: `^%{TIMESTAMP_ISO8601:time} %{LOGLEVEL:level} \[%{THREAD:thread}\]`},
+			{Files: "/var/log/nginx/*", Pattern: `^%{IP:client} %{WORD:method} %{NUMBER:status:int}`},
+			{Files: "*.log", Pattern: `^%{WORD:level}: %{GREEDYDATA:msg}`}, // Tried after the bindings above
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name     string
+		path     string
+		line     string
+		expected map[string]interface{}
+	}{
+		{name: "name glob", path: "/opt/app/legacy-1.log", line: "2024-01-02 10:00:00 INFO [main] started",
+			expected: map[string]interface{}{"time": "2024-01-02 10:00:00", "level": "INFO", "thread": "main"}},
+		{name: "path glob", path: "/var/log/nginx/access", line: "10.0.0.1 GET 200",
+			expected: map[string]interface{}{"client": "10.0.0.1", "method": "GET", "status": json.Number("200")}},
+		{name: "path glob on other directory", path: "/srv/nginx/access", line: "10.0.0.1 GET 200"},
+		{name: "next binding", path: "/opt/app/legacy-1.log", line: "ERROR: disk full", expected: map[string]interface{}{"level": "ERROR", "msg": "disk full"}},
+		{name: "json fallback", path: "/opt/app/legacy-1.log", line: `{"level": "warn"}`, expected: map[string]interface{}{"level": "warn"}},
+		{name: "json not bound", path: "/opt/app/app.json", line: `x {"latency": 12}`, expected: map[string]interface{}{"latency": json.Number("12")}},
+		{name: "not structured", path: "/opt/app/app.json", line: "ERROR: disk full"},
+		{name: "colours", path: "/var/log/nginx/access", line: "\x1b[32m10.0.0.1\x1b[0m GET 404",
+			expected: map[string]interface{}{"client": "10.0.0.1", "method": "GET", "status": json.Number("404")}},
+	}
+	for _, test := range tests {
+		if fields := p.Fields(test.path, []byte(test.line)); !reflect.DeepEqual(fields, test.expected) {
+			t.Errorf("%s: Fields(%s, %q) = %v, expected %v", test.name, test.path, test.line, fields, test.expected)
+		}
+	}
+	var none *Parser // Only json
+	if fields := none.Fields("legacy-1.log", []byte(`{"a": "b"}`)); fields["a"] != "b" {
+		t.Errorf("Fields() = %v, expected the json fields", fields)
+	}
+}
+
+func TestNewParserInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     PatternConfiguration
+		invalid string
+	}{
+		{name: "glob", cfg: PatternConfiguration{Bindings: []PatternBinding{{Files: "[", Pattern: "%{WORD}"}}}, invalid: "invalid glob"},
+		{name: "unknown", cfg: PatternConfiguration{Bindings: []PatternBinding{{Files: "*.log", Pattern: "%{THREAD:t}"}}}, invalid: "unknown pattern THREAD"},
+		{name: "override", cfg: PatternConfiguration{Patterns: map[string]string{"WORD": "%{GREEDYDATA:x}"}, Bindings: []PatternBinding{{Files: "*.log", Pattern: "%{WORD:w}"}}}},
+	}
+	for _, test := range tests {
+		_, err := NewParser(test.cfg)
+		if test.invalid == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", test.name, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), test.invalid) {
+			t.Errorf("%s: NewParser() error = %v, expected %q", test.name, err, test.invalid)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package parser
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+)
+
+/* ------------- LEVEL ------------- */
+
+// Level is the severity of a log line, ordered from the less to the most important
+type Level int
+
+// Severity supported by the parser. LevelUnknown is used when the line does not contain a recognizable level
+const (
+	LevelUnknown Level = iota
+	LevelTrace
+	LevelDebug
+	LevelInfo
+	LevelWarn
+	LevelError
+	LevelFatal
+)
+
+// Levels contains every known level, sorted by severity
+var Levels = []Level{LevelTrace, LevelDebug, LevelInfo, LevelWarn, LevelError, LevelFatal}
+
+var levelNames = map[Level]string{
+	LevelUnknown: "UNKNOWN",
+	LevelTrace:   "TRACE",
+	LevelDebug:   "DEBUG",
+	LevelInfo:    "INFO",
+	LevelWarn:    "WARN",
+	LevelError:   "ERROR",
+	LevelFatal:   "FATAL",
+}
+
+// levelAliases map every (lower case) name used by the common logging libraries to the related level.
+// The four letters names (TRAC, DEBU, ERRO, FATA, PANI) are the ones used by logrus when the colours are enabled
+var levelAliases = map[string]Level{
+	"trace": LevelTrace, "trc": LevelTrace, "trac": LevelTrace, "finest": LevelTrace,
+	"debug": LevelDebug, "dbg": LevelDebug, "debu": LevelDebug, "fine": LevelDebug,
+	"info": LevelInfo, "inf": LevelInfo, "information": LevelInfo, "notice": LevelInfo,
+	"warn": LevelWarn, "warning": LevelWarn, "wrn": LevelWarn,
+	"error": LevelError, "err": LevelError, "eror": LevelError, "erro": LevelError, "severe": LevelError,
+	"fatal": LevelFatal, "ftl": LevelFatal, "fata": LevelFatal, "critical": LevelFatal, "crit": LevelFatal, "panic": LevelFatal, "pani": LevelFatal,
+	"emerg": LevelFatal, "emergency": LevelFatal, "alert": LevelFatal,
+}
+
+// levelKeys are the keys that precede the level in the key=value and json formats (level=info, "level":"info")
+var levelKeys = map[string]struct{}{"level": {}, "lvl": {}, "severity": {}, "loglevel": {}}
+
+// String return the canonical name of the level
+func (l Level) String() string {
+	if name, ok := levelNames[l]; ok {
+		return name
+	}
+	return levelNames[LevelUnknown]
+}
+
+// ParseLevelName return the level related to the given name (case insensitive), aliases included
+func ParseLevelName(name string) Level {
+	return levelAliases[strings.ToLower(strings.TrimSpace(name))]
+}
+
+// DetectLevel try to recognize the level of the given line.
+// An explicit key (level=warn, "level":"warn") always win, otherwise the first upper case alias (WARN, [ERROR]) is used.
+// The ANSI escape sequences are ignored
+func DetectLevel(line []byte) Level {
+	line = StripANSI(line) // The colours codes are attached to the level (ESC[31mERROR)
+	var previous string    // Previous word, used for recognize the key=value format
+	candidate := LevelUnknown
+	for start := 0; start < len(line); {
+		for start < len(line) && !isLetter(line[start]) { // Skip the separators
+			start++
+		}
+		end := start
+		for end < len(line) && isLetter(line[end]) {
+			end++
+		}
+		if end == start {
+			break
+		}
+		word := string(line[start:end])
+		if _, ok := levelKeys[strings.ToLower(previous)]; ok {
+			if level := ParseLevelName(word); level != LevelUnknown {
+				return level
+			}
+		}
+		if candidate == LevelUnknown && len(word) >= 3 && strings.ToUpper(word) == word {
+			candidate = ParseLevelName(word)
+		}
+		previous = word
+		start = end
+	}
+	return candidate
+}
+
+func isLetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// CountLevels return the number of lines for every level contained in the given (uncompressed) data
+func CountLevels(data []byte) map[string]int {
+	stats := make(map[string]int, len(levelNames))
+	for _, level := range Levels {
+		stats[level.String()] = 0
+	}
+	for _, line := range SplitLines(data) {
+		stats[DetectLevel(line).String()]++
+	}
+	return stats
+}
+
+// SplitLines split the data into lines, ignoring the trailing new line
+func SplitLines(data []byte) [][]byte {
+	data = bytes.TrimSuffix(data, []byte("\n"))
+	if len(data) == 0 {
+		return nil
+	}
+	return bytes.Split(data, []byte("\n"))
+}
+
+/* ------------- FILTER ------------- */
+
+// LevelFilter is used for select the lines by level.
+// The zero value match every line
+type LevelFilter struct {
+	levels map[Level]struct{}
+}
+
+// ParseLevelFilter parse a filter expressed as a comma separated list of level.
+// Every level can be followed by '+' for select the level and every more severe level (warn+ -> WARN, ERROR, FATAL)
+func ParseLevelFilter(filter string) (LevelFilter, error) {
+	var levelFilter LevelFilter
+	if strings.TrimSpace(filter) == "" {
+		return levelFilter, nil
+	}
+	levelFilter.levels = make(map[Level]struct{})
+	for _, token := range strings.Split(filter, ",") {
+		token = strings.TrimSpace(token)
+		orHigher := strings.HasSuffix(token, "+")
+		token = strings.TrimSuffix(token, "+")
+		level := ParseLevelName(token)
+		if level == LevelUnknown {
+			return LevelFilter{}, errors.New("unknown level: " + token)
+		}
+		levelFilter.levels[level] = struct{}{}
+		if orHigher {
+			for _, l := range Levels {
+				if l > level {
+					levelFilter.levels[l] = struct{}{}
+				}
+			}
+		}
+	}
+	return levelFilter, nil
+}
+
+// IsEmpty return true if the filter accept every line
+func (f LevelFilter) IsEmpty() bool {
+	return len(f.levels) == 0
+}
+
+// Match verify if the given line satisfy the filter
+func (f LevelFilter) Match(line []byte) bool {
+	if f.IsEmpty() {
+		return true
+	}
+	_, ok := f.levels[DetectLevel(line)]
+	return ok
+}
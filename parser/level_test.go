@@ -0,0 +1,110 @@
+package parser
+
+import "testing"
+
+func TestDetectLevel(t *testing.T) {
+	tests := []struct {
+		line  string
+		level Level
+	}{
+		{line: "2024-01-02 10:00:00 INFO server started", level: LevelInfo},
+		{line: "2024-01-02 10:00:00 [ERROR] connection refused", level: LevelError},
+		{line: "W WARN disk almost full", level: LevelWarn},
+		{line: `time="2024-01-02T10:00:00Z" level=debug msg="cache miss"`, level: LevelDebug},
+		{line: `{"ts": 1704189600, "level": "warning", "msg": "slow query"}`, level: LevelWarn},
+		{line: `{"severity":"CRITICAL","message":"out of memory"}`, level: LevelFatal},
+		{line: "lvl=trc msg=tick", level: LevelTrace},
+		{line: "INFO retry after ERROR", level: LevelInfo},                       // The first upper case alias is used
+		{line: "ERROR while reading level=info from the file", level: LevelInfo}, // The explicit key always win
+		{line: "\x1b[31mERRO\x1b[0m[0001] unable to bind", level: LevelError},    // logrus with colours
+		{line: "\x1b[33mWARN\x1b[0m[0002] retrying", level: LevelWarn},
+		{line: "PANI something went wrong", level: LevelFatal},
+		{line: "an error occurred in the info page", level: LevelUnknown}, // Lower case words are not levels
+		{line: "GET /index.html 200", level: LevelUnknown},
+		{line: "", level: LevelUnknown},
+		{line: "level=", level: LevelUnknown},
+	}
+	for _, test := range tests {
+		if level := DetectLevel([]byte(test.line)); level != test.level {
+			t.Errorf("DetectLevel(%q) = %s, expected %s", test.line, level, test.level)
+		}
+	}
+}
+
+func TestParseLevelName(t *testing.T) {
+	tests := []struct {
+		name  string
+		level Level
+	}{
+		{name: "warn", level: LevelWarn},
+		{name: " Warning ", level: LevelWarn},
+		{name: "ERR", level: LevelError},
+		{name: "notice", level: LevelInfo},
+		{name: "emerg", level: LevelFatal},
+		{name: "verbose", level: LevelUnknown},
+	}
+	for _, test := range tests {
+		if level := ParseLevelName(test.name); level != test.level {
+			t.Errorf("ParseLevelName(%q) = %s, expected %s", test.name, level, test.level)
+		}
+	}
+}
+
+func TestParseLevelFilter(t *testing.T) {
+	lines := map[Level]string{
+		LevelUnknown: "no level here",
+		LevelTrace:   "TRACE tick",
+		LevelDebug:   "DEBUG cache miss",
+		LevelInfo:    "INFO started",
+		LevelWarn:    "WARN slow query",
+		LevelError:   "ERROR refused",
+		LevelFatal:   "FATAL out of memory",
+	}
+	tests := []struct {
+		filter  string
+		invalid bool
+		match   []Level
+	}{
+		{filter: "", match: []Level{LevelUnknown, LevelTrace, LevelDebug, LevelInfo, LevelWarn, LevelError, LevelFatal}},
+		{filter: "error", match: []Level{LevelError}},
+		{filter: "warn+", match: []Level{LevelWarn, LevelError, LevelFatal}},
+		{filter: "info, debug", match: []Level{LevelDebug, LevelInfo}},
+		{filter: "trace,error+", match: []Level{LevelTrace, LevelError, LevelFatal}},
+		{filter: "FATAL+", match: []Level{LevelFatal}},
+		{filter: "verbose", invalid: true},
+		{filter: "warn,", invalid: true},
+		{filter: "+", invalid: true},
+	}
+	for _, test := range tests {
+		filter, err := ParseLevelFilter(test.filter)
+		if test.invalid {
+			if err == nil {
+				t.Errorf("ParseLevelFilter(%q): expected an error", test.filter)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseLevelFilter(%q): unexpected error %v", test.filter, err)
+			continue
+		}
+		expected := make(map[Level]bool)
+		for _, level := range test.match {
+			expected[level] = true
+		}
+		for level, line := range lines {
+			if match := filter.Match([]byte(line)); match != expected[level] {
+				t.Errorf("ParseLevelFilter(%q).Match(%q) = %v, expected %v", test.filter, line, match, expected[level])
+			}
+		}
+	}
+}
+
+func TestCountLevels(t *testing.T) {
+	stats := CountLevels([]byte("INFO a\nINFO b\nERROR c\nplain\n"))
+	expected := map[string]int{"TRACE": 0, "DEBUG": 0, "INFO": 2, "WARN": 0, "ERROR": 1, "FATAL": 0, "UNKNOWN": 1}
+	for name, n := range expected {
+		if stats[name] != n {
+			t.Errorf("CountLevels()[%s] = %d, expected %d", name, stats[name], n)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package parser
+
+import (
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+/* ------------- TIMESTAMP ------------- */
+
+// timestampKeys are the fields that usually contains the time of the structured lines
+var timestampKeys = []string{"time", "timestamp", "@timestamp", "ts", "datetime", "date"}
+
+type timestampFormat struct {
+	regex   *regexp.Regexp
+	layouts []string
+	noYear  bool // The format does not contains the year (syslog), see withYear
+}
+
+// timestampFormats are the formats recognized in the plain text lines, in order of priority
+var timestampFormats = []timestampFormat{
+	{regex: regexp.MustCompile(`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(?:[.,]\d+)?(?:Z|[+-]\d{2}:?\d{2})?`),
+		layouts: []string{time.RFC3339Nano, "2006-01-02T15:04:05.999999999Z0700", "2006-01-02 15:04:05.999999999Z07:00", "2006-01-02 15:04:05.999999999Z0700", "2006-01-02T15:04:05.999999999", "2006-01-02 15:04:05.999999999"}},
+	{regex: regexp.MustCompile(`\d{2}/[A-Z][a-z]{2}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}`),
+		layouts: []string{"02/Jan/2006:15:04:05 -0700"}},
+	{regex: regexp.MustCompile(`\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}(?:\.\d+)?`),
+		layouts: []string{"2006/01/02 15:04:05.999999999"}},
+	{regex: regexp.MustCompile(`[A-Z][a-z]{2} [ _\d]\d \d{2}:\d{2}:\d{2}(?:\.\d+)?`),
+		layouts: []string{"Jan _2 15:04:05.999999999"}, noYear: true},
+}
+
+// ParseTimestamp extract the time of the line. The timestamp fields of the structured lines are preferred,
+// otherwise the first known format found in the line is used. The times without timezone are considered local
+func ParseTimestamp(line []byte, fields map[string]interface{}) (time.Time, bool) {
+	line = StripANSI(line)
+	for _, key := range timestampKeys {
+		if value, ok := fields[key]; ok {
+			if t, ok := timestampFromValue(value); ok {
+				return t, true
+			}
+		}
+	}
+	return findTimestamp(string(line))
+}
+
+// findTimestamp parse the first known format found in the text. The formats without the year (syslog) use the current one,
+// or the previous one if the time is in the future (the lines of December read in January)
+func findTimestamp(text string) (time.Time, bool) {
+	for _, format := range timestampFormats {
+		if match := format.regex.FindString(text); match != "" {
+			if t, ok := parseLayouts(match, format.layouts); ok {
+				if format.noYear {
+					t = withYear(t, time.Now())
+				}
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// maxClockSkew is how much a time without the year can be in the future before is considered of the previous year,
+// the lines of the other hosts can be a bit ahead (clock or timezone not aligned)
+const maxClockSkew = 24 * time.Hour
+
+// withYear set the year of a time parsed without it: the current year, unless the time is in the future
+func withYear(t, now time.Time) time.Time {
+	t = time.Date(now.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), t.Location())
+	if t.After(now.Add(maxClockSkew)) {
+		t = t.AddDate(-1, 0, 0)
+	}
+	return t
+}
+
+// timestampFromValue convert a field value (string or unix time in seconds/milliseconds/nanoseconds) into time
+func timestampFromValue(value interface{}) (time.Time, bool) {
+	var n float64
+	switch v := value.(type) {
+	case string:
+		if t, ok := findTimestamp(v); ok {
+			return t, true
+		}
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return time.Time{}, false
+		}
+		n = f
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return time.Time{}, false
+		}
+		n = f
+	case float64:
+		n = v
+	default:
+		return time.Time{}, false
+	}
+	switch {
+	case n > 1e17: // Nanoseconds
+		return time.Unix(0, int64(n)), true
+	case n > 1e14: // Microseconds
+		return time.Unix(0, int64(n)*int64(time.Microsecond)), true
+	case n > 1e11: // Milliseconds
+		return time.Unix(0, int64(n)*int64(time.Millisecond)), true
+	case n > 0:
+		return time.Unix(0, int64(n*float64(time.Second))), true
+	}
+	return time.Time{}, false
+}
+
+func parseLayouts(value string, layouts []string) (time.Time, bool) {
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
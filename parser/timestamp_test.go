@@ -0,0 +1,82 @@
+package parser
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestParseTimestamp(t *testing.T) {
+	year := time.Now().Year()
+	december := year // The syslog times in the future are of the previous year
+	if time.Date(year, 12, 24, 23, 59, 59, 5e8, time.Local).After(time.Now().Add(maxClockSkew)) {
+		december--
+	}
+	tests := []struct {
+		line     string
+		fields   map[string]interface{}
+		expected time.Time
+		missing  bool
+	}{
+		{line: "2024-01-02T10:00:00Z INFO started", expected: time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)},
+		{line: "2024-01-02T10:00:00.250+02:00 INFO started", expected: time.Date(2024, 1, 2, 8, 0, 0, 250e6, time.UTC)},
+		{line: "2024-01-02 10:00:00,123 WARN slow", expected: time.Date(2024, 1, 2, 10, 0, 0, 123e6, time.Local)},
+		{line: `127.0.0.1 - - [02/Jan/2024:10:00:00 +0100] "GET / HTTP/1.1" 200`, expected: time.Date(2024, 1, 2, 9, 0, 0, 0, time.UTC)},
+		{line: "2024/01/02 10:00:00 listening on :8080", expected: time.Date(2024, 1, 2, 10, 0, 0, 0, time.Local)},
+		{line: "Jan  2 10:00:00 host sshd[42]: accepted", expected: time.Date(year, 1, 2, 10, 0, 0, 0, time.Local)},
+		{line: "Dec 24 23:59:59.5 host cron: done", expected: time.Date(december, 12, 24, 23, 59, 59, 5e8, time.Local)},
+		{line: "\x1b[90m2024-01-02T10:00:00Z\x1b[0m INFO coloured", expected: time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)},
+		{line: "INFO no timestamp here", missing: true},
+		{line: "", missing: true},
+		// The fields are preferred to the text
+		{line: `2020-05-05T00:00:00Z {"time": "2024-01-02T10:00:00Z"}`, fields: map[string]interface{}{"time": "2024-01-02T10:00:00Z"},
+			expected: time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)},
+		{line: `{"ts": 1704189600}`, fields: map[string]interface{}{"ts": json.Number("1704189600")}, expected: time.Unix(1704189600, 0)},
+		{line: `{"ts": 1704189600.5}`, fields: map[string]interface{}{"ts": 1704189600.5}, expected: time.Unix(1704189600, 5e8)},
+		{line: `{"ts": 1704189600123}`, fields: map[string]interface{}{"ts": json.Number("1704189600123")}, expected: time.Unix(1704189600, 123e6)},
+		{line: `{"ts": 1704189600123456}`, fields: map[string]interface{}{"ts": json.Number("1704189600123456")}, expected: time.Unix(1704189600, 123456e3)},
+		{line: `{"ts": 1704189600123456000}`, fields: map[string]interface{}{"ts": json.Number("1704189600123456000")}, expected: time.Unix(1704189600, 123456000)},
+		{line: `{"@timestamp": "1704189600"}`, fields: map[string]interface{}{"@timestamp": "1704189600"}, expected: time.Unix(1704189600, 0)},
+		{line: `{"date": "Jan  2 10:00:00"}`, fields: map[string]interface{}{"date": "Jan  2 10:00:00"}, expected: time.Date(year, 1, 2, 10, 0, 0, 0, time.Local)},
+		// Invalid fields fall back to the text
+		{line: `2024-01-02T10:00:00Z {"time": "yesterday"}`, fields: map[string]interface{}{"time": "yesterday"},
+			expected: time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)},
+		{line: `{"ts": -1}`, fields: map[string]interface{}{"ts": json.Number("-1")}, missing: true},
+		{line: `{"ts": true}`, fields: map[string]interface{}{"ts": true}, missing: true},
+	}
+	for _, test := range tests {
+		parsed, ok := ParseTimestamp([]byte(test.line), test.fields)
+		if test.missing {
+			if ok {
+				t.Errorf("ParseTimestamp(%q) = %s, expected no timestamp", test.line, parsed)
+			}
+			continue
+		}
+		if !ok {
+			t.Errorf("ParseTimestamp(%q): timestamp not found", test.line)
+			continue
+		}
+		if !parsed.Equal(test.expected) {
+			t.Errorf("ParseTimestamp(%q) = %s, expected %s", test.line, parsed, test.expected)
+		}
+	}
+}
+
+func TestWithYear(t *testing.T) {
+	now := time.Date(2025, 1, 3, 9, 0, 0, 0, time.UTC)
+	tests := []struct {
+		parsed   time.Time
+		expected time.Time
+	}{
+		{parsed: time.Date(0, 1, 3, 8, 0, 0, 0, time.UTC), expected: time.Date(2025, 1, 3, 8, 0, 0, 0, time.UTC)},
+		{parsed: time.Date(0, 1, 3, 20, 0, 0, 0, time.UTC), expected: time.Date(2025, 1, 3, 20, 0, 0, 0, time.UTC)}, // Host ahead of some hours
+		{parsed: time.Date(0, 1, 4, 9, 0, 0, 0, time.UTC), expected: time.Date(2025, 1, 4, 9, 0, 0, 0, time.UTC)},
+		{parsed: time.Date(0, 1, 4, 9, 0, 1, 0, time.UTC), expected: time.Date(2024, 1, 4, 9, 0, 1, 0, time.UTC)},
+		{parsed: time.Date(0, 12, 31, 23, 59, 59, 0, time.UTC), expected: time.Date(2024, 12, 31, 23, 59, 59, 0, time.UTC)}, // Read after the new year
+	}
+	for _, test := range tests {
+		if year := withYear(test.parsed, now); !year.Equal(test.expected) {
+			t.Errorf("withYear(%s) = %s, expected %s", test.parsed, year, test.expected)
+		}
+	}
+}
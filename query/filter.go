@@ -0,0 +1,54 @@
+package query
+
+import (
+	"bytes"
+
+	"github.com/alessiosavi/GoLog-Viewer/parser"
+)
+
+// Filter combine every criteria that can be used for select the lines of a file:
+// the text (optionally reversed and case insensitive), the level and the query on the fields.
+// The reverse flag is applied only to the text, the lines have always to satisfy the level and the query
+type Filter struct {
+	Text       string             // Text that have to be contained in the line
+	Reverse    bool               // Select the lines that does not contains the text
+	IgnoreCase bool               // Search the text ignoring the case
+	Level      parser.LevelFilter // Levels accepted
+	Query      Query              // Query on the fields of the line
+	Parser     *parser.Parser     // Parser used for extract the fields
+}
+
+// Match verify if the line of the given file satisfy every criteria of the filter
+func (f Filter) Match(path string, line []byte) bool {
+	if !f.matchLine(line) {
+		return false
+	}
+	return f.Query.IsEmpty() || f.Query.Match(f.Parser.Fields(path, line))
+}
+
+// MatchParsed verify if the line satisfy the filter, using the fields already extracted from the line
+func (f Filter) MatchParsed(line []byte, fields map[string]interface{}) bool {
+	return f.matchLine(line) && f.Query.Match(fields)
+}
+
+// matchLine verify the text and the level criteria, ignoring the ANSI escape sequences
+func (f Filter) matchLine(line []byte) bool {
+	line = parser.StripANSI(line)
+	if f.Text != "" {
+		toFind := []byte(f.Text)
+		target := line
+		if f.IgnoreCase {
+			toFind = bytes.ToLower(toFind)
+			target = bytes.ToLower(line)
+		}
+		if bytes.Contains(target, toFind) == f.Reverse {
+			return false
+		}
+	}
+	return f.Level.Match(line)
+}
+
+// IsEmpty return true if the filter accept every line
+func (f Filter) IsEmpty() bool {
+	return f.Text == "" && f.Level.IsEmpty() && f.Query.IsEmpty()
+}
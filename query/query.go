@@ -0,0 +1,374 @@
+// Package query implements the field based query language used for search the structured log lines.
+//
+// A query is composed by comparisons joined by AND, OR, NOT and parenthesis:
+//
+//	user_id=42 AND latency_ms>500
+//	http.request.method="POST" OR (level=error AND NOT retry)
+//
+// Supported operators are = != > >= < <= and ~ (contains). A field without operator verify only the presence of the field.
+// Unquoted values are typed (number, true, false, null), quoted values are always compared as string.
+package query
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/alessiosavi/GoLog-Viewer/parser"
+)
+
+// Query is a compiled query. The zero value match every line
+type Query struct {
+	root node
+	expr string
+}
+
+// Parse compile the given expression. An empty expression return a query that match everything
+func Parse(expr string) (Query, error) {
+	if strings.TrimSpace(expr) == "" {
+		return Query{}, nil
+	}
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return Query{}, err
+	}
+	p := queryParser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return Query{}, err
+	}
+	if p.pos < len(p.tokens) {
+		return Query{}, fmt.Errorf("unexpected token %q at position %d", p.tokens[p.pos].text, p.tokens[p.pos].offset)
+	}
+	return Query{root: root, expr: expr}, nil
+}
+
+// IsEmpty return true if the query match every line
+func (q Query) IsEmpty() bool {
+	return q.root == nil
+}
+
+// String return the original expression
+func (q Query) String() string {
+	return q.expr
+}
+
+// Match verify if the given fields satisfy the query
+func (q Query) Match(fields map[string]interface{}) bool {
+	if q.root == nil {
+		return true
+	}
+	return q.root.match(fields)
+}
+
+/* ------------- AST ------------- */
+
+type node interface {
+	match(fields map[string]interface{}) bool
+}
+
+type andNode struct{ left, right node }
+
+func (n andNode) match(fields map[string]interface{}) bool {
+	return n.left.match(fields) && n.right.match(fields)
+}
+
+type orNode struct{ left, right node }
+
+func (n orNode) match(fields map[string]interface{}) bool {
+	return n.left.match(fields) || n.right.match(fields)
+}
+
+type notNode struct{ child node }
+
+func (n notNode) match(fields map[string]interface{}) bool {
+	return !n.child.match(fields)
+}
+
+type existsNode struct{ path string }
+
+func (n existsNode) match(fields map[string]interface{}) bool {
+	_, ok := parser.Lookup(fields, n.path)
+	return ok
+}
+
+type compareNode struct {
+	path  string
+	op    string
+	value literal
+}
+
+func (n compareNode) match(fields map[string]interface{}) bool {
+	value, ok := parser.Lookup(fields, n.path)
+	if !ok {
+		return false
+	}
+	return compare(value, n.op, n.value)
+}
+
+/* ------------- COMPARISON ------------- */
+
+// literal is the value on the right side of a comparison
+type literal struct {
+	text     string
+	quoted   bool
+	number   float64
+	isNumber bool
+}
+
+func newLiteral(text string, quoted bool) literal {
+	l := literal{text: text, quoted: quoted}
+	if !quoted {
+		if n, err := strconv.ParseFloat(text, 64); err == nil {
+			l.number, l.isNumber = n, true
+		}
+	}
+	return l
+}
+
+// compare apply the operator to the field value and the literal, taking care of the type of the field
+func compare(value interface{}, op string, l literal) bool {
+	switch v := value.(type) {
+	case json.Number:
+		if n, err := v.Float64(); err == nil && !l.quoted {
+			return compareNumber(n, op, l)
+		}
+		return compareString(v.String(), op, l.text)
+	case float64:
+		if !l.quoted {
+			return compareNumber(v, op, l)
+		}
+		return compareString(strconv.FormatFloat(v, 'f', -1, 64), op, l.text)
+	case string:
+		if l.isNumber && op != "~" { // Numeric string ("42") compared with a number
+			if n, err := strconv.ParseFloat(v, 64); err == nil {
+				return compareNumber(n, op, l)
+			}
+		}
+		return compareString(v, op, l.text)
+	case bool:
+		if l.quoted || (l.text != "true" && l.text != "false") {
+			return op == "!="
+		}
+		return compareString(strconv.FormatBool(v), op, l.text)
+	case nil:
+		if l.quoted || l.text != "null" {
+			return op == "!="
+		}
+		return op == "=" || op == ">=" || op == "<="
+	default: // Object and array can be only tested for existence
+		return false
+	}
+}
+
+func compareNumber(n float64, op string, l literal) bool {
+	if !l.isNumber {
+		return compareString(strconv.FormatFloat(n, 'f', -1, 64), op, l.text)
+	}
+	switch op {
+	case "=":
+		return n == l.number
+	case "!=":
+		return n != l.number
+	case ">":
+		return n > l.number
+	case ">=":
+		return n >= l.number
+	case "<":
+		return n < l.number
+	case "<=":
+		return n <= l.number
+	case "~":
+		return strings.Contains(strconv.FormatFloat(n, 'f', -1, 64), l.text)
+	}
+	return false
+}
+
+func compareString(s, op, text string) bool {
+	switch op {
+	case "=":
+		return s == text
+	case "!=":
+		return s != text
+	case ">":
+		return s > text
+	case ">=":
+		return s >= text
+	case "<":
+		return s < text
+	case "<=":
+		return s <= text
+	case "~":
+		return strings.Contains(s, text)
+	}
+	return false
+}
+
+/* ------------- PARSER ------------- */
+
+const (
+	tokenWord = iota
+	tokenString
+	tokenOperator
+	tokenOpen
+	tokenClose
+)
+
+type token struct {
+	kind   int
+	text   string
+	offset int
+}
+
+var operators = []string{">=", "<=", "!=", "=", ">", "<", "~"}
+
+func tokenize(expr string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(':
+			tokens = append(tokens, token{kind: tokenOpen, text: "(", offset: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokenClose, text: ")", offset: i})
+			i++
+		case c == '"' || c == '\'':
+			end := i + 1
+			var text strings.Builder
+			for ; end < len(expr) && expr[end] != c; end++ {
+				if expr[end] == '\\' && end+1 < len(expr) {
+					end++
+				}
+				text.WriteByte(expr[end])
+			}
+			if end >= len(expr) {
+				return nil, fmt.Errorf("unterminated string at position %d", i)
+			}
+			tokens = append(tokens, token{kind: tokenString, text: text.String(), offset: i})
+			i = end + 1
+		case strings.IndexByte("=!<>~", c) >= 0:
+			op := ""
+			for _, candidate := range operators {
+				if strings.HasPrefix(expr[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("invalid operator at position %d", i)
+			}
+			tokens = append(tokens, token{kind: tokenOperator, text: op, offset: i})
+			i += len(op)
+		default:
+			end := i
+			for end < len(expr) && strings.IndexByte(" \t\n\r()\"'=!<>~", expr[end]) < 0 {
+				end++
+			}
+			tokens = append(tokens, token{kind: tokenWord, text: expr[i:end], offset: i})
+			i = end
+		}
+	}
+	return tokens, nil
+}
+
+type queryParser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *queryParser) peek() *token {
+	if p.pos < len(p.tokens) {
+		return &p.tokens[p.pos]
+	}
+	return nil
+}
+
+func (p *queryParser) keyword(word string) bool {
+	t := p.peek()
+	if t != nil && t.kind == tokenWord && strings.EqualFold(t.text, word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *queryParser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("OR") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.keyword("AND") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseNot() (node, error) {
+	if p.keyword("NOT") {
+		child, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{child: child}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *queryParser) parsePrimary() (node, error) {
+	t := p.peek()
+	if t == nil {
+		return nil, errors.New("unexpected end of query")
+	}
+	switch t.kind {
+	case tokenOpen:
+		p.pos++
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if t := p.peek(); t == nil || t.kind != tokenClose {
+			return nil, errors.New("missing closing parenthesis")
+		}
+		p.pos++
+		return n, nil
+	case tokenWord, tokenString:
+		p.pos++
+		path := t.text
+		op := p.peek()
+		if op == nil || op.kind != tokenOperator {
+			return existsNode{path: path}, nil
+		}
+		p.pos++
+		value := p.peek()
+		if value == nil || (value.kind != tokenWord && value.kind != tokenString) {
+			return nil, fmt.Errorf("missing value after %q at position %d", op.text, op.offset)
+		}
+		p.pos++
+		return compareNode{path: path, op: op.text, value: newLiteral(value.text, value.kind == tokenString)}, nil
+	}
+	return nil, fmt.Errorf("unexpected token %q at position %d", t.text, t.offset)
+}
@@ -0,0 +1,126 @@
+package query
+
+import (
+	"testing"
+
+	"github.com/alessiosavi/GoLog-Viewer/parser"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		expr    string
+		invalid bool
+	}{
+		{expr: ""},
+		{expr: "   "},
+		{expr: "user_id=42"},
+		{expr: `http.request.method="POST" OR (level=error AND NOT retry)`},
+		{expr: "not retry and latency_ms>=500"},
+		{expr: `msg~'timed out'`},
+		{expr: "user_id=", invalid: true},
+		{expr: "(level=error", invalid: true},
+		{expr: "level=error)", invalid: true},
+		{expr: `msg="unterminated`, invalid: true},
+		{expr: "level!error", invalid: true},
+		{expr: "level=error AND", invalid: true},
+		{expr: "NOT", invalid: true},
+	}
+	for _, test := range tests {
+		q, err := Parse(test.expr)
+		if test.invalid {
+			if err == nil {
+				t.Errorf("Parse(%q): expected an error", test.expr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Parse(%q): unexpected error %v", test.expr, err)
+			continue
+		}
+		if q.String() != test.expr && !q.IsEmpty() {
+			t.Errorf("Parse(%q).String() = %q", test.expr, q.String())
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	fields := parser.ParseFields([]byte(`{"user_id": 42, "latency_ms": 730.5, "level": "error", "code": "404", "retry": false,
+		"parent": null, "msg": "upstream timed out", "http": {"request": {"method": "POST"}}, "items": [{"id": 7}], "id": 12345678901234567890}`))
+	tests := []struct {
+		expr  string
+		match bool
+	}{
+		{expr: "", match: true},
+		// Numbers
+		{expr: "user_id=42", match: true},
+		{expr: "user_id!=42", match: false},
+		{expr: "latency_ms>500", match: true},
+		{expr: "latency_ms>=730.5", match: true},
+		{expr: "latency_ms<730.5", match: false},
+		{expr: "latency_ms<=1e3", match: true},
+		{expr: `user_id="42"`, match: true}, // Quoted: compared as string
+		{expr: "user_id~4", match: true},
+		{expr: "id=12345678901234567890", match: true},
+		// Numeric strings
+		{expr: "code=404", match: true},
+		{expr: "code>400", match: true},
+		{expr: "code<400", match: false},
+		// Strings
+		{expr: "level=error", match: true},
+		{expr: "level=ERROR", match: false},
+		{expr: "level!=warn", match: true},
+		{expr: "level>debug", match: true},
+		{expr: `msg~"timed out"`, match: true},
+		{expr: "msg~refused", match: false},
+		// Booleans and null
+		{expr: "retry=false", match: true},
+		{expr: "retry=true", match: false},
+		{expr: `retry="false"`, match: false},
+		{expr: "retry!=1", match: true},
+		{expr: "parent=null", match: true},
+		{expr: "parent!=null", match: false},
+		{expr: "parent=0", match: false},
+		// Paths
+		{expr: "http.request.method=POST", match: true},
+		{expr: "items.0.id=7", match: true},
+		{expr: "items.1.id=7", match: false},
+		{expr: "http=POST", match: false}, // Objects can be only tested for existence
+		// Existence
+		{expr: "retry", match: true},
+		{expr: "http.request", match: true},
+		{expr: "missing", match: false},
+		{expr: "missing!=1", match: false},
+		// Boolean operators
+		{expr: "user_id=42 AND latency_ms>500", match: true},
+		{expr: "user_id=42 AND latency_ms>1000", match: false},
+		{expr: "user_id=1 OR level=error", match: true},
+		{expr: "NOT missing", match: true},
+		{expr: "NOT NOT missing", match: false},
+		{expr: "user_id=1 AND level=error OR retry=false", match: true}, // AND before OR
+		{expr: "user_id=1 AND (level=error OR retry=false)", match: false},
+		{expr: "not user_id=1 and (level=warn or http.request.method=POST)", match: true},
+	}
+	for _, test := range tests {
+		q, err := Parse(test.expr)
+		if err != nil {
+			t.Errorf("Parse(%q): unexpected error %v", test.expr, err)
+			continue
+		}
+		if match := q.Match(fields); match != test.match {
+			t.Errorf("Match(%q) = %v, expected %v", test.expr, match, test.match)
+		}
+	}
+}
+
+func TestMatchNotStructured(t *testing.T) {
+	q, err := Parse("level=error")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q.Match(nil) {
+		t.Error("a line without fields can't satisfy a comparison")
+	}
+	if !(Query{}).Match(nil) {
+		t.Error("the empty query have to match every line")
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/gob"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/alessiosavi/GoLog-Viewer/config"
+	"github.com/alessiosavi/GoLog-Viewer/datastructure"
+	"github.com/alessiosavi/GoLog-Viewer/drain"
+	log "github.com/sirupsen/logrus"
+)
+
+/* ------------- SNAPSHOT ------------- */
+
+// snapshotVersion is the version of the format of the snapshot, the snapshots of other versions are ignored
+const snapshotVersion = 1
+
+// Snapshot is the state of the files saved on disk, used for avoid to read again every file after a restart
+type Snapshot struct {
+	Version int
+	Created time.Time
+	Files   []FileSnapshot
+}
+
+// FileSnapshot is the state of a file: the compressed lines in memory, the offset of the ingestion and the related statistics
+type FileSnapshot struct {
+	Path      string
+	Inode     uint64 // Identify the file, a different inode means that the file was rotated (0 if not available)
+	Timestamp int64  // Last modification of the file when the lines were read
+	Offset    int64
+	Data      []byte // Lines in memory (compressed)
+	Levels    map[string]int
+	Bytes     int           // Size of the (uncompressed) lines in memory
+	Limits    config.Limits // Limits applied when the lines were read, the lines are read again if the limits changed
+	Templates []drain.Seen
+}
+
+// WriteSnapshot save the state of the managed files. The file is replaced atomically, in order to never leave a truncated snapshot
+func WriteSnapshot(path string, fileList []datastructure.LogFileStruct) error {
+	snapshot := Snapshot{Version: snapshotVersion, Created: time.Now()}
+	for _, i := range ManagedFiles(fileList) {
+		state := fileList[i].State() // The lines, the offset and the statistics are consistent with each other
+		info := state.Info
+		file := FileSnapshot{Path: info.Path, Timestamp: info.Timestamp, Offset: info.Offset, Data: state.Data, Levels: info.Levels, Bytes: info.Bytes, Limits: info.Limits}
+		if stat, err := os.Stat(info.Path); err == nil {
+			file.Inode = fileID(stat)
+		}
+		if state.Templates != nil {
+			file.Templates = state.Templates.Seen()
+		}
+		snapshot.Files = append(snapshot.Files, file)
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err = gob.NewEncoder(tmp).Encode(snapshot); err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// LoadSnapshot read the snapshot, the state of the files is returned by path
+func LoadSnapshot(path string) (map[string]FileSnapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var snapshot Snapshot
+	if err = gob.NewDecoder(f).Decode(&snapshot); err != nil {
+		return nil, errors.New("unable to decode " + path + ": " + err.Error())
+	}
+	if snapshot.Version != snapshotVersion {
+		return nil, errors.New("unsupported version " + strconv.Itoa(snapshot.Version) + " of " + path)
+	}
+	files := make(map[string]FileSnapshot, len(snapshot.Files))
+	for _, file := range snapshot.Files {
+		files[file.Path] = file
+	}
+	log.Info("LoadSnapshot | ", len(files), " files in the snapshot created at ", snapshot.Created.Format(time.RFC3339))
+	return files, nil
+}
+
+// RestoreLogFile restore the state of the file from the snapshot. The snapshot is discarded if the file was rotated or truncated,
+// the lines are read again if the limits changed. The lines appended after the snapshot are the baseline of the ingestion (see CatchUp).
+// The caller have to hold the lock of the file
+func RestoreLogFile(logFile *datastructure.LogFileStruct, snapshot FileSnapshot, limits config.Limits) bool {
+	path := logFile.LogFileInfoStruct.Path
+	info, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	if inode := fileID(info); inode != snapshot.Inode || info.Size() < snapshot.Offset {
+		log.Info("RestoreLogFile | File ", path, " rotated or truncated since the snapshot, reading it again")
+		return false
+	}
+	logFile.LogFileInfoStruct.Offset = snapshot.Offset
+	logFile.LogFileInfoStruct.Timestamp = snapshot.Timestamp // The core engine read the file again if it was modified
+	logFile.Templates = drain.RestoreHistory(snapshot.Templates)
+	if n := CatchUp(logFile, time.Now()); n > 0 { // Stale lines, the core engine ingest only the lines appended after the start
+		log.Info("RestoreLogFile | ", n, " lines appended to ", path, " since the snapshot used as baseline")
+	}
+	if !snapshot.Limits.SameLines(limits) {
+		ReadLogFile(logFile, limits)
+		return true
+	}
+	logFile.Data = snapshot.Data
+	logFile.LogFileInfoStruct.Levels = snapshot.Levels
+	logFile.LogFileInfoStruct.Bytes = snapshot.Bytes
+	logFile.LogFileInfoStruct.Limits = limits
+	return true
+}
+
+// SnapshotEngine save the state of the files at every interval, until the context is cancelled (the last snapshot is saved by the shutdown)
+func SnapshotEngine(ctx context.Context, fileList []datastructure.LogFileStruct, logCfg *datastructure.Configuration) {
+	log.Trace("SnapshotEngine | START")
+	for {
+		select {
+		case <-time.After(time.Duration(*logCfg.SnapshotInterval) * time.Minute):
+		case <-ctx.Done():
+			log.Trace("SnapshotEngine | STOP")
+			return
+		}
+		start := time.Now()
+		if err := WriteSnapshot(*logCfg.Snapshot, fileList); err != nil {
+			log.Error("SnapshotEngine | Unable to save the snapshot in ", *logCfg.Snapshot, " | Err: ", err)
+			continue
+		}
+		log.Debug("SnapshotEngine | Snapshot saved in ", *logCfg.Snapshot, " | Elapsed: ", time.Since(start))
+	}
+}
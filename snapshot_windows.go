@@ -0,0 +1,11 @@
+//go:build windows
+// +build windows
+
+package main
+
+import "os"
+
+// fileID is not available on Windows, the rotated files are recognized only by the size
+func fileID(info os.FileInfo) uint64 {
+	return 0
+}
@@ -0,0 +1,456 @@
+package ui
+
+import "strings"
+
+/* ------------- WEB UI ------------- */
+
+// Asset is a static file of the web interface, compiled into the binary
+type Asset struct {
+	ContentType string
+	Content     string
+}
+
+// Prefix is the path used for expose the web interface
+const Prefix = "/ui/"
+
+var assets = map[string]Asset{
+	"":           {ContentType: "text/html; charset=utf-8", Content: indexHTML},
+	"index.html": {ContentType: "text/html; charset=utf-8", Content: indexHTML},
+	"app.css":    {ContentType: "text/css; charset=utf-8", Content: appCSS},
+	"app.js":     {ContentType: "application/javascript; charset=utf-8", Content: appJS},
+}
+
+// Find return the asset related to the given path (/ui/app.js)
+func Find(path string) (Asset, bool) {
+	if path+"/" == Prefix {
+		path = Prefix
+	}
+	if !strings.HasPrefix(path, Prefix) {
+		return Asset{}, false
+	}
+	asset, ok := assets[strings.TrimPrefix(path, Prefix)]
+	return asset, ok
+}
+
+const indexHTML = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>GoLog Viewer</title>
+<link rel="stylesheet" href="app.css">
+</head>
+<body>
+<header>
+  <h1>GoLog Viewer</h1>
+  <form id="search" autocomplete="off">
+    <input id="filter" type="search" placeholder="Text to search">
+    <input id="q" type="search" placeholder="Query: user_id=42 AND latency_ms>500">
+    <select id="level" title="Level">
+      <option value="">All levels</option>
+      <option value="trace+">trace+</option>
+      <option value="debug+">debug+</option>
+      <option value="info+">info+</option>
+      <option value="warn+">warn+</option>
+      <option value="error+">error+</option>
+      <option value="fatal">fatal</option>
+    </select>
+    <label><input id="ignoreCase" type="checkbox"> Ignore case</label>
+    <label><input id="reverse" type="checkbox"> Exclude matches</label>
+    <label><input id="follow" type="checkbox" checked> Live tail</label>
+    <button type="submit">Search</button>
+    <button id="clear" type="button">Clear</button>
+  </form>
+</header>
+<main>
+  <nav id="tree"></nav>
+  <section>
+    <div id="status"></div>
+    <pre id="lines"></pre>
+  </section>
+</main>
+<script src="app.js"></script>
+</body>
+</html>
+`
+
+const appCSS = `* { box-sizing: border-box; }
+body { margin: 0; font-family: sans-serif; font-size: 14px; color: #222; height: 100vh; display: flex; flex-direction: column; }
+header { display: flex; align-items: center; gap: 16px; padding: 8px 12px; background: #263238; color: #eceff1; }
+header h1 { font-size: 16px; margin: 0; white-space: nowrap; }
+form { display: flex; flex-wrap: wrap; align-items: center; gap: 8px; flex: 1; }
+form input[type=search] { padding: 4px 6px; min-width: 200px; flex: 1; }
+main { display: flex; flex: 1; min-height: 0; }
+nav { width: 280px; overflow: auto; border-right: 1px solid #cfd8dc; padding: 8px; background: #fafafa; }
+nav ul { list-style: none; margin: 0; padding-left: 14px; }
+nav > ul { padding-left: 0; }
+nav summary { cursor: pointer; font-weight: bold; }
+nav a { display: block; padding: 2px 4px; color: #37474f; text-decoration: none; border-radius: 3px; white-space: nowrap; }
+nav a:hover { background: #eceff1; }
+nav a.active { background: #1565c0; color: #fff; }
+nav .count { float: right; font-size: 11px; color: #c62828; margin-left: 8px; }
+nav a.active .count { color: #fff; }
+section { flex: 1; display: flex; flex-direction: column; min-width: 0; }
+#status { padding: 4px 12px; font-size: 12px; color: #607d8b; border-bottom: 1px solid #eceff1; }
+#status.error { color: #c62828; }
+pre { margin: 0; padding: 8px 12px; overflow: auto; flex: 1; font-size: 12px; line-height: 1.4; }
+pre div { white-space: pre-wrap; word-break: break-all; }
+mark { background: #fff176; }
+.TRACE { color: #90a4ae; }
+.DEBUG { color: #607d8b; }
+.INFO { color: #1b5e20; }
+.WARN { color: #e65100; }
+.ERROR { color: #c62828; }
+.FATAL { color: #fff; background: #b71c1c; }
+`
+
+const appJS = `(function () {
+  "use strict";
+
+  var REFRESH = 3000; // Milliseconds among every request of the live tail
+  var MAX_LINES = 5000; // Lines rendered in the page
+
+  var LEVEL_ALIASES = {
+    trace: "TRACE", trc: "TRACE", trac: "TRACE", finest: "TRACE",
+    debug: "DEBUG", dbg: "DEBUG", debu: "DEBUG", fine: "DEBUG",
+    info: "INFO", inf: "INFO", information: "INFO", notice: "INFO",
+    warn: "WARN", warning: "WARN", wrn: "WARN",
+    error: "ERROR", err: "ERROR", eror: "ERROR", erro: "ERROR", severe: "ERROR",
+    fatal: "FATAL", ftl: "FATAL", fata: "FATAL", critical: "FATAL", crit: "FATAL", panic: "FATAL", pani: "FATAL",
+    emerg: "FATAL", emergency: "FATAL", alert: "FATAL"
+  };
+  var LEVEL_KEYS = { level: true, lvl: true, severity: true, loglevel: true };
+
+  var $ = function (id) { return document.getElementById(id); };
+  var state = { file: "", filter: "", q: "", level: "", ignoreCase: false, reverse: false, follow: true };
+  var timer = null;
+  var lastTimestamp = null;
+
+  // Same rules of parser.DetectLevel: an explicit key wins, otherwise the first upper case alias
+  function detectLevel(line) {
+    var words = line.match(/[A-Za-z]+/g) || [];
+    var candidate = "";
+    for (var i = 0; i < words.length; i++) {
+      var level = LEVEL_ALIASES[words[i].toLowerCase()];
+      if (i > 0 && LEVEL_KEYS[words[i - 1].toLowerCase()] && level) {
+        return level;
+      }
+      if (!candidate && level && words[i].length >= 3 && words[i].toUpperCase() === words[i]) {
+        candidate = level;
+      }
+    }
+    return candidate;
+  }
+
+  function escapeRegExp(text) {
+    return text.replace(/[.*+?^${}()|[\]\\]/g, "\\$&");
+  }
+
+  // Wrap the matches contained in the text nodes of the element with <mark>
+  function highlightNode(node, highlight) {
+    if (node.nodeType !== Node.TEXT_NODE) {
+      Array.prototype.slice.call(node.childNodes).forEach(function (child) {
+        highlightNode(child, highlight);
+      });
+      return;
+    }
+    var text = node.nodeValue;
+    var fragment = document.createDocumentFragment();
+    var last = 0;
+    text.replace(highlight, function (match, offset) {
+      fragment.appendChild(document.createTextNode(text.slice(last, offset)));
+      var mark = document.createElement("mark");
+      mark.textContent = match;
+      fragment.appendChild(mark);
+      last = offset + match.length;
+      return match;
+    });
+    if (last > 0) {
+      fragment.appendChild(document.createTextNode(text.slice(last)));
+      node.parentNode.replaceChild(fragment, node);
+    }
+  }
+
+  // Only the lines requested with ansi=html are markup (the server escape the text and convert the colours to <span>),
+  // the other ones are plain text
+  function renderLine(line, highlight, html) {
+    var div = document.createElement("div");
+    if (html) {
+      div.innerHTML = line;
+    } else {
+      div.textContent = line;
+    }
+    var level = detectLevel(div.textContent);
+    if (level) {
+      div.className = level;
+    }
+    if (highlight) {
+      highlightNode(div, highlight);
+    }
+    return div;
+  }
+
+  function render(data, html) {
+    var lines = data.replace(/\n$/, "").split("\n");
+    if (lines.length === 1 && lines[0] === "") {
+      lines = [];
+    }
+    if (lines.length > MAX_LINES) {
+      lines = lines.slice(lines.length - MAX_LINES);
+    }
+    var highlight = null;
+    if (state.filter && !state.reverse) {
+      highlight = new RegExp(escapeRegExp(state.filter), state.ignoreCase ? "gi" : "g");
+    }
+    var pre = $("lines");
+    var bottom = pre.scrollHeight - pre.scrollTop - pre.clientHeight < 20;
+    var fragment = document.createDocumentFragment();
+    for (var i = 0; i < lines.length; i++) {
+      fragment.appendChild(renderLine(lines[i], highlight, html));
+    }
+    pre.textContent = "";
+    pre.appendChild(fragment);
+    if (bottom) { // Keep following the end of the file
+      pre.scrollTop = pre.scrollHeight;
+    }
+    return lines.length;
+  }
+
+  function setStatus(text, error) {
+    $("status").textContent = text;
+    $("status").className = error ? "error" : "";
+  }
+
+  function api(path, params) {
+    var query = new URLSearchParams(params).toString();
+    return fetch(path + "?" + query).then(function (response) {
+      return response.json();
+    }).then(function (status) {
+      if (!status.Status) {
+        throw new Error(status.ErrorCode + ": " + status.Description);
+      }
+      return status.Data;
+    });
+  }
+
+  function isSearch() {
+    return state.filter !== "" || state.q !== "" || state.level !== "";
+  }
+
+  function load() {
+    clearTimeout(timer);
+    if (!state.file) {
+      setStatus("Select a file");
+      return;
+    }
+    var request;
+    if (isSearch()) {
+      var params = { file: state.file, json: "on", ansi: "html" };
+      ["filter", "q", "level"].forEach(function (key) {
+        if (state[key]) {
+          params[key] = state[key];
+        }
+      });
+      if (state.ignoreCase) {
+        params.ignoreCase = "on";
+      }
+      if (state.reverse) {
+        params.reverse = "on";
+      }
+      if (state.follow) { // Counted by the server as subscriber of the live tail
+        params.follow = "on";
+      }
+      request = api("/api/v1/search", params).then(function (data) {
+        return { data: data, timestamp: null, html: params.ansi === "html" };
+      });
+    } else {
+      var content = { file: state.file, json: "on", ansi: "html" };
+      if (state.follow) {
+        content.follow = "on";
+      }
+      request = api("/api/v1/files/content", content).then(function (data) {
+        return { data: data.Data, timestamp: data.Timestamp, html: content.ansi === "html" };
+      });
+    }
+    request.then(function (result) {
+      if (result.timestamp === null || result.timestamp !== lastTimestamp) {
+        var n = render(result.data, result.html);
+        setStatus(state.file + " | " + n + " lines" + (isSearch() ? " matching" : "") + " | " + new Date().toLocaleTimeString());
+      }
+      lastTimestamp = result.timestamp;
+    }).catch(function (err) {
+      setStatus(err.message, true);
+    }).then(function () {
+      if (state.follow) {
+        timer = setTimeout(load, REFRESH);
+      }
+    });
+  }
+
+  // The state of the page is saved in the hash of the URL, so it can be shared (permalink)
+  function readHash() {
+    var params = new URLSearchParams(location.hash.slice(1));
+    state.file = params.get("file") || "";
+    state.filter = params.get("filter") || "";
+    state.q = params.get("q") || "";
+    state.level = params.get("level") || "";
+    state.ignoreCase = params.get("ignoreCase") === "on";
+    state.reverse = params.get("reverse") === "on";
+    state.follow = params.get("follow") !== "off";
+    $("filter").value = state.filter;
+    $("q").value = state.q;
+    $("level").value = state.level;
+    $("ignoreCase").checked = state.ignoreCase;
+    $("reverse").checked = state.reverse;
+    $("follow").checked = state.follow;
+    markActive();
+  }
+
+  function writeHash() {
+    var params = new URLSearchParams();
+    ["file", "filter", "q", "level"].forEach(function (key) {
+      if (state[key]) {
+        params.set(key, state[key]);
+      }
+    });
+    if (state.ignoreCase) {
+      params.set("ignoreCase", "on");
+    }
+    if (state.reverse) {
+      params.set("reverse", "on");
+    }
+    if (!state.follow) {
+      params.set("follow", "off");
+    }
+    var hash = "#" + params.toString();
+    if (location.hash !== hash) {
+      location.hash = hash; // Trigger the hashchange event, that reload the lines
+    } else {
+      lastTimestamp = null;
+      load();
+    }
+  }
+
+  function markActive() {
+    var links = $("tree").getElementsByTagName("a");
+    for (var i = 0; i < links.length; i++) {
+      links[i].className = links[i].getAttribute("data-file") === state.file ? "active" : "";
+    }
+  }
+
+  function fileLink(name, info) {
+    var a = document.createElement("a");
+    a.href = "#";
+    a.setAttribute("data-file", info.Path);
+    a.title = info.Members ? info.Members.join("\n") : info.Path;
+    a.textContent = name;
+    var problems = info.Levels ? (info.Levels.ERROR || 0) + (info.Levels.FATAL || 0) : 0;
+    if (problems > 0) {
+      var count = document.createElement("span");
+      count.className = "count";
+      count.textContent = problems;
+      a.appendChild(count);
+    }
+    a.addEventListener("click", function (event) {
+      event.preventDefault();
+      state.file = info.Path;
+      writeHash();
+    });
+    return a;
+  }
+
+  // Build the tree of the folders from the path of the files
+  function buildTree(files) {
+    var root = { dirs: {}, files: [] };
+    var virtual = [];
+    files.forEach(function (info) {
+      if (info.Members) {
+        virtual.push(info);
+        return;
+      }
+      var parts = info.Path.split("/").filter(function (part) { return part !== ""; });
+      var node = root;
+      for (var i = 0; i < parts.length - 1; i++) {
+        node = node.dirs[parts[i]] = node.dirs[parts[i]] || { dirs: {}, files: [] };
+      }
+      node.files.push({ name: parts[parts.length - 1], info: info });
+    });
+    // Collapse the folders that contain only one folder (/var/log/app -> var/log/app)
+    function collapse(name, node) {
+      var names = Object.keys(node.dirs);
+      while (names.length === 1 && node.files.length === 0) {
+        name = name + "/" + names[0];
+        node = node.dirs[names[0]];
+        names = Object.keys(node.dirs);
+      }
+      return { name: name, node: node };
+    }
+    function renderNode(node) {
+      var ul = document.createElement("ul");
+      Object.keys(node.dirs).sort().forEach(function (dir) {
+        var collapsed = collapse(dir, node.dirs[dir]);
+        var li = document.createElement("li");
+        var details = document.createElement("details");
+        details.open = true;
+        var summary = document.createElement("summary");
+        summary.textContent = collapsed.name;
+        details.appendChild(summary);
+        details.appendChild(renderNode(collapsed.node));
+        li.appendChild(details);
+        ul.appendChild(li);
+      });
+      node.files.sort(function (a, b) { return a.name < b.name ? -1 : 1; }).forEach(function (file) {
+        var li = document.createElement("li");
+        li.appendChild(fileLink(file.name, file.info));
+        ul.appendChild(li);
+      });
+      return ul;
+    }
+    if (virtual.length > 0) {
+      root.dirs["virtual files"] = { dirs: {}, files: virtual.map(function (info) { return { name: info.Path, info: info }; }) };
+    }
+    var tree = $("tree");
+    tree.textContent = "";
+    tree.appendChild(renderNode(collapse("", root).node));
+    markActive();
+  }
+
+  function loadFiles() {
+    api("/api/v1/files", {}).then(buildTree).catch(function (err) {
+      setStatus(err.message, true);
+    });
+  }
+
+  $("search").addEventListener("submit", function (event) {
+    event.preventDefault();
+    state.filter = $("filter").value;
+    state.q = $("q").value;
+    state.level = $("level").value;
+    state.ignoreCase = $("ignoreCase").checked;
+    state.reverse = $("reverse").checked;
+    state.follow = $("follow").checked;
+    writeHash();
+  });
+  $("clear").addEventListener("click", function () {
+    $("filter").value = "";
+    $("q").value = "";
+    $("level").value = "";
+    $("reverse").checked = false;
+    $("search").dispatchEvent(new Event("submit", { cancelable: true }));
+  });
+  $("follow").addEventListener("change", function () {
+    state.follow = $("follow").checked;
+    writeHash();
+  });
+  window.addEventListener("hashchange", function () {
+    readHash();
+    lastTimestamp = null;
+    load();
+  });
+
+  readHash();
+  loadFiles();
+  setInterval(loadFiles, REFRESH * 10); // Refresh the level counters
+  load();
+})();
+`